@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	addressHandler struct {
+		bc           *blockchain.Blockchain
+		addressIndex *blockchain.AddressIndex
+	}
+
+	RestAddress interface {
+		UnspentOutputs() func(http.ResponseWriter, *http.Request)
+		Balances() func(http.ResponseWriter, *http.Request)
+		Transactions() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+// MaxAddressPage is the number of transactions /addresses/{address}/transactions returns per page
+const MaxAddressPage = 100
+
+// NewAddressHandler serves the addresses of bc, their transactions come from addressIndex when it isn't nil
+func NewAddressHandler(bc *blockchain.Blockchain, addressIndex *blockchain.AddressIndex) RestAddress {
+	return &addressHandler{bc: bc, addressIndex: addressIndex}
+}
+
+func (h *addressHandler) UnspentOutputs() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.bc.Genesis().Consensus.LedgerMode != blockchain.LedgerModeUTXO {
+			http.Error(w, "Unspent outputs are only tracked in utxo ledger mode", http.StatusBadRequest)
+			return
+		}
+
+		address := r.PathValue("address")
+		unspent := h.bc.UnspentOutputs(address)
+		var balance blockchain.Amount
+		for _, output := range unspent {
+			balance += output.Amount
+		}
+
+		response := map[string]interface{}{
+			"address": address,
+			"utxos":   unspent,
+			"balance": balance,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+func (h *addressHandler) Balances() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := r.PathValue("address")
+		response := map[string]interface{}{
+			"address":  address,
+			"balances": h.bc.Balances(address),
+			"nonce":    h.bc.PendingNonce(address),
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+func (h *addressHandler) Transactions() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.addressIndex == nil {
+			http.Error(w, "The address index is disabled, set address_index in the configuration", http.StatusNotImplemented)
+			return
+		}
+
+		values := r.URL.Query()
+		direction := values.Get("direction")
+		if direction != "" && direction != blockchain.DirectionSent && direction != blockchain.DirectionReceived {
+			http.Error(w, "direction must be sent or received", http.StatusBadRequest)
+			return
+		}
+		limit := MaxAddressPage
+		if values.Has("limit") {
+			var err error
+			if limit, err = strconv.Atoi(values.Get("limit")); err != nil || limit < 1 {
+				http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+			limit = min(limit, MaxAddressPage)
+		}
+		var beforeBlock, beforePosition int
+		if cursor := values.Get("cursor"); cursor != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+			if _, scanErr := fmt.Sscanf(string(decoded), "%d:%d", &beforeBlock, &beforePosition); err != nil || scanErr != nil || beforeBlock < 1 {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+		}
+
+		address := r.PathValue("address")
+		transactions, more := h.addressIndex.Transactions(address, direction, beforeBlock, beforePosition, limit)
+		response := map[string]interface{}{
+			"address":      address,
+			"transactions": transactions,
+		}
+		if more {
+			last := transactions[len(transactions)-1]
+			response["next_cursor"] = base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", last.BlockIndex, last.Position)))
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
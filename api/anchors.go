@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	anchorHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestAnchor interface {
+		GetAnchor() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewAnchorHandler(bc *blockchain.Blockchain) RestAnchor {
+	return &anchorHandler{bc: bc}
+}
+
+// GetAnchor tells which block notarized a document hash
+func (h *anchorHandler) GetAnchor() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		anchor, ok := h.bc.Anchor(r.PathValue("hash"))
+		if !ok {
+			http.Error(w, "Document hash hasn't been anchored", http.StatusNotFound)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, anchor)
+	}
+}
@@ -2,21 +2,20 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
-	"sync"
 
 	"diy.blockchain.org/m/blockchain"
 )
 
-// TODO: this should be persisted in a database
-var bc = blockchain.NewBlockchain()
-
 type (
 	ErrorDto struct {
 		Error string `json:"error"`
 	}
 
 	BlockAndChainHandler struct {
+		bc *blockchain.Blockchain
 	}
 
 	RestBlockAndChain interface {
@@ -24,59 +23,75 @@ type (
 		MineBlock() func(http.ResponseWriter, *http.Request)
 		GetChain() func(http.ResponseWriter, *http.Request)
 		RegisterNodes() func(http.ResponseWriter, *http.Request)
+		ListNodes() func(http.ResponseWriter, *http.Request)
 		ResolveConflicts() func(http.ResponseWriter, *http.Request)
 	}
 )
 
-var onceBlockAndChainHandler sync.Once
-var instanceBlockAndChainHandler *BlockAndChainHandler
-
-func BlockAndChainHandlerInstance() RestBlockAndChain {
-	onceBlockAndChainHandler.Do(func() {
-		instanceBlockAndChainHandler = &BlockAndChainHandler{}
-	})
-	return instanceBlockAndChainHandler
+func NewBlockAndChainHandler(bc *blockchain.Blockchain) RestBlockAndChain {
+	return &BlockAndChainHandler{bc: bc}
 }
 
 func (nt *BlockAndChainHandler) NewTransaction() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		var request struct {
+			blockchain.Transaction
+			// Amount is a number of base units, or a decimal string in the unit of the asset like "1.25"
+			Amount json.RawMessage `json:"amount"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+			return
+		}
+		txn := request.Transaction
+		if txn.Amount, err = nt.amount(txn.Asset, request.Amount); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		var txn blockchain.Transaction
-		err := json.NewDecoder(r.Body).Decode(&txn)
+		index, err := nt.bc.AddTransaction(&txn)
+		if errors.Is(err, blockchain.ErrDuplicateTransaction) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
-			http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		index := bc.NewTransaction(txn.Sender, txn.Recipient, txn.Amount)
 		response := map[string]interface{}{
-			"message":     "Transaction will be added to Block",
-			"block_index": index,
+			"message":        "Transaction will be added to Block",
+			"block_index":    index,
+			"transaction_id": txn.ID,
 		}
 		RespondWithJSON(w, http.StatusCreated, response)
 	}
 }
 
-func (nt *BlockAndChainHandler) MineBlock() func(http.ResponseWriter, *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		var previousHash string
-		if lastBlock := bc.LastBlock(); lastBlock != nil {
-			previousHash = lastBlock.Hash
-		} else {
-			previousHash = "0" // Genesis block case
+// amount reads the amount of a new transaction, given in base units or as a decimal string of the asset
+func (nt *BlockAndChainHandler) amount(asset string, raw json.RawMessage) (blockchain.Amount, error) {
+	var units blockchain.Amount
+	if len(raw) == 0 {
+		return units, nil
+	}
+	if raw[0] == '"' {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return 0, err
 		}
+		return nt.bc.ParseAmount(asset, value)
+	}
+	if err := json.Unmarshal(raw, &units); err != nil {
+		return 0, errors.New("amount must be an integer of base units or a decimal string")
+	}
+	return units, nil
+}
 
+func (nt *BlockAndChainHandler) MineBlock() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
 		// Mine the block with the pending transactions
-		newBlock := bc.NewBlock(previousHash)
+		newBlock := nt.bc.NewBlock(nt.bc.LatestBlock().Hash)
 		response := map[string]interface{}{
 			"message": "New Block Forged",
 			"block":   newBlock,
@@ -87,14 +102,27 @@ func (nt *BlockAndChainHandler) MineBlock() func(http.ResponseWriter, *http.Requ
 
 func (nt *BlockAndChainHandler) GetChain() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		query, err := parseChainQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		blocks, length, cursor, err := query.blocks(nt.bc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
 			return
 		}
 
+		if query.ndjson {
+			streamChain(w, query.items(blocks))
+			return
+		}
 		response := map[string]interface{}{
-			"chain":  bc.Chain,
-			"length": len(bc.Chain),
+			"chain":  query.items(blocks),
+			"length": length,
+		}
+		if cursor != "" {
+			response["next_cursor"] = cursor
 		}
 		RespondWithJSON(w, http.StatusOK, response)
 	}
@@ -102,11 +130,6 @@ func (nt *BlockAndChainHandler) GetChain() func(http.ResponseWriter, *http.Reque
 
 func (nt *BlockAndChainHandler) RegisterNodes() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
 		var payload struct {
 			Nodes []string `json:"nodes"`
 		}
@@ -118,36 +141,47 @@ func (nt *BlockAndChainHandler) RegisterNodes() func(http.ResponseWriter, *http.
 		}
 
 		for _, node := range payload.Nodes {
-			bc.RegisterNode(node)
+			nt.bc.RegisterNode(node)
+		}
+		nodes := map[string]bool{}
+		for _, node := range nt.bc.Peers() {
+			nodes[node] = true
 		}
 
 		response := map[string]interface{}{
 			"message":     "New nodes have been added",
-			"total_nodes": bc.Nodes,
+			"total_nodes": nodes,
 		}
 		RespondWithJSON(w, http.StatusCreated, response)
 	}
 }
 
-func (nt *BlockAndChainHandler) ResolveConflicts() func(http.ResponseWriter, *http.Request) {
+func (nt *BlockAndChainHandler) ListNodes() func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+		nodes := nt.bc.Peers()
+		response := map[string]interface{}{
+			"nodes": nodes,
+			"total": len(nodes),
 		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
 
-		replaced := bc.ResolveConflicts()
+func (nt *BlockAndChainHandler) ResolveConflicts() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		replaced := nt.bc.ResolveConflicts()
+		chain, _ := nt.bc.Blocks(1, math.MaxInt)
 
 		var response map[string]interface{}
 		if replaced {
 			response = map[string]interface{}{
 				"message":   "Our chain was replaced",
-				"new_chain": bc.Chain,
+				"new_chain": chain,
 			}
 		} else {
 			response = map[string]interface{}{
 				"message": "Our chain is authoritative",
-				"chain":   bc.Chain,
+				"chain":   chain,
 			}
 		}
 
@@ -1,6 +1,7 @@
 package api_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -8,12 +9,17 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
 	"diy.blockchain.org/m/api"
+	"diy.blockchain.org/m/blockchain"
 	"diy.blockchain.org/m/configuration"
+	"diy.blockchain.org/m/webhooks"
 	"gopkg.in/yaml.v2"
 )
 
@@ -24,14 +30,20 @@ func TestMain(m *testing.M) {
 	serverPort = randomServerPort()
 
 	// load test configuration
-	serverConfiguration := fmt.Sprintf("http_port: \"%d\"\n", serverPort)
+	serverConfiguration := fmt.Sprintf("network:\n  http_port: %d\nstorage:\n  address_index: true\nwebhooks:\n  allowed_networks: [127.0.0.0/8]\n", serverPort)
 	yamlData := []byte(serverConfiguration)
-	if err := yaml.Unmarshal(yamlData, &configuration.InstanceConfig); err != nil {
+	config := configuration.Default()
+	if err := yaml.UnmarshalStrict(yamlData, config); err != nil {
 		fmt.Printf("Failed to parse config data: %v\n", err)
 	}
 
 	// start HTTP Server
-	go startServer(readyCh)
+	server, err := api.NewServer(config)
+	if err != nil {
+		fmt.Printf("Failed to create server: %v\n", err)
+		os.Exit(1)
+	}
+	go startServer(server, readyCh)
 	select {
 	case <-readyCh:
 		fmt.Println("Server is ready")
@@ -42,7 +54,13 @@ func TestMain(m *testing.M) {
 
 	// Run tests
 	code := m.Run()
-	// TODO: teardown
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("Failed to shut the server down: %v\n", err)
+		code = 1
+	}
 	os.Exit(code)
 }
 
@@ -74,9 +92,42 @@ func TestNewTransaction(t *testing.T) {
 	}
 }
 
+// pendingNonce returns the nonce the next transaction of address must carry, so tests don't depend on the ones run before
+func pendingNonce(t *testing.T, address string) uint64 {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/addresses/%s/balances", serverPort, address))
+	if err != nil {
+		t.Fatalf("Failed to make request to /addresses/%s/balances: %v", address, err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Nonce uint64 `json:"nonce"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	return result.Nonce
+}
+
+// latestBlock returns the last block of the chain
+func latestBlock(t *testing.T) blockchain.Block {
+	t.Helper()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/blocks/latest", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /blocks/latest: %v", err)
+	}
+	defer resp.Body.Close()
+	var block blockchain.Block
+	if err := json.NewDecoder(resp.Body).Decode(&block); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	return block
+}
+
 func TestMineBlock(t *testing.T) {
+	latest := latestBlock(t)
 	// Add a sample transaction before mining a block
-	payload := []byte(`{"sender": "Alice", "recipient": "Bob", "amount": 10}`)
+	payload := []byte(`{"sender": "Trent", "recipient": "Bob", "amount": 10, "nonce": 0}`)
 	url := fmt.Sprintf("http://localhost:%d/transactions/new", serverPort)
 	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
 	if err != nil {
@@ -124,9 +175,9 @@ func TestMineBlock(t *testing.T) {
 		t.Errorf("Expected block data in response, got %v", result["block"])
 	}
 
-	// The index of the first block should be 2 (since genesis block has index 1)
-	if blockData["index"] != float64(2) {
-		t.Errorf("Expected block index 2, got %v", blockData["index"])
+	// The block is mined on top of the latest one
+	if blockData["index"] != float64(latest.Index+1) {
+		t.Errorf("Expected block index %d, got %v", latest.Index+1, blockData["index"])
 	}
 }
 
@@ -168,9 +219,8 @@ func randomServerPort() int {
 	return listener.Addr().(*net.TCPAddr).Port
 }
 
-func startServer(ch chan<- bool) {
-	ctx := context.Background()
-	go api.Start(ctx, &configuration.InstanceConfig)
+func startServer(server *api.Server, ch chan<- bool) {
+	go server.Start()
 
 	// Retry logic: make request every 2 seconds until success
 	for {
@@ -248,4 +298,612 @@ func TestRegisterNodes(t *testing.T) {
 	} else {
 		t.Errorf("Expected 'total_nodes' to be a map[string]bool, got %v", result["total_nodes"])
 	}
+
+	// The registered nodes are listed, sorted
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/nodes", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to send request to /nodes: %v", err)
+	}
+	defer resp.Body.Close()
+	var listed struct {
+		Nodes []string `json:"nodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	if !slices.IsSorted(listed.Nodes) || !slices.Contains(listed.Nodes, nodes[0]) || !slices.Contains(listed.Nodes, nodes[1]) {
+		t.Errorf("Expected %v to be listed in order, got %v", nodes, listed.Nodes)
+	}
+}
+
+func TestNewTransactionWrongChain(t *testing.T) {
+	payload := []byte(`{"chain_id": "other-network", "sender": "Alice", "recipient": "Bob", "amount": 10}`)
+	url := fmt.Sprintf("http://localhost:%d/transactions/new", serverPort)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	url := fmt.Sprintf("http://localhost:%d/info", serverPort)
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to make request to /info: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var info api.InfoDto
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+
+	if info.ChainID != blockchain.DefaultChainID {
+		t.Errorf("Expected chain id %s, got %s", blockchain.DefaultChainID, info.ChainID)
+	}
+	if info.GenesisHash != blockchain.NewBlockchain().GenesisHash() {
+		t.Errorf("Expected default genesis hash, got %s", info.GenesisHash)
+	}
+}
+
+func TestNewTransactionDuplicate(t *testing.T) {
+	payload := []byte(`{"sender": "Dave", "recipient": "Erin", "amount": 10}`)
+	url := fmt.Sprintf("http://localhost:%d/transactions/new", serverPort)
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, err = http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status code %d for a duplicate, got %d", http.StatusConflict, resp.StatusCode)
+	}
+}
+
+func TestPartialTransaction(t *testing.T) {
+	var keys [2][2]string
+	for i := range keys {
+		publicKey, privateKey, err := blockchain.GenerateKey()
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		keys[i] = [2]string{publicKey, privateKey}
+	}
+	account := &blockchain.MultisigAccount{PublicKeys: []string{keys[0][0], keys[1][0]}, Threshold: 2}
+
+	transaction := blockchain.Transaction{ChainID: blockchain.DefaultChainID, Sender: account.Address(), Recipient: "Bob", Amount: 10, Multisig: account}
+	if err := transaction.Sign(keys[0][1]); err != nil {
+		t.Fatalf("Failed to sign transaction: %v", err)
+	}
+	payload, _ := json.Marshal(transaction)
+
+	url := fmt.Sprintf("http://localhost:%d/transactions/partial", serverPort)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/partial: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	var created struct {
+		Partial blockchain.PartialTransaction `json:"partial_transaction"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	if created.Partial.Submitted {
+		t.Error("Expected transaction to wait for the second signature")
+	}
+
+	signature, _ := blockchain.Sign(keys[1][1], created.Partial.Transaction.SigningBytes())
+	payload, _ = json.Marshal(blockchain.Signature{PublicKey: keys[1][0], Signature: signature})
+	url = fmt.Sprintf("http://localhost:%d/transactions/partial/%s/signatures", serverPort, created.Partial.Transaction.ID)
+	resp, err = http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var signed struct {
+		Partial blockchain.PartialTransaction `json:"partial_transaction"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	if !signed.Partial.Submitted {
+		t.Error("Expected transaction to be submitted once the threshold is met")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	payload := []byte(`{"type": "token_create", "sender": "Frank", "token": {"symbol": "FRK", "decimals": 1, "supply": 15}}`)
+	url := fmt.Sprintf("http://localhost:%d/transactions/new", serverPort)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	url = fmt.Sprintf("http://localhost:%d/tokens/FRK/balances/Frank", serverPort)
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to make request to %s: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d before the token is mined, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /mine: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to make request to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var balance blockchain.AssetBalance
+	if err := json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	if balance.Amount != 15 || balance.Formatted != "1.5" {
+		t.Errorf("Expected Frank to hold 1.5 FRK, got %+v", balance)
+	}
+
+	// Amounts can be given in the unit of the asset, with at most its precision
+	url = fmt.Sprintf("http://localhost:%d/transactions/new", serverPort)
+	nonce := pendingNonce(t, "Frank")
+	for amount, status := range map[string]int{`"0.05"`: http.StatusBadRequest, `"0.5"`: http.StatusCreated} {
+		payload = []byte(fmt.Sprintf(`{"sender": "Frank", "recipient": "Grace", "asset": "FRK", "nonce": %d, "amount": %s}`, nonce, amount))
+		resp, err := http.Post(url, "application/json", bytes.NewBuffer(payload))
+		if err != nil {
+			t.Fatalf("Failed to make request to /transactions/new: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected status code %d for amount %s, got %d", status, amount, resp.StatusCode)
+		}
+	}
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /mine: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/tokens/FRK/balances/Grace", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /tokens/FRK/balances/Grace: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	if balance.Amount != 5 {
+		t.Errorf("Expected Grace to hold 0.5 FRK, got %+v", balance)
+	}
+}
+
+func TestTransactionReceipt(t *testing.T) {
+	payload := []byte(`{"sender": "Grace", "recipient": "Heidi", "amount": 2}`)
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/transactions/new", serverPort), "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	var created struct {
+		TransactionID string `json:"transaction_id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+
+	url := fmt.Sprintf("http://localhost:%d/transactions/%s/receipt", serverPort, created.TransactionID)
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to make request to %s: %v", url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a pending transaction, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /mine: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("Failed to make request to %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var receipt blockchain.TransactionReceipt
+	if err := json.NewDecoder(resp.Body).Decode(&receipt); err != nil {
+		t.Fatalf("Failed to parse response JSON: %v", err)
+	}
+	if receipt.TransactionID != created.TransactionID || receipt.Status != blockchain.ReceiptStatusSuccess || receipt.BlockHash == "" {
+		t.Errorf("Unexpected receipt %+v", receipt)
+	}
+}
+
+func TestBlockLookups(t *testing.T) {
+	latest := latestBlock(t)
+
+	for _, path := range []string{fmt.Sprintf("/blocks/%d", latest.Index), "/blocks/hash/" + latest.Hash} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", serverPort, path))
+		if err != nil {
+			t.Fatalf("Failed to make request to %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var block blockchain.Block
+		if err := json.NewDecoder(resp.Body).Decode(&block); err != nil {
+			t.Fatalf("Failed to parse response JSON: %v", err)
+		}
+		if block.Hash != latest.Hash {
+			t.Errorf("Expected %s to return block %s, got %s", path, latest.Hash, block.Hash)
+		}
+	}
+
+	expected := map[string]int{"/blocks/0": http.StatusNotFound, "/blocks/abc": http.StatusBadRequest, "/blocks/hash/none": http.StatusNotFound, "/transactions/none": http.StatusNotFound, "/transactions/pending": http.StatusOK}
+	for path, status := range expected {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", serverPort, path))
+		if err != nil {
+			t.Fatalf("Failed to make request to %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected status code %d for %s, got %d", status, path, resp.StatusCode)
+		}
+	}
+}
+
+func TestChainPagination(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+		if err != nil {
+			t.Fatalf("Failed to make request to /mine: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	type page struct {
+		Chain      []map[string]interface{} `json:"chain"`
+		Length     int                      `json:"length"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	var indexes []float64
+	query := "limit=1&headers=true"
+	for {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chain?%s", serverPort, query))
+		if err != nil {
+			t.Fatalf("Failed to make request to /chain: %v", err)
+		}
+		var response page
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if err != nil || len(response.Chain) != 1 {
+			t.Fatalf("Expected a page of one block, got %+v (%v)", response, err)
+		}
+		if _, ok := response.Chain[0]["transactions"]; ok {
+			t.Errorf("Expected headers only, got %v", response.Chain[0])
+		}
+		indexes = append(indexes, response.Chain[0]["index"].(float64))
+		if response.NextCursor == "" {
+			if len(indexes) != response.Length {
+				t.Errorf("Expected to page through %d blocks, got %v", response.Length, indexes)
+			}
+			break
+		}
+		query = "limit=1&headers=true&cursor=" + response.NextCursor
+	}
+	for i, index := range indexes {
+		if index != float64(i+1) {
+			t.Errorf("Expected block %d on page %d, got %v", i+1, i+1, index)
+		}
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chain?format=ndjson&from=2&to=3", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /chain: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Expected application/x-ndjson, got %s", contentType)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	var streamed []int
+	for decoder.More() {
+		var block blockchain.Block
+		if err := decoder.Decode(&block); err != nil {
+			t.Fatalf("Failed to parse streamed block: %v", err)
+		}
+		streamed = append(streamed, block.Index)
+	}
+	if len(streamed) != 2 || streamed[0] != 2 || streamed[1] != 3 {
+		t.Errorf("Expected blocks 2 and 3 to be streamed, got %v", streamed)
+	}
+
+	for _, query := range []string{"from=0", "limit=abc", "from=3&to=2", "cursor=invalid"} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chain?%s", serverPort, query))
+		if err != nil {
+			t.Fatalf("Failed to make request to /chain: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, query, resp.StatusCode)
+		}
+	}
+}
+
+func TestAddressTransactions(t *testing.T) {
+	for i, payload := range []string{
+		`{"sender": "Ivan", "recipient": "Judy", "amount": 1, "nonce": 0}`,
+		`{"sender": "Judy", "recipient": "Ivan", "amount": 1, "nonce": 0}`,
+	} {
+		resp, err := http.Post(fmt.Sprintf("http://localhost:%d/transactions/new", serverPort), "application/json", bytes.NewBufferString(payload))
+		if err != nil {
+			t.Fatalf("Failed to make request to /transactions/new: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status code %d for transaction %d, got %d: %s", http.StatusCreated, i, resp.StatusCode, body)
+		}
+	}
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /mine: %v", err)
+	}
+	resp.Body.Close()
+
+	type page struct {
+		Transactions []blockchain.AddressTransaction `json:"transactions"`
+		NextCursor   string                          `json:"next_cursor"`
+	}
+	get := func(query string) page {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/addresses/Ivan/transactions?%s", serverPort, query))
+		if err != nil {
+			t.Fatalf("Failed to make request to /addresses/Ivan/transactions: %v", err)
+		}
+		defer resp.Body.Close()
+		var response page
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to parse response JSON: %v", err)
+		}
+		return response
+	}
+
+	first := get("limit=1")
+	if len(first.Transactions) != 1 || first.Transactions[0].Direction != blockchain.DirectionReceived || first.NextCursor == "" {
+		t.Fatalf("Expected the transaction received by Ivan and a cursor, got %+v", first)
+	}
+	second := get("limit=1&cursor=" + first.NextCursor)
+	if len(second.Transactions) != 1 || second.Transactions[0].Direction != blockchain.DirectionSent || second.NextCursor != "" {
+		t.Errorf("Expected the transaction sent by Ivan as the last page, got %+v", second)
+	}
+	if sent := get("direction=sent"); len(sent.Transactions) != 1 || sent.Transactions[0].Transaction.Recipient != "Judy" {
+		t.Errorf("Expected the transaction sent by Ivan, got %+v", sent)
+	}
+
+	for _, query := range []string{"direction=up", "limit=0", "cursor=invalid"} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/addresses/Ivan/transactions?%s", serverPort, query))
+		if err != nil {
+			t.Fatalf("Failed to make request to /addresses/Ivan/transactions: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, query, resp.StatusCode)
+		}
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/events?topics=block.mined", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /events: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %s", contentType)
+	}
+
+	mined, err := http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /mine: %v", err)
+	}
+	mined.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for len(lines) < 3 && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id: ") || lines[1] != "event: block.mined" {
+		t.Fatalf("Expected a block.mined event, got %q", lines)
+	}
+	var event blockchain.Event
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event); err != nil || event.Topic != blockchain.EventBlockMined {
+		t.Errorf("Expected the data of a block.mined event, got %q (%v)", lines[2], err)
+	}
+
+	unknown, err := http.Get(fmt.Sprintf("http://localhost:%d/events?topics=blocks", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /events: %v", err)
+	}
+	unknown.Body.Close()
+	if unknown.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an unknown topic, got %d", http.StatusBadRequest, unknown.StatusCode)
+	}
+}
+
+func TestWebSocketEvents(t *testing.T) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	fmt.Fprint(conn, "GET /events?topics=transaction HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read the handshake: %v", err)
+	}
+	// The accept value of the sample key of RFC 6455
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected the connection to be upgraded, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	payload := []byte(`{"sender": "Mallory", "recipient": "Niaj", "amount": 1}`)
+	created, err := http.Post(fmt.Sprintf("http://localhost:%d/transactions/new", serverPort), "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	created.Body.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil || header[0] != 0x81 {
+		t.Fatalf("Expected a text frame, got %x (%v)", header, err)
+	}
+	length := int(header[1])
+	if length == 126 {
+		extended := make([]byte, 2)
+		io.ReadFull(reader, extended)
+		length = int(extended[0])<<8 | int(extended[1])
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(reader, message); err != nil {
+		t.Fatalf("Failed to read the message: %v", err)
+	}
+	var event blockchain.Event
+	if err := json.Unmarshal(message, &event); err != nil || event.Topic != blockchain.EventTransactionPending {
+		t.Errorf("Expected a transaction.pending event, got %s (%v)", message, err)
+	}
+
+	// A masked close frame without payload is echoed back
+	conn.Write([]byte{0x88, 0x80, 1, 2, 3, 4})
+	if _, err := io.ReadFull(reader, header); err != nil || header[0] != 0x88 {
+		t.Errorf("Expected a close frame, got %x (%v)", header, err)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	payloads := make(chan webhooks.Payload, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhooks.Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+	}))
+	defer receiver.Close()
+
+	subscription := fmt.Sprintf(`{"url": "%s", "events": ["transaction.confirmed"], "address": "Oscar"}`, receiver.URL)
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/webhooks", serverPort), "application/json", bytes.NewBufferString(subscription))
+	if err != nil {
+		t.Fatalf("Failed to make request to /webhooks: %v", err)
+	}
+	var webhook webhooks.Webhook
+	json.NewDecoder(resp.Body).Decode(&webhook)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || webhook.ID == "" || webhook.Secret == "" {
+		t.Fatalf("Expected the webhook to be created with a secret, got %d %+v", resp.StatusCode, webhook)
+	}
+
+	payload := []byte(`{"sender": "Peggy", "recipient": "Oscar", "amount": 3}`)
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%d/transactions/new", serverPort), "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /transactions/new: %v", err)
+	}
+	resp.Body.Close()
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/mine", serverPort))
+	if err != nil {
+		t.Fatalf("Failed to make request to /mine: %v", err)
+	}
+	resp.Body.Close()
+
+	select {
+	case delivered := <-payloads:
+		if delivered.Event != webhooks.EventTransactionConfirmed || delivered.WebhookID != webhook.ID {
+			t.Errorf("Expected the confirmation of the transaction to Oscar, got %+v", delivered)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the webhook to be delivered")
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/webhooks/%s/deliveries", serverPort, webhook.ID))
+	if err != nil {
+		t.Fatalf("Failed to make request to /webhooks/%s/deliveries: %v", webhook.ID, err)
+	}
+	var log struct {
+		Deliveries []webhooks.Delivery `json:"deliveries"`
+	}
+	json.NewDecoder(resp.Body).Decode(&log)
+	resp.Body.Close()
+	if len(log.Deliveries) != 1 || log.Deliveries[0].Event != webhooks.EventTransactionConfirmed {
+		t.Errorf("Expected one delivery in the log, got %+v", log.Deliveries)
+	}
+
+	request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:%d/webhooks/%s", serverPort, webhook.ID), nil)
+	resp, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to delete the webhook: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/webhooks/%s", serverPort, webhook.ID))
+	if err != nil {
+		t.Fatalf("Failed to make request to /webhooks/%s: %v", webhook.ID, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a removed webhook, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = http.Post(fmt.Sprintf("http://localhost:%d/webhooks", serverPort), "application/json", bytes.NewBufferString(`{"url": "nowhere", "events": ["block"]}`))
+	if err != nil {
+		t.Fatalf("Failed to make request to /webhooks: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for an invalid webhook, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
 }
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	blockHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestBlock interface {
+		GetBlock() func(http.ResponseWriter, *http.Request)
+		GetBlockByHash() func(http.ResponseWriter, *http.Request)
+		LatestBlock() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewBlockHandler(bc *blockchain.Blockchain) RestBlock {
+	return &blockHandler{bc: bc}
+}
+
+// GetBlock returns the block at an index, the genesis block being 1
+func (h *blockHandler) GetBlock() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		index, err := strconv.Atoi(r.PathValue("index"))
+		if err != nil {
+			http.Error(w, "Block index must be a number", http.StatusBadRequest)
+			return
+		}
+		block, ok := h.bc.BlockByIndex(index)
+		if !ok {
+			http.Error(w, "Block not found", http.StatusNotFound)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, block)
+	}
+}
+
+// GetBlockByHash returns the block of the chain with a hash
+func (h *blockHandler) GetBlockByHash() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		block, ok := h.bc.BlockByHash(r.PathValue("hash"))
+		if !ok {
+			http.Error(w, "Block not found", http.StatusNotFound)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, block)
+	}
+}
+
+// LatestBlock returns the last block of the chain
+func (h *blockHandler) LatestBlock() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSON(w, http.StatusOK, h.bc.LatestBlock())
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// MaxChainPage is the number of blocks /chain returns per page when paginating
+const MaxChainPage = 100
+
+var errChainReorganized = errors.New("the chain was reorganized since the cursor was issued, restart from a block index")
+
+// chainQuery holds the parameters of /chain. Without any of them the whole chain is returned in one response.
+type chainQuery struct {
+	from, to, limit int
+	// after is the hash the block before from must have, as recorded in the cursor
+	after    string
+	headers  bool
+	ndjson   bool
+	paginate bool
+}
+
+func parseChainQuery(r *http.Request) (chainQuery, error) {
+	values := r.URL.Query()
+	query := chainQuery{
+		from:    1,
+		to:      math.MaxInt,
+		limit:   MaxChainPage,
+		headers: values.Get("headers") == "true",
+		ndjson:  values.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson"),
+	}
+
+	for name, target := range map[string]*int{"from": &query.from, "to": &query.to, "limit": &query.limit} {
+		if !values.Has(name) {
+			continue
+		}
+		value, err := strconv.Atoi(values.Get(name))
+		if err != nil || value < 1 {
+			return query, fmt.Errorf("%s must be a positive block number", name)
+		}
+		*target = value
+		query.paginate = true
+	}
+	query.limit = min(query.limit, MaxChainPage)
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		index, hash, found := strings.Cut(string(decoded), ":")
+		from, atoiErr := strconv.Atoi(index)
+		if err != nil || !found || atoiErr != nil || from < 2 {
+			return query, errors.New("invalid cursor")
+		}
+		query.from, query.after, query.paginate = from, hash, true
+	}
+	if query.from > query.to {
+		return query, errors.New("from can't be after to")
+	}
+	return query, nil
+}
+
+// encodeCursor returns the cursor of the page starting after block
+func encodeCursor(block blockchain.Block) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", block.Index+1, block.Hash)))
+}
+
+// blocks returns the blocks of the page, the length of the chain and the cursor of the next page, if any
+func (q chainQuery) blocks(bc *blockchain.Blockchain) ([]blockchain.Block, int, string, error) {
+	from := q.from
+	if q.after != "" {
+		from-- // Fetch the block the cursor was issued after, to check it is still in the chain
+	}
+	to := q.to
+	if q.paginate && !q.ndjson {
+		to = min(q.to, q.from+q.limit-1)
+	}
+
+	blocks, length := bc.Blocks(from, to)
+	if q.after != "" {
+		if len(blocks) == 0 || blocks[0].Hash != q.after {
+			if from <= length {
+				return nil, 0, "", errChainReorganized
+			}
+		} else {
+			blocks = blocks[1:]
+		}
+	}
+
+	cursor := ""
+	if q.paginate && !q.ndjson && len(blocks) > 0 {
+		if last := blocks[len(blocks)-1]; last.Index < min(q.to, length) {
+			cursor = encodeCursor(last)
+		}
+	}
+	return blocks, length, cursor, nil
+}
+
+// items returns the blocks, or their headers
+func (q chainQuery) items(blocks []blockchain.Block) []interface{} {
+	items := make([]interface{}, len(blocks))
+	for i, block := range blocks {
+		if q.headers {
+			items[i] = block.Header()
+		} else {
+			items[i] = block
+		}
+	}
+	return items
+}
+
+// streamChain writes one JSON document per line, flushing as it goes so full exports don't sit in memory twice
+func streamChain(w http.ResponseWriter, items []interface{}) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for i, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return // The client went away
+		}
+		if flusher != nil && i%MaxChainPage == MaxChainPage-1 {
+			flusher.Flush()
+		}
+	}
+}
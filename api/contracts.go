@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	contractHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestContract interface {
+		GetContract() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewContractHandler(bc *blockchain.Blockchain) RestContract {
+	return &contractHandler{bc: bc}
+}
+
+// GetContract returns the code and storage of a deployed contract
+func (h *contractHandler) GetContract() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contract, ok := h.bc.Contract(r.PathValue("address"))
+		if !ok {
+			http.Error(w, "Contract not found", http.StatusNotFound)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, contract)
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+	"diy.blockchain.org/m/logger"
+)
+
+type (
+	eventHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestEvent interface {
+		Events() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+const (
+	// EventBuffer is the number of events a slow client can lag behind before it misses some
+	EventBuffer = 64
+	// KeepAliveInterval is how often idle streams send something, so proxies don't close them
+	KeepAliveInterval = 15 * time.Second
+)
+
+func NewEventHandler(bc *blockchain.Blockchain) RestEvent {
+	return &eventHandler{bc: bc}
+}
+
+// Events streams the events of the chain as Server-Sent Events, or over a WebSocket when the client asks to upgrade
+func (h *eventHandler) Events() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topics := []string{}
+		for _, value := range r.URL.Query()["topics"] {
+			for _, topic := range strings.Split(value, ",") {
+				if topic = strings.TrimSpace(topic); topic == "" {
+					continue
+				}
+				if !blockchain.ValidTopic(topic) {
+					http.Error(w, fmt.Sprintf("Unknown topic %s", topic), http.StatusBadRequest)
+					return
+				}
+				topics = append(topics, topic)
+			}
+		}
+
+		if isWebSocketUpgrade(r) {
+			ws, err := upgradeWebSocket(w, r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer ws.Close()
+			subscription := h.bc.Events().Subscribe(EventBuffer, topics...)
+			defer subscription.Close()
+			streamWebSocket(r.Context(), ws, subscription)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		subscription := h.bc.Events().Subscribe(EventBuffer, topics...)
+		defer subscription.Close()
+		streamServerSentEvents(w, r, flusher, subscription)
+	}
+}
+
+func streamServerSentEvents(w http.ResponseWriter, r *http.Request, flusher http.Flusher, subscription *blockchain.Subscription) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-subscription.C:
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Errorf("Event %d couldn't be encoded: %v", event.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Topic, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func streamWebSocket(ctx context.Context, ws *webSocket, subscription *blockchain.Subscription) {
+	closed := make(chan struct{})
+	go ws.readLoop(closed)
+
+	keepAlive := time.NewTicker(KeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ws.writeFrame(opClose, closeGoingAway)
+			return
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if ws.writeFrame(opPing, nil) != nil {
+				return
+			}
+		case event := <-subscription.C:
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Errorf("Event %d couldn't be encoded: %v", event.ID, err)
+				continue
+			}
+			if ws.WriteText(data) != nil {
+				return
+			}
+		}
+	}
+}
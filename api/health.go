@@ -2,7 +2,6 @@ package api
 
 import (
 	"net/http"
-	"sync"
 )
 
 type (
@@ -18,14 +17,8 @@ type (
 	}
 )
 
-var onceHealthHandler sync.Once
-var instanceHealthHandler *healthHandler
-
-func HealthHandlerInstance() RestHeartBeat {
-	onceHealthHandler.Do(func() {
-		instanceHealthHandler = &healthHandler{}
-	})
-	return instanceHealthHandler
+func NewHealthHandler() RestHeartBeat {
+	return &healthHandler{}
 }
 
 func (h *healthHandler) Health() func(http.ResponseWriter, *http.Request) {
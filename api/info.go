@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	InfoDto struct {
+		ChainID       string `json:"chain_id"`
+		GenesisHash   string `json:"genesis_hash"`
+		NativeSymbol  string `json:"native_symbol"`
+		Difficulty    int    `json:"difficulty"`
+		Length        int    `json:"length"`
+		LastBlockHash string `json:"last_block_hash"`
+	}
+
+	infoHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestInfo interface {
+		Info() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewInfoHandler(bc *blockchain.Blockchain) RestInfo {
+	return &infoHandler{bc: bc}
+}
+
+func (h *infoHandler) Info() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The index of the last block is the length of the chain, the genesis block being 1
+		latest := h.bc.LatestBlock()
+		RespondWithJSON(w, http.StatusOK, &InfoDto{
+			ChainID:       h.bc.ChainID(),
+			GenesisHash:   h.bc.GenesisHash(),
+			NativeSymbol:  h.bc.Genesis().NativeSymbol,
+			Difficulty:    h.bc.Difficulty(),
+			Length:        latest.Index,
+			LastBlockHash: latest.Hash,
+		})
+	}
+}
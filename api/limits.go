@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxTrackedClients is the number of clients the rate limiter tracks before it forgets the idle ones
+const maxTrackedClients = 10000
+
+// rateLimiter gives every client a bucket of burst tokens refilled at rate tokens per second, each request
+// takes one. A rate of 0 lets every request through.
+type rateLimiter struct {
+	rate    float64
+	burst   int
+	clients map[string]*bucket
+	mu      sync.Mutex
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, clients: make(map[string]*bucket)}
+}
+
+// SetLimit changes the rate and burst of every client, buckets keep their tokens up to the new burst
+func (l *rateLimiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst = rate, burst
+}
+
+// Allow takes a token from the bucket of client and tells if there was one
+func (l *rateLimiter) Allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if len(l.clients) >= maxTrackedClients {
+		l.forgetIdle(now)
+	}
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.clients[client] = b
+	}
+	b.tokens = min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// forgetIdle drops the buckets that refilled, their clients would start from a full bucket anyway
+func (l *rateLimiter) forgetIdle(now time.Time) {
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// clientIP returns the IP a request comes from, without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// guard rejects the requests of clients over the rate limit with 429 Too Many Requests, answers /mine with
+// 503 Service Unavailable while mining is disabled, and caps the size of request bodies
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiter.Allow(clientIP(r)) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Path == "/mine" && !s.mining.Load() {
+			http.Error(w, "Mining is disabled on this node", http.StatusServiceUnavailable)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBodyBytes.Load())
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	multisigHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestMultisig interface {
+		MultisigAddress() func(http.ResponseWriter, *http.Request)
+		PartialTransactions() func(http.ResponseWriter, *http.Request)
+		NewPartialTransaction() func(http.ResponseWriter, *http.Request)
+		AddPartialSignature() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewMultisigHandler(bc *blockchain.Blockchain) RestMultisig {
+	return &multisigHandler{bc: bc}
+}
+
+// MultisigAddress derives the address of a set of public keys and a threshold
+func (h *multisigHandler) MultisigAddress() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var account blockchain.MultisigAccount
+		if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+			http.Error(w, "Invalid multisig account data", http.StatusBadRequest)
+			return
+		}
+		if err := account.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := map[string]interface{}{
+			"address":     account.Address(),
+			"public_keys": account.PublicKeys,
+			"threshold":   account.Threshold,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// PartialTransactions lists the multisig transactions collecting signatures
+func (h *multisigHandler) PartialTransactions() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"partial_transactions": h.bc.PartialTransactions(),
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// NewPartialTransaction starts collecting the signatures of a multisig transaction
+func (h *multisigHandler) NewPartialTransaction() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var txn blockchain.Transaction
+		if err := json.NewDecoder(r.Body).Decode(&txn); err != nil {
+			http.Error(w, "Invalid transaction data", http.StatusBadRequest)
+			return
+		}
+
+		partial, err := h.bc.NewPartialTransaction(&txn)
+		if err != nil {
+			respondWithPartialError(w, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusCreated, partialResponse(partial))
+	}
+}
+
+// AddPartialSignature adds a signature to a partial transaction, which is broadcast once it meets its threshold
+func (h *multisigHandler) AddPartialSignature() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var signature blockchain.Signature
+		if err := json.NewDecoder(r.Body).Decode(&signature); err != nil {
+			http.Error(w, "Invalid signature data", http.StatusBadRequest)
+			return
+		}
+
+		partial, err := h.bc.AddPartialSignature(r.PathValue("id"), signature)
+		if err != nil {
+			respondWithPartialError(w, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, partialResponse(partial))
+	}
+}
+
+func partialResponse(partial blockchain.PartialTransaction) map[string]interface{} {
+	message := "Transaction is waiting for signatures"
+	if partial.Submitted {
+		message = "Transaction will be added to Block"
+	}
+	return map[string]interface{}{
+		"message":             message,
+		"partial_transaction": partial,
+	}
+}
+
+func respondWithPartialError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, blockchain.ErrUnknownTransaction):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, blockchain.ErrDuplicateTransaction):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"slices"
+
+	"diy.blockchain.org/m/configuration"
+	"diy.blockchain.org/m/logger"
+)
+
+// ReloadResult tells which changed fields of the configuration were applied, and which keep their value until
+// the node restarts. Fields are named by their YAML path, like logging.level.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// reloadable applies the fields that can change while the node runs, by YAML path
+var reloadable = map[string]func(s *Server, config *configuration.Config){
+	"logging.level": func(s *Server, config *configuration.Config) {
+		s.config.Logging.Level = config.Logging.Level
+		logger.SetLevel(config.Logging.Level)
+	},
+	"peers.nodes": func(s *Server, config *configuration.Config) {
+		// Only the peers of the configuration are removed, not the ones registered through the API
+		for _, node := range s.config.Peers.Nodes {
+			if !slices.Contains(config.Peers.Nodes, node) {
+				s.bc.RemoveNode(node)
+			}
+		}
+		for _, node := range config.Peers.Nodes {
+			s.bc.RegisterNode(node)
+		}
+		s.config.Peers.Nodes = config.Peers.Nodes
+	},
+	"mining.enabled": func(s *Server, config *configuration.Config) {
+		s.config.Mining.Enabled = config.Mining.Enabled
+		s.mining.Store(config.Mining.Enabled)
+	},
+	"limits.requests_per_second": func(s *Server, config *configuration.Config) {
+		s.config.Limits.RequestsPerSecond = config.Limits.RequestsPerSecond
+		s.limiter.SetLimit(s.config.Limits.RequestsPerSecond, s.config.Limits.Burst)
+	},
+	"limits.burst": func(s *Server, config *configuration.Config) {
+		s.config.Limits.Burst = config.Limits.Burst
+		s.limiter.SetLimit(s.config.Limits.RequestsPerSecond, s.config.Limits.Burst)
+	},
+	"limits.max_body_bytes": func(s *Server, config *configuration.Config) {
+		s.config.Limits.MaxBodyBytes = config.Limits.MaxBodyBytes
+		s.maxBodyBytes.Store(config.Limits.MaxBodyBytes)
+	},
+}
+
+// Reload reads the configuration again, from the file the node was started with, and applies the fields that can
+// change while the node runs. Nothing is applied when the configuration is invalid.
+func (s *Server) Reload() (ReloadResult, error) {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+
+	config, err := configuration.Load(s.config.Path)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	result := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+	for _, field := range configuration.Diff(&s.config, config) {
+		if apply, ok := reloadable[field]; ok {
+			apply(s, config)
+			result.Applied = append(result.Applied, field)
+		} else {
+			result.RestartRequired = append(result.RestartRequired, field)
+		}
+	}
+	return result, nil
+}
+
+// ReloadConfig answers POST /admin/config/reload with the ReloadResult. Only clients on the host of the node
+// may reload its configuration.
+func (s *Server) ReloadConfig() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ip := net.ParseIP(clientIP(r)); ip == nil || !ip.IsLoopback() {
+			http.Error(w, "The configuration can only be reloaded from the host of the node", http.StatusForbidden)
+			return
+		}
+
+		result, err := s.Reload()
+		if err != nil {
+			http.Error(w, "Configuration not reloaded: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Infof("Configuration reloaded, applied %v, restart required for %v", result.Applied, result.RestartRequired)
+		RespondWithJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+	"diy.blockchain.org/m/webhooks"
+)
+
+// NewRouter returns the API of a node serving bc. The address index and the webhook dispatcher are optional,
+// their endpoints answer 501 Not Implemented when they are nil. Routers share nothing, so several nodes can
+// be served from one process.
+func NewRouter(bc *blockchain.Blockchain, addressIndex *blockchain.AddressIndex, dispatcher *webhooks.Dispatcher) http.Handler {
+	health := NewHealthHandler()
+	info := NewInfoHandler(bc)
+	blockAndChain := NewBlockAndChainHandler(bc)
+	transactions := NewTransactionHandler(bc)
+	events := NewEventHandler(bc)
+	blocks := NewBlockHandler(bc)
+	addresses := NewAddressHandler(bc, addressIndex)
+	anchors := NewAnchorHandler(bc)
+	tokens := NewTokenHandler(bc)
+	contracts := NewContractHandler(bc)
+	multisig := NewMultisigHandler(bc)
+	hooks := NewWebhookHandler(dispatcher)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", health.Health())
+	mux.HandleFunc("GET /info", info.Info())
+	mux.HandleFunc("POST /transactions/new", blockAndChain.NewTransaction())
+	mux.HandleFunc("GET /transactions/pending", transactions.PendingTransactions())
+	mux.HandleFunc("GET /transactions/{txid}", transactions.GetTransaction())
+	mux.HandleFunc("GET /transactions/{txid}/receipt", transactions.GetReceipt())
+	mux.HandleFunc("GET /mine", blockAndChain.MineBlock())
+	mux.HandleFunc("GET /chain", blockAndChain.GetChain())
+	mux.HandleFunc("GET /events", events.Events())
+	mux.HandleFunc("GET /blocks/latest", blocks.LatestBlock())
+	mux.HandleFunc("GET /blocks/{index}", blocks.GetBlock())
+	mux.HandleFunc("GET /blocks/hash/{hash}", blocks.GetBlockByHash())
+	mux.HandleFunc("GET /nodes", blockAndChain.ListNodes())
+	mux.HandleFunc("POST /nodes/register", blockAndChain.RegisterNodes())
+	mux.HandleFunc("GET /nodes/resolve", blockAndChain.ResolveConflicts())
+	mux.HandleFunc("GET /addresses/{address}/utxos", addresses.UnspentOutputs())
+	mux.HandleFunc("GET /addresses/{address}/balances", addresses.Balances())
+	mux.HandleFunc("GET /addresses/{address}/transactions", addresses.Transactions())
+	mux.HandleFunc("GET /anchors/{hash}", anchors.GetAnchor())
+	mux.HandleFunc("GET /tokens", tokens.Tokens())
+	mux.HandleFunc("GET /tokens/{symbol}/balances/{address}", tokens.Balance())
+	mux.HandleFunc("GET /contracts/{address}", contracts.GetContract())
+	mux.HandleFunc("POST /multisig", multisig.MultisigAddress())
+	mux.HandleFunc("GET /transactions/partial", multisig.PartialTransactions())
+	mux.HandleFunc("POST /transactions/partial", multisig.NewPartialTransaction())
+	mux.HandleFunc("POST /transactions/partial/{id}/signatures", multisig.AddPartialSignature())
+	mux.HandleFunc("GET /webhooks", hooks.Webhooks())
+	mux.HandleFunc("POST /webhooks", hooks.NewWebhook())
+	mux.HandleFunc("GET /webhooks/{id}", hooks.GetWebhook())
+	mux.HandleFunc("DELETE /webhooks/{id}", hooks.DeleteWebhook())
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", hooks.Deliveries())
+	return mux
+}
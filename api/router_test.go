@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"diy.blockchain.org/m/api"
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestMultipleNodes runs two nodes in the test process, the first one adopting the longer chain of the second.
+func TestMultipleNodes(t *testing.T) {
+	first, second := blockchain.NewBlockchain(), blockchain.NewBlockchain()
+	firstNode := httptest.NewServer(api.NewRouter(first, nil, nil))
+	defer firstNode.Close()
+	secondNode := httptest.NewServer(api.NewRouter(second, nil, nil))
+	defer secondNode.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(secondNode.URL + "/mine")
+		if err != nil {
+			t.Fatalf("Failed to make request to /mine: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(first.Chain) != 1 || len(second.Chain) != 3 {
+		t.Fatalf("Expected the nodes to mine on their own chains, got %d and %d blocks", len(first.Chain), len(second.Chain))
+	}
+
+	payload := fmt.Sprintf(`{"nodes": ["%s"]}`, strings.TrimPrefix(secondNode.URL, "http://"))
+	resp, err := http.Post(firstNode.URL+"/nodes/register", "application/json", bytes.NewBufferString(payload))
+	if err != nil {
+		t.Fatalf("Failed to make request to /nodes/register: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(firstNode.URL + "/nodes/resolve")
+	if err != nil {
+		t.Fatalf("Failed to make request to /nodes/resolve: %v", err)
+	}
+	defer resp.Body.Close()
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result["message"] != "Our chain was replaced" || first.LatestBlock().Hash != second.LatestBlock().Hash {
+		t.Errorf("Expected the first node to adopt the chain of the second, got %v", result["message"])
+	}
+}
+
+// TestRoutes answers 405 to methods a route doesn't serve, and 501 to features the node runs without.
+func TestRoutes(t *testing.T) {
+	node := httptest.NewServer(api.NewRouter(blockchain.NewBlockchain(), nil, nil))
+	defer node.Close()
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodPost, "/mine", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/chain", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/nodes/register", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/addresses/alice/transactions", http.StatusNotImplemented},
+		{http.MethodGet, "/webhooks", http.StatusNotImplemented},
+		{http.MethodGet, "/health", http.StatusOK},
+	}
+	for _, test := range tests {
+		request, _ := http.NewRequest(test.method, node.URL+test.path, nil)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("Failed to make request to %s %s: %v", test.method, test.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("Expected status code %d for %s %s, got %d", test.status, test.method, test.path, resp.StatusCode)
+		}
+	}
+}
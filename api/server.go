@@ -2,20 +2,175 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"diy.blockchain.org/m/blockchain"
 	"diy.blockchain.org/m/configuration"
 	"diy.blockchain.org/m/logger"
-	"go.uber.org/zap"
+	"diy.blockchain.org/m/webhooks"
 )
 
-func Start(ctx context.Context, configuration *configuration.Config) {
-	http.HandleFunc("/health", HealthHandlerInstance().Health())
-	http.HandleFunc("/transactions/new", BlockAndChainHandlerInstance().NewTransaction())
-	http.HandleFunc("/mine", BlockAndChainHandlerInstance().MineBlock())
-	http.HandleFunc("/chain", BlockAndChainHandlerInstance().GetChain())
-	http.HandleFunc("/nodes/register", BlockAndChainHandlerInstance().RegisterNodes())
-	http.HandleFunc("/nodes/resolve", BlockAndChainHandlerInstance().ResolveConflicts())
-	logger.Infof("Server started on port %s", configuration.HttpPort)
-	logger.Fatal("Server didn't start.", zap.Error(http.ListenAndServe(":"+configuration.HttpPort, nil)))
+// Server serves the API of a node until it is shut down
+type Server struct {
+	bc         *blockchain.Blockchain
+	dispatcher *webhooks.Dispatcher
+	httpServer *http.Server
+	// requests is the base context of every request, canceled once shutdown starts so event streams end
+	requests       context.Context
+	cancelRequests context.CancelFunc
+	// background is canceled once in-flight requests are drained, to stop the goroutines tracked by workers
+	background       context.Context
+	cancelBackground context.CancelFunc
+	workers          sync.WaitGroup
+
+	// config holds the configuration in effect, Reload updates the fields it applies
+	config       configuration.Config
+	reloading    sync.Mutex
+	mining       atomic.Bool
+	limiter      *rateLimiter
+	maxBodyBytes atomic.Int64
+}
+
+// NewServer creates the blockchain of the node from the genesis spec of the configuration, registers the peers
+// it lists and routes the API to the blockchain
+func NewServer(config *configuration.Config) (*Server, error) {
+	genesis, err := config.Genesis()
+	if err != nil {
+		return nil, err
+	}
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("Chain %s starts from genesis block %s", genesis.ChainID, bc.GenesisHash())
+	var addressIndex *blockchain.AddressIndex
+	if config.Storage.AddressIndex {
+		addressIndex = blockchain.NewAddressIndex()
+		bc.AddObserver(addressIndex)
+	}
+	for _, node := range config.Peers.Nodes {
+		bc.RegisterNode(node)
+	}
+
+	dispatcher := webhooks.NewDispatcher(bc)
+	for _, network := range config.Webhooks.AllowedNetworks {
+		_, allowed, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("webhooks.allowed_networks: %w", err)
+		}
+		dispatcher.AllowedNetworks = append(dispatcher.AllowedNetworks, allowed)
+	}
+
+	s := &Server{
+		bc:         bc,
+		dispatcher: dispatcher,
+		config:     *config,
+		limiter:    newRateLimiter(config.Limits.RequestsPerSecond, config.Limits.Burst),
+	}
+	s.mining.Store(config.Mining.Enabled)
+	s.maxBodyBytes.Store(config.Limits.MaxBodyBytes)
+	s.requests, s.cancelRequests = context.WithCancel(context.Background())
+	s.background, s.cancelBackground = context.WithCancel(context.Background())
+	mux := http.NewServeMux()
+	mux.Handle("/", NewRouter(bc, addressIndex, s.dispatcher))
+	mux.HandleFunc("POST /admin/config/reload", s.ReloadConfig())
+	s.httpServer = &http.Server{
+		Addr:              config.Address(),
+		Handler:           s.guard(mux),
+		ReadHeaderTimeout: config.Network.ReadHeaderTimeout,
+		IdleTimeout:       config.Network.IdleTimeout,
+		MaxHeaderBytes:    config.Limits.MaxHeaderBytes,
+		BaseContext:       func(net.Listener) context.Context { return s.requests },
+	}
+	// Shutdown doesn't wait for streams, they would never become idle, so end them instead
+	s.httpServer.RegisterOnShutdown(s.cancelRequests)
+	return s, nil
+}
+
+// Blockchain returns the blockchain served by the node
+func (s *Server) Blockchain() *blockchain.Blockchain {
+	return s.bc
+}
+
+// Start runs the background goroutines of the node and serves the API. It blocks until the server is shut down,
+// which returns nil, or fails to serve.
+func (s *Server) Start() error {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		s.dispatcher.Run(s.background)
+	}()
+	s.every(s.config.Mining.Interval, s.mine)
+	s.every(s.config.Peers.SyncInterval, func() { s.bc.ResolveConflicts() })
+
+	logger.Infof("Server started on %s", s.httpServer.Addr)
+	var err error
+	if s.config.TLS.Enabled {
+		err = s.httpServer.ListenAndServeTLS(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		s.cancelBackground()
+		return err
+	}
+	return nil
+}
+
+// every runs task every interval in the background until the server is shut down, never if interval is 0
+func (s *Server) every(interval time.Duration, task func()) {
+	if interval <= 0 {
+		return
+	}
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				task()
+			case <-s.background.Done():
+				return
+			}
+		}
+	}()
+}
+
+// mine mines a block of the pending transactions, if there are some and mining is enabled
+func (s *Server) mine() {
+	if !s.mining.Load() || len(s.bc.PendingTransactions()) == 0 {
+		return
+	}
+	block := s.bc.NewBlock(s.bc.LatestBlock().Hash)
+	logger.Infof("Mined block %d with %d transactions", block.Index, len(block.Transactions))
+}
+
+// Shutdown stops accepting connections, ends the event streams and waits for the in-flight requests, a block
+// being mined included, then stops the background goroutines. It gives up when ctx is done.
+// The chain lives in memory, so there is nothing to flush.
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.httpServer.Shutdown(ctx)
+	s.cancelRequests()
+	s.cancelBackground()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return errors.Join(err, ctx.Err())
+	}
+	logger.Infof("Server stopped")
+	return err
 }
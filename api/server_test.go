@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/api"
+	"diy.blockchain.org/m/configuration"
+	"diy.blockchain.org/m/logger"
+)
+
+// startNode starts a server with config on a random port and returns its URL, it is shut down when the test ends
+func startNode(t *testing.T, config *configuration.Config) (*api.Server, string) {
+	t.Helper()
+	config.Network.Host = "127.0.0.1"
+	config.Network.HttpPort = randomServerPort()
+	server, err := api.NewServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Start()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	})
+
+	url := fmt.Sprintf("http://%s", config.Address())
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if resp, err := http.Get(url + "/health"); err == nil {
+			resp.Body.Close()
+			return server, url
+		}
+	}
+	t.Fatalf("node didn't start on %s", url)
+	return nil, ""
+}
+
+// TestServerLimits rejects large bodies, mining while it is disabled, and clients over their rate limit.
+func TestServerLimits(t *testing.T) {
+	config := configuration.Default()
+	config.Mining.Enabled = false
+	config.Limits.MaxBodyBytes = 64
+	config.Limits.RequestsPerSecond = 0.01
+	config.Limits.Burst = 3
+	_, url := startNode(t, config)
+
+	// The health check waiting for the node took the first token
+	resp, err := http.Get(url + "/mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while mining is disabled, got %d", resp.StatusCode)
+	}
+
+	payload := fmt.Sprintf(`{"sender": "Rupert", "recipient": "Sybil", "amount": 1, "data": "%s"}`, strings.Repeat("x", 64))
+	resp, err = http.Post(url+"/transactions/new", "application/json", bytes.NewBufferString(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a body over the limit, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(url + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("expected 429 once the burst is spent, got %d", resp.StatusCode)
+	}
+}
+
+// TestServerMiningInterval mines pending transactions in the background and registers the configured peers.
+func TestServerMiningInterval(t *testing.T) {
+	config := configuration.Default()
+	config.Mining.Interval = 20 * time.Millisecond
+	config.Peers.Nodes = []string{"localhost:1"}
+	server, url := startNode(t, config)
+	bc := server.Blockchain()
+	if !bc.Nodes["localhost:1"] {
+		t.Errorf("expected the configured peer to be registered, got %v", bc.Nodes)
+	}
+
+	resp, err := http.Post(url+"/transactions/new", "application/json", bytes.NewBufferString(`{"sender": "Rupert", "recipient": "Sybil", "amount": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if len(bc.PendingTransactions()) == 0 && bc.LatestBlock().Index == 2 {
+			return
+		}
+	}
+	t.Errorf("expected the transaction to be mined in block 2, got %+v", bc.LatestBlock())
+}
+
+// TestReloadConfig applies the safe changes of the configuration file and reports the others.
+func TestReloadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("peers:\n  nodes: [\"localhost:1\"]\n")
+	config, err := configuration.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, url := startNode(t, config)
+	bc := server.Blockchain()
+	t.Cleanup(func() { logger.SetLevel("info") })
+
+	network := fmt.Sprintf("network:\n  host: %s\n  http_port: %d\n", config.Network.Host, config.Network.HttpPort)
+	write(network + "  idle_timeout: 1m\nmining:\n  enabled: false\npeers:\n  nodes: [\"localhost:2\"]\nlogging:\n  level: warn\n")
+	resp, err := http.Post(url+"/admin/config/reload", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result api.ReloadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Applied, []string{"mining.enabled", "peers.nodes", "logging.level"}) || !slices.Equal(result.RestartRequired, []string{"network.idle_timeout"}) {
+		t.Errorf("expected mining, peers and logging to apply and the idle timeout to need a restart, got %+v", result)
+	}
+	if bc.Nodes["localhost:1"] || !bc.Nodes["localhost:2"] {
+		t.Errorf("expected the peers to be swapped, got %v", bc.Nodes)
+	}
+	resp, err = http.Get(url + "/mine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected mining to be disabled, got %d", resp.StatusCode)
+	}
+
+	// Invalid configurations are not applied at all
+	write(network + "mining:\n  enabled: true\nlogging:\n  level: loud\n")
+	if result, err := server.Reload(); !errors.Is(err, configuration.ErrInvalidConfig) || len(result.Applied) != 0 {
+		t.Errorf("expected an invalid configuration, got %+v (%v)", result, err)
+	}
+	if resp, err := http.Get(url + "/mine"); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected mining to stay disabled, got %v", err)
+	} else {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	tokenHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestToken interface {
+		Tokens() func(http.ResponseWriter, *http.Request)
+		Balance() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewTokenHandler(bc *blockchain.Blockchain) RestToken {
+	return &tokenHandler{bc: bc}
+}
+
+// Tokens lists every asset of the ledger, the native one included
+func (h *tokenHandler) Tokens() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"tokens": h.bc.Assets(),
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// Balance returns the amount of a token held by an address
+func (h *tokenHandler) Balance() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := r.PathValue("address")
+		balance, err := h.bc.Balance(r.PathValue("symbol"), address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		response := map[string]interface{}{
+			"address":   address,
+			"symbol":    balance.Symbol,
+			"amount":    balance.Amount,
+			"formatted": balance.Formatted,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	transactionHandler struct {
+		bc *blockchain.Blockchain
+	}
+
+	RestTransaction interface {
+		GetReceipt() func(http.ResponseWriter, *http.Request)
+		GetTransaction() func(http.ResponseWriter, *http.Request)
+		PendingTransactions() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+func NewTransactionHandler(bc *blockchain.Blockchain) RestTransaction {
+	return &transactionHandler{bc: bc}
+}
+
+// GetReceipt returns the receipt of a mined transaction
+func (h *transactionHandler) GetReceipt() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		receipt, err := h.bc.Receipt(r.PathValue("txid"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, receipt)
+	}
+}
+
+// GetTransaction returns a confirmed or pending transaction with its number of confirmations
+func (h *transactionHandler) GetTransaction() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		transaction, err := h.bc.Transaction(r.PathValue("txid"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, transaction)
+	}
+}
+
+// PendingTransactions lists the transactions waiting to be mined
+func (h *transactionHandler) PendingTransactions() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"transactions": h.bc.PendingTransactions(),
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
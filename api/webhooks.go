@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"diy.blockchain.org/m/webhooks"
+)
+
+type (
+	webhookHandler struct {
+		dispatcher *webhooks.Dispatcher
+	}
+
+	RestWebhook interface {
+		Webhooks() func(http.ResponseWriter, *http.Request)
+		NewWebhook() func(http.ResponseWriter, *http.Request)
+		GetWebhook() func(http.ResponseWriter, *http.Request)
+		DeleteWebhook() func(http.ResponseWriter, *http.Request)
+		Deliveries() func(http.ResponseWriter, *http.Request)
+	}
+)
+
+// NewWebhookHandler serves the webhooks of dispatcher, or answers 501 Not Implemented when it is nil
+func NewWebhookHandler(dispatcher *webhooks.Dispatcher) RestWebhook {
+	return &webhookHandler{dispatcher: dispatcher}
+}
+
+// Webhooks lists the registered webhooks, without their secrets
+func (h *webhookHandler) Webhooks() func(http.ResponseWriter, *http.Request) {
+	return h.enabled(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]interface{}{
+			"webhooks": h.dispatcher.Webhooks(),
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	})
+}
+
+// NewWebhook registers a webhook. The secret is only returned here.
+func (h *webhookHandler) NewWebhook() func(http.ResponseWriter, *http.Request) {
+	return h.enabled(func(w http.ResponseWriter, r *http.Request) {
+		var subscription webhooks.Subscription
+		if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+			http.Error(w, "Invalid webhook data", http.StatusBadRequest)
+			return
+		}
+		webhook, err := h.dispatcher.Register(subscription)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		RespondWithJSON(w, http.StatusCreated, webhook)
+	})
+}
+
+// GetWebhook returns a webhook
+func (h *webhookHandler) GetWebhook() func(http.ResponseWriter, *http.Request) {
+	return h.enabled(func(w http.ResponseWriter, r *http.Request) {
+		webhook, err := h.dispatcher.Webhook(r.PathValue("id"))
+		if err != nil {
+			respondWithWebhookError(w, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, webhook)
+	})
+}
+
+// DeleteWebhook removes a webhook
+func (h *webhookHandler) DeleteWebhook() func(http.ResponseWriter, *http.Request) {
+	return h.enabled(func(w http.ResponseWriter, r *http.Request) {
+		if err := h.dispatcher.Remove(r.PathValue("id")); err != nil {
+			respondWithWebhookError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Deliveries returns the delivery log of a webhook, newest first
+func (h *webhookHandler) Deliveries() func(http.ResponseWriter, *http.Request) {
+	return h.enabled(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		deliveries, err := h.dispatcher.Deliveries(id)
+		if err != nil {
+			respondWithWebhookError(w, err)
+			return
+		}
+		response := map[string]interface{}{
+			"webhook_id": id,
+			"deliveries": deliveries,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	})
+}
+
+func (h *webhookHandler) enabled(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.dispatcher == nil {
+			http.Error(w, "Webhooks are disabled on this node", http.StatusNotImplemented)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func respondWithWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, webhooks.ErrUnknownWebhook) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// webSocketGUID is appended to the key of the client to compute the accept header, see RFC 6455 section 1.3
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// closeGoingAway is the payload of the close frame sent when the server shuts down, status code 1001
+var closeGoingAway = []byte{0x03, 0xE9}
+
+// maxControlPayload is the largest payload of control frames, and the largest frame we read since clients only
+// have to send us control frames
+const maxControlPayload = 125
+
+// webSocket is the server side of a WebSocket connection, enough to push text messages to a client
+type webSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// isWebSocketUpgrade tells if a request asks to switch to the WebSocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// upgradeWebSocket completes the opening handshake and takes the connection over from the HTTP server
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		return nil, errors.New("only version 13 of the WebSocket protocol is supported")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("the connection can't be upgraded")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + webSocketGUID))
+	handshake := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &webSocket{conn: conn, reader: buffered.Reader}, nil
+}
+
+// WriteText sends a message in a single text frame
+func (ws *webSocket) WriteText(message []byte) error {
+	return ws.writeFrame(opText, message)
+}
+
+func (ws *webSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	// Server frames are never masked
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) <= maxControlPayload:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readLoop answers the control frames of the client until it closes the connection or breaks the protocol,
+// then closes closed. Data frames are ignored, clients have nothing to tell us.
+func (ws *webSocket) readLoop(closed chan<- struct{}) {
+	defer close(closed)
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case opClose:
+			ws.writeFrame(opClose, payload)
+			return
+		case opPing:
+			if ws.writeFrame(opPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (ws *webSocket) readFrame() (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(ws.reader, header); err != nil {
+		return 0, nil, err
+	}
+	opcode, masked, length := header[0]&0x0F, header[1]&0x80 != 0, int(header[1]&0x7F)
+	if !masked {
+		return 0, nil, errors.New("client frames must be masked")
+	}
+	if length > maxControlPayload {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", length)
+	}
+
+	maskAndPayload := make([]byte, 4+length)
+	if _, err := io.ReadFull(ws.reader, maskAndPayload); err != nil {
+		return 0, nil, err
+	}
+	mask, payload := maskAndPayload[:4], maskAndPayload[4:]
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// Close closes the underlying connection
+func (ws *webSocket) Close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// DirectionSent marks transactions spending from an address
+	DirectionSent = "sent"
+	// DirectionReceived marks transactions paying to an address
+	DirectionReceived = "received"
+	// DirectionSelf marks transactions both spending from and paying to an address
+	DirectionSelf = "self"
+)
+
+// AddressTransaction is a confirmed transaction involving an address
+type AddressTransaction struct {
+	Transaction Transaction `json:"transaction"`
+	Direction   string      `json:"direction"`
+	BlockIndex  int         `json:"block_index"`
+	BlockHash   string      `json:"block_hash"`
+	Position    int         `json:"position"`
+	Timestamp   int64       `json:"timestamp"`
+}
+
+// AddressIndex is an optional BlockObserver mapping addresses to the transactions involving them
+type AddressIndex struct {
+	// entries holds the transactions of every address in chain order
+	entries map[string][]AddressTransaction
+	mu      sync.RWMutex
+}
+
+// NewAddressIndex returns an empty index, register it with Blockchain.AddObserver to fill it
+func NewAddressIndex() *AddressIndex {
+	return &AddressIndex{entries: make(map[string][]AddressTransaction)}
+}
+
+// BlockConnected indexes the transactions of a block appended to the chain
+func (x *AddressIndex) BlockConnected(block Block) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for position, transaction := range block.Transactions {
+		for address, direction := range involvedAddresses(transaction) {
+			x.entries[address] = append(x.entries[address], AddressTransaction{
+				Transaction: transaction,
+				Direction:   direction,
+				BlockIndex:  block.Index,
+				BlockHash:   block.Hash,
+				Position:    position,
+				Timestamp:   block.Timestamp,
+			})
+		}
+	}
+}
+
+// BlockDisconnected forgets the transactions of a block removed from the end of the chain
+func (x *AddressIndex) BlockDisconnected(block Block) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, transaction := range block.Transactions {
+		for address := range involvedAddresses(transaction) {
+			entries := x.entries[address]
+			if len(entries) <= 1 {
+				delete(x.entries, address)
+			} else {
+				x.entries[address] = entries[:len(entries)-1]
+			}
+		}
+	}
+}
+
+// Transactions returns up to limit transactions of address confirmed before the given block index and position,
+// newest first. A zero beforeBlock starts from the tip, an empty direction matches every transaction,
+// and sent or received also match the transactions of an address to itself. more tells if older ones are left.
+func (x *AddressIndex) Transactions(address, direction string, beforeBlock, beforePosition, limit int) (transactions []AddressTransaction, more bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	entries := x.entries[address]
+	end := len(entries)
+	if beforeBlock > 0 {
+		end = sort.Search(len(entries), func(i int) bool {
+			return entries[i].BlockIndex > beforeBlock || entries[i].BlockIndex == beforeBlock && entries[i].Position >= beforePosition
+		})
+	}
+
+	transactions = []AddressTransaction{}
+	for i := end - 1; i >= 0; i-- {
+		entry := entries[i]
+		if direction != "" && entry.Direction != direction && entry.Direction != DirectionSelf {
+			continue
+		}
+		if len(transactions) == limit {
+			return transactions, true
+		}
+		transactions = append(transactions, entry)
+	}
+	return transactions, false
+}
+
+// Involves tells if a transaction spends from or pays to address
+func (t Transaction) Involves(address string) bool {
+	_, ok := involvedAddresses(t)[address]
+	return ok
+}
+
+// involvedAddresses returns the addresses a transaction spends from or pays to, with the direction for each
+func involvedAddresses(transaction Transaction) map[string]string {
+	addresses := make(map[string]string)
+	involve := func(address, direction string) {
+		if address == "" {
+			return
+		}
+		if existing, ok := addresses[address]; ok && existing != direction {
+			direction = DirectionSelf
+		}
+		addresses[address] = direction
+	}
+
+	involve(transaction.Sender, DirectionSent)
+	involve(transaction.Recipient, DirectionReceived)
+	if transaction.Type == TxTypeDeploy {
+		involve(ContractAddress(transaction.ID), DirectionReceived)
+	}
+	for _, input := range transaction.Inputs {
+		if address, err := AddressFromPublicKey(input.PublicKey); err == nil {
+			involve(address, DirectionSent)
+		}
+	}
+	for _, output := range transaction.Outputs {
+		involve(output.Address, DirectionReceived)
+	}
+	return addresses
+}
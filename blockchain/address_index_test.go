@@ -0,0 +1,76 @@
+package blockchain_test
+
+import (
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestAddressIndex follows the transactions of addresses as blocks are connected and disconnected.
+func TestAddressIndex(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	for _, transaction := range []blockchain.Transaction{
+		{Sender: "Alice", Recipient: "Bob", Amount: 1},
+		{Sender: "Bob", Recipient: "Carol", Amount: 1},
+		{Sender: "Alice", Recipient: "Alice", Amount: 1, Nonce: 1},
+	} {
+		if _, err := bc.AddTransaction(&transaction); err != nil {
+			t.Fatal(err)
+		}
+		bc.NewBlock(bc.LastBlock().Hash)
+	}
+
+	// Blocks already in the chain are replayed to the index
+	index := blockchain.NewAddressIndex()
+	bc.AddObserver(index)
+	transaction := blockchain.Transaction{Sender: "Carol", Recipient: "Alice", Amount: 1}
+	if _, err := bc.AddTransaction(&transaction); err != nil {
+		t.Fatal(err)
+	}
+	last := bc.NewBlock(bc.LastBlock().Hash)
+
+	directions := func(transactions []blockchain.AddressTransaction) []string {
+		directions := []string{}
+		for _, transaction := range transactions {
+			directions = append(directions, transaction.Direction)
+		}
+		return directions
+	}
+	tests := []struct {
+		address, direction string
+		expected           []string
+	}{
+		{"Alice", "", []string{"received", "self", "sent"}},
+		{"Alice", blockchain.DirectionSent, []string{"self", "sent"}},
+		{"Alice", blockchain.DirectionReceived, []string{"received", "self"}},
+		{"Bob", "", []string{"sent", "received"}},
+		{"Dave", "", []string{}},
+	}
+	for _, test := range tests {
+		transactions, more := index.Transactions(test.address, test.direction, 0, 0, 10)
+		if got := directions(transactions); more || len(got) != len(test.expected) {
+			t.Errorf("expected %v for %s %s, got %v", test.expected, test.direction, test.address, got)
+		} else {
+			for i := range got {
+				if got[i] != test.expected[i] {
+					t.Errorf("expected %v for %s %s, got %v", test.expected, test.direction, test.address, got)
+					break
+				}
+			}
+		}
+	}
+
+	page, more := index.Transactions("Alice", "", 0, 0, 2)
+	if !more || len(page) != 2 || page[0].BlockIndex != last.Index {
+		t.Fatalf("expected the two newest transactions of Alice, got %+v", page)
+	}
+	rest, more := index.Transactions("Alice", "", page[1].BlockIndex, page[1].Position, 2)
+	if more || len(rest) != 1 || rest[0].Transaction.Recipient != "Bob" {
+		t.Errorf("expected the oldest transaction of Alice, got %+v", rest)
+	}
+
+	index.BlockDisconnected(last)
+	if transactions, _ := index.Transactions("Carol", blockchain.DirectionSent, 0, 0, 10); len(transactions) != 0 {
+		t.Errorf("expected the transactions of the disconnected block to be forgotten, got %+v", transactions)
+	}
+}
@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimals is the highest precision an asset can have, 10^18 still fits in an Amount
+const MaxDecimals = 18
+
+// ErrOverflow is returned when an amount doesn't fit in 64 bits
+var ErrOverflow = errors.New("amount overflow")
+
+// Amount is a fixed-point quantity of an asset, counted in its smallest unit.
+// An asset with 2 decimals represents 1.25 as the Amount 125.
+type Amount int64
+
+// Add returns a + b, or ErrOverflow if the result doesn't fit in an Amount
+func (a Amount) Add(b Amount) (Amount, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, fmt.Errorf("%w: %d + %d", ErrOverflow, a, b)
+	}
+	return a + b, nil
+}
+
+// Sub returns a - b, or ErrOverflow if the result doesn't fit in an Amount
+func (a Amount) Sub(b Amount) (Amount, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, fmt.Errorf("%w: %d - %d", ErrOverflow, a, b)
+	}
+	return a - b, nil
+}
+
+// Format renders the amount as a decimal number with the given precision
+func (a Amount) Format(decimals int) string {
+	if decimals <= 0 {
+		return strconv.FormatInt(int64(a), 10)
+	}
+
+	sign := ""
+	digits := strconv.FormatInt(int64(a), 10)
+	if a < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+}
+
+// ParseAmount reads a decimal number with at most the given precision, "1.25" with 2 decimals is 125
+func ParseAmount(value string, decimals int) (Amount, error) {
+	whole, fraction, _ := strings.Cut(value, ".")
+	if len(fraction) > decimals {
+		return 0, fmt.Errorf("%s has more than %d decimals", value, decimals)
+	}
+	if strings.TrimLeft(whole, "+-")+fraction == "" || strings.HasPrefix(fraction, "-") || strings.HasPrefix(fraction, "+") {
+		return 0, fmt.Errorf("invalid amount %s", value)
+	}
+
+	units, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", decimals-len(fraction)), 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %s", ErrOverflow, value)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s", value)
+	}
+	return Amount(units), nil
+}
+
+// sumAmounts adds amounts, failing with ErrOverflow instead of wrapping around
+func sumAmounts(amounts ...Amount) (Amount, error) {
+	var total Amount
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	// TxTypeTransfer is the type of transactions moving funds, the default
+	TxTypeTransfer = ""
+	// TxTypeAnchor is the type of transactions notarizing a document by its content hash
+	TxTypeAnchor = "anchor"
+	// MaxDataSize is the maximum size in bytes of the data of a transaction
+	MaxDataSize = 256
+)
+
+// Anchor tells which block notarized a document hash, and when
+type Anchor struct {
+	Hash          string `json:"hash"`
+	TransactionID string `json:"transaction_id"`
+	Sender        string `json:"sender"`
+	BlockIndex    int    `json:"block_index"`
+	BlockHash     string `json:"block_hash"`
+	Timestamp     int64  `json:"timestamp"`
+}
+
+// validateAnchor checks that an anchor transaction carries a SHA-256 content hash and moves no funds
+func validateAnchor(transaction Transaction) error {
+	if decoded, err := hex.DecodeString(transaction.AnchorHash); err != nil || len(decoded) != 32 || transaction.AnchorHash != hex.EncodeToString(decoded) {
+		return fmt.Errorf("%w: anchor hash must be a lowercase hex encoded SHA-256 hash", ErrInvalidTransaction)
+	}
+	if transaction.Recipient != "" || transaction.Amount != 0 {
+		return fmt.Errorf("%w: anchor transactions don't have a recipient nor an amount", ErrInvalidTransaction)
+	}
+	return nil
+}
+
+// Anchor returns the earliest confirmed anchor of a document hash
+func (bc *Blockchain) Anchor(hash string) (Anchor, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	anchors := bc.anchors[hash]
+	if len(anchors) == 0 {
+		return Anchor{}, false
+	}
+	return anchors[0], true
+}
+
+// indexAnchors records the anchor transactions of a block appended to the chain
+func (bc *Blockchain) indexAnchors(block Block) {
+	for _, transaction := range block.Transactions {
+		if transaction.Type == TxTypeAnchor {
+			bc.anchors[transaction.AnchorHash] = append(bc.anchors[transaction.AnchorHash], Anchor{
+				Hash:          transaction.AnchorHash,
+				TransactionID: transaction.ID,
+				Sender:        transaction.Sender,
+				BlockIndex:    block.Index,
+				BlockHash:     block.Hash,
+				Timestamp:     block.Timestamp,
+			})
+		}
+	}
+}
+
+// unindexAnchors forgets the anchor transactions of a block removed from the end of the chain
+func (bc *Blockchain) unindexAnchors(block Block) {
+	for _, transaction := range block.Transactions {
+		if transaction.Type == TxTypeAnchor {
+			anchors := bc.anchors[transaction.AnchorHash]
+			if len(anchors) <= 1 {
+				delete(bc.anchors, transaction.AnchorHash)
+			} else {
+				bc.anchors[transaction.AnchorHash] = anchors[:len(anchors)-1]
+			}
+		}
+	}
+}
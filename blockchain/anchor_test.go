@@ -0,0 +1,61 @@
+package blockchain_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestAnchor verifies that an anchored document hash can be found once its block is mined.
+func TestAnchor(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	digest := sha256.Sum256([]byte("contract.pdf"))
+	documentHash := hex.EncodeToString(digest[:])
+
+	anchor := blockchain.Transaction{Type: blockchain.TxTypeAnchor, Sender: "Notary", AnchorHash: documentHash, Data: "Signed lease"}
+	if _, err := bc.AddTransaction(&anchor); err != nil {
+		t.Fatalf("expected anchor transaction to be accepted, got %v", err)
+	}
+	if _, ok := bc.Anchor(documentHash); ok {
+		t.Error("expected pending anchor not to be found")
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	found, ok := bc.Anchor(documentHash)
+	if !ok {
+		t.Fatal("expected anchor to be found")
+	}
+	if found.TransactionID != anchor.ID || found.BlockIndex != block.Index || found.BlockHash != block.Hash || found.Timestamp != block.Timestamp {
+		t.Errorf("unexpected anchor %+v for block %d", found, block.Index)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+
+	// The data is covered by the block hash
+	bc.Chain[1].Transactions[0].Data = "Forged lease"
+	if bc.ValidChain(bc.Chain) {
+		t.Error("expected chain with altered data to be invalid")
+	}
+}
+
+// TestInvalidPayloads checks the bounds of data and anchor transactions.
+func TestInvalidPayloads(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	invalid := map[string]blockchain.Transaction{
+		"oversized data":    {Sender: "Alice", Recipient: "Bob", Data: strings.Repeat("x", blockchain.MaxDataSize+1)},
+		"malformed hash":    {Type: blockchain.TxTypeAnchor, Sender: "Alice", AnchorHash: "not-a-hash"},
+		"anchor with funds": {Type: blockchain.TxTypeAnchor, Sender: "Alice", Recipient: "Bob", Amount: 1, AnchorHash: strings.Repeat("ab", 32)},
+		"unknown type":      {Type: "poem", Sender: "Alice"},
+	}
+	for name, transaction := range invalid {
+		if _, err := bc.AddTransaction(&transaction); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+			t.Errorf("%s: expected ErrInvalidTransaction, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+const (
+	// TxTypeTokenCreate is the type of transactions issuing a new asset, its whole supply goes to the sender
+	TxTypeTokenCreate = "token_create"
+	// TxTypeTokenMint is the type of transactions increasing the supply of an asset, credited to the
+	// recipient or to the issuer when there is none. Only the issuer can mint.
+	TxTypeTokenMint = "token_mint"
+	// TxTypeTokenBurn is the type of transactions destroying part of the balance of the issuer
+	TxTypeTokenBurn = "token_burn"
+)
+
+var (
+	// ErrUnknownAsset is returned for transactions moving an asset that doesn't exist
+	ErrUnknownAsset = errors.New("unknown asset")
+	// ErrInsufficientFunds is returned when a sender doesn't hold the amount it moves
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrNotIssuer is returned when someone else than the issuer of an asset mints or burns it
+	ErrNotIssuer = errors.New("sender isn't the issuer of the asset")
+
+	symbolPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+)
+
+// Asset is a fungible asset tracked by the ledger. The native asset is allocated in the genesis block,
+// the others are issued by token creation transactions.
+type Asset struct {
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+	Supply   Amount `json:"supply"`
+	Issuer   string `json:"issuer,omitempty"`
+}
+
+// TokenSpec describes the asset issued by a token creation transaction
+type TokenSpec struct {
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+	Supply   Amount `json:"supply"`
+}
+
+// AssetBalance is the amount of an asset held by an address
+type AssetBalance struct {
+	Symbol    string `json:"symbol"`
+	Amount    Amount `json:"amount"`
+	Formatted string `json:"formatted"`
+}
+
+// validateSymbol checks that symbol is 2 to 10 uppercase letters or digits, starting with a letter
+func validateSymbol(symbol string) error {
+	if !symbolPattern.MatchString(symbol) {
+		return fmt.Errorf("%w: symbol %q must be 2 to 10 uppercase letters or digits, starting with a letter", ErrInvalidTransaction, symbol)
+	}
+	return nil
+}
+
+// validateTokenCreation checks the spec of a token creation transaction
+func validateTokenCreation(transaction Transaction) error {
+	token := transaction.Token
+	if token == nil {
+		return fmt.Errorf("%w: token creation needs a token spec", ErrInvalidTransaction)
+	}
+	if transaction.Recipient != "" || transaction.Amount != 0 || transaction.Asset != "" {
+		return fmt.Errorf("%w: token creation doesn't have a recipient, an amount nor an asset", ErrInvalidTransaction)
+	}
+	if err := validateSymbol(token.Symbol); err != nil {
+		return err
+	}
+	if token.Decimals < 0 || token.Decimals > MaxDecimals {
+		return fmt.Errorf("%w: token decimals must be between 0 and %d, got %d", ErrInvalidTransaction, MaxDecimals, token.Decimals)
+	}
+	if token.Supply <= 0 {
+		return fmt.Errorf("%w: token supply must be positive, got %d", ErrInvalidTransaction, token.Supply)
+	}
+	return nil
+}
+
+// validateSupplyChange checks a mint or burn transaction
+func validateSupplyChange(transaction Transaction) error {
+	if err := validateSymbol(transaction.Asset); err != nil {
+		return err
+	}
+	if transaction.Amount <= 0 {
+		return fmt.Errorf("%w: %s amount must be positive, got %d", ErrInvalidTransaction, transaction.Type, transaction.Amount)
+	}
+	if transaction.Type == TxTypeTokenBurn && transaction.Recipient != "" {
+		return fmt.Errorf("%w: burns don't have a recipient", ErrInvalidTransaction)
+	}
+	return nil
+}
+
+// assetOf returns the symbol of the asset a transaction moves
+func (s *State) assetOf(transaction Transaction) string {
+	if transaction.Asset == "" {
+		return s.genesis.NativeSymbol
+	}
+	return transaction.Asset
+}
+
+// transfer moves amount of an asset from sender to recipient. Balances of the native asset may
+// go negative unless the consensus rules enforce them, balances of issued tokens never can.
+func (s *State) transfer(symbol, sender, recipient string, amount Amount) error {
+	if _, ok := s.assets[symbol]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownAsset, symbol)
+	}
+
+	senderBalance, err := s.Balance(symbol, sender).Sub(amount)
+	if err != nil {
+		return err
+	}
+	if senderBalance < 0 && (symbol != s.genesis.NativeSymbol || s.genesis.Consensus.EnforceBalances) {
+		return fmt.Errorf("%w: %s holds %d %s, can't send %d", ErrInsufficientFunds, sender, s.Balance(symbol, sender), symbol, amount)
+	}
+	s.setBalance(symbol, sender, senderBalance)
+
+	recipientBalance, err := s.Balance(symbol, recipient).Add(amount)
+	if err != nil {
+		return err
+	}
+	s.setBalance(symbol, recipient, recipientBalance)
+	return nil
+}
+
+// createToken issues a new asset and credits its supply to the issuer
+func (s *State) createToken(issuer string, token TokenSpec) error {
+	if _, ok := s.assets[token.Symbol]; ok {
+		return fmt.Errorf("%w: asset %s already exists", ErrInvalidTransaction, token.Symbol)
+	}
+	s.setAsset(Asset{Symbol: token.Symbol, Decimals: token.Decimals, Supply: token.Supply, Issuer: issuer})
+	s.setBalance(token.Symbol, issuer, token.Supply)
+	return nil
+}
+
+// mint increases the supply of an asset owned by issuer and credits it to recipient
+func (s *State) mint(issuer, symbol, recipient string, amount Amount) error {
+	asset, err := s.issuedAsset(issuer, symbol)
+	if err != nil {
+		return err
+	}
+	if asset.Supply, err = asset.Supply.Add(amount); err != nil {
+		return err
+	}
+	if recipient == "" {
+		recipient = issuer
+	}
+	balance, err := s.Balance(symbol, recipient).Add(amount)
+	if err != nil {
+		return err
+	}
+	s.setAsset(asset)
+	s.setBalance(symbol, recipient, balance)
+	return nil
+}
+
+// burn destroys amount of an asset held by its issuer
+func (s *State) burn(issuer, symbol string, amount Amount) error {
+	asset, err := s.issuedAsset(issuer, symbol)
+	if err != nil {
+		return err
+	}
+	balance := s.Balance(symbol, issuer)
+	if balance < amount {
+		return fmt.Errorf("%w: %s holds %d %s, can't burn %d", ErrInsufficientFunds, issuer, balance, symbol, amount)
+	}
+	asset.Supply -= amount // The supply is at least the balance of the issuer
+	s.setAsset(asset)
+	s.setBalance(symbol, issuer, balance-amount)
+	return nil
+}
+
+// issuedAsset returns an asset, failing unless issuer created it
+func (s *State) issuedAsset(issuer, symbol string) (Asset, error) {
+	asset, ok := s.assets[symbol]
+	if !ok {
+		return Asset{}, fmt.Errorf("%w: %s", ErrUnknownAsset, symbol)
+	}
+	if asset.Issuer == "" || asset.Issuer != issuer {
+		return Asset{}, fmt.Errorf("%w: %s", ErrNotIssuer, symbol)
+	}
+	return asset, nil
+}
+
+// Balance returns the amount of an asset held by address
+func (s *State) Balance(symbol, address string) Amount {
+	return s.balances[symbol][address]
+}
+
+// Balances returns every asset held by address, ordered by symbol. In utxo ledger
+// mode the native balance is the sum of the unspent outputs of the address.
+func (s *State) Balances(address string) []AssetBalance {
+	balances := []AssetBalance{}
+	if s.genesis.Consensus.LedgerMode == LedgerModeUTXO {
+		var total Amount
+		for _, output := range s.UnspentOutputs(address) {
+			total += output.Amount // The UTXO set never holds more than the genesis allocations
+		}
+		return append(balances, AssetBalance{Symbol: s.genesis.NativeSymbol, Amount: total, Formatted: total.Format(s.genesis.NativeDecimals)})
+	}
+
+	for symbol, holders := range s.balances {
+		if amount, ok := holders[address]; ok && amount != 0 {
+			balances = append(balances, AssetBalance{Symbol: symbol, Amount: amount, Formatted: amount.Format(s.assets[symbol].Decimals)})
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Symbol < balances[j].Symbol })
+	return balances
+}
+
+// Assets returns every asset of the ledger, ordered by symbol
+func (s *State) Assets() []Asset {
+	assets := []Asset{}
+	for _, asset := range s.assets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Symbol < assets[j].Symbol })
+	return assets
+}
+
+// Balances returns every asset held by address, ordered by symbol
+func (bc *Blockchain) Balances(address string) []AssetBalance {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.state.Balances(address)
+}
+
+// Balance returns the amount of an asset held by address, or ErrUnknownAsset
+func (bc *Blockchain) Balance(symbol, address string) (AssetBalance, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	asset, ok := bc.state.assets[symbol]
+	if !ok {
+		return AssetBalance{}, fmt.Errorf("%w: %s", ErrUnknownAsset, symbol)
+	}
+	for _, balance := range bc.state.Balances(address) {
+		if balance.Symbol == symbol {
+			return balance, nil
+		}
+	}
+	return AssetBalance{Symbol: symbol, Formatted: Amount(0).Format(asset.Decimals)}, nil
+}
+
+// Assets returns every asset of the ledger, ordered by symbol
+func (bc *Blockchain) Assets() []Asset {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.state.Assets()
+}
+
+// ParseAmount reads a decimal amount like "1.25" with the precision of an asset, the native one if symbol is empty
+func (bc *Blockchain) ParseAmount(symbol, value string) (Amount, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	symbol = bc.state.assetOf(Transaction{Asset: symbol})
+	asset, ok := bc.state.assets[symbol]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownAsset, symbol)
+	}
+	return ParseAmount(value, asset.Decimals)
+}
@@ -0,0 +1,193 @@
+package blockchain_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestAmount checks parsing, formatting and overflow of fixed-point amounts.
+func TestAmount(t *testing.T) {
+	parsed := map[string]blockchain.Amount{"1.25": 125, "3": 300, ".5": 50, "-0.01": -1}
+	for value, expected := range parsed {
+		if amount, err := blockchain.ParseAmount(value, 2); err != nil || amount != expected {
+			t.Errorf("ParseAmount(%q) = %d, %v, expected %d", value, amount, err, expected)
+		}
+	}
+	for _, value := range []string{"", "1.234", "1.-5", "abc"} {
+		if _, err := blockchain.ParseAmount(value, 2); err == nil {
+			t.Errorf("expected ParseAmount(%q) to fail", value)
+		}
+	}
+	if _, err := blockchain.ParseAmount("92233720368547758.08", 2); !errors.Is(err, blockchain.ErrOverflow) {
+		t.Errorf("expected ErrOverflow, got %v", err)
+	}
+
+	formatted := map[blockchain.Amount]string{125: "1.25", 5: "0.05", -150: "-1.50", 0: "0.00"}
+	for amount, expected := range formatted {
+		if actual := amount.Format(2); actual != expected {
+			t.Errorf("Format(%d) = %s, expected %s", amount, actual, expected)
+		}
+	}
+
+	if _, err := blockchain.Amount(math.MaxInt64).Add(1); !errors.Is(err, blockchain.ErrOverflow) {
+		t.Errorf("expected ErrOverflow, got %v", err)
+	}
+	if _, err := blockchain.Amount(math.MinInt64).Sub(1); !errors.Is(err, blockchain.ErrOverflow) {
+		t.Errorf("expected ErrOverflow, got %v", err)
+	}
+}
+
+// TestTokens verifies that issued tokens can be transferred but never overspent.
+func TestTokens(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	create := blockchain.Transaction{Type: blockchain.TxTypeTokenCreate, Sender: "Alice", Token: &blockchain.TokenSpec{Symbol: "GOLD", Decimals: 2, Supply: 10000}}
+	if _, err := bc.AddTransaction(&create); err != nil {
+		t.Fatalf("expected token creation to be accepted, got %v", err)
+	}
+	transfer := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 2550, Asset: "GOLD", Nonce: 1}
+	if _, err := bc.AddTransaction(&transfer); err != nil {
+		t.Fatalf("expected pending token transfer to be accepted, got %v", err)
+	}
+
+	rejected := map[string]struct {
+		transaction blockchain.Transaction
+		err         error
+	}{
+		"overspend":       {blockchain.Transaction{Sender: "Bob", Recipient: "Carol", Amount: 2551, Asset: "GOLD"}, blockchain.ErrInsufficientFunds},
+		"unknown asset":   {blockchain.Transaction{Sender: "Bob", Recipient: "Carol", Amount: 1, Asset: "SILVER"}, blockchain.ErrUnknownAsset},
+		"negative amount": {blockchain.Transaction{Sender: "Bob", Recipient: "Carol", Amount: -1}, blockchain.ErrInvalidTransaction},
+		"duplicate token": {blockchain.Transaction{Type: blockchain.TxTypeTokenCreate, Sender: "Bob", Token: &blockchain.TokenSpec{Symbol: "GOLD", Supply: 1}}, blockchain.ErrInvalidTransaction},
+		"invalid symbol":  {blockchain.Transaction{Type: blockchain.TxTypeTokenCreate, Sender: "Bob", Token: &blockchain.TokenSpec{Symbol: "gold", Supply: 1}}, blockchain.ErrInvalidTransaction},
+	}
+	for name, test := range rejected {
+		if _, err := bc.AddTransaction(&test.transaction); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", name, test.err, err)
+		}
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	expected := []blockchain.AssetBalance{{Symbol: "GOLD", Amount: 2550, Formatted: "25.50"}}
+	if balances := bc.Balances("Bob"); len(balances) != 1 || balances[0] != expected[0] {
+		t.Errorf("expected Bob to hold %+v, got %+v", expected, balances)
+	}
+	if assets := bc.Assets(); len(assets) != 2 || assets[1].Symbol != "GOLD" || assets[1].Issuer != "Alice" {
+		t.Errorf("expected the native asset and GOLD, got %+v", assets)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+}
+
+// TestEnforceBalances checks that native transfers can only overdraw when balances aren't enforced.
+func TestEnforceBalances(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.NativeDecimals = 2
+	genesis.Alloc = map[string]blockchain.Amount{"Alice": 100}
+	genesis.Consensus.EnforceBalances = true
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 101}); !errors.Is(err, blockchain.ErrInsufficientFunds) {
+		t.Errorf("expected ErrInsufficientFunds, got %v", err)
+	}
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 100}); err != nil {
+		t.Errorf("expected transfer of the whole balance to be accepted, got %v", err)
+	}
+
+	genesis.Alloc = map[string]blockchain.Amount{"Alice": math.MaxInt64, "Bob": 1}
+	if err := genesis.Validate(); !errors.Is(err, blockchain.ErrOverflow) {
+		t.Errorf("expected overflowing allocations to be rejected, got %v", err)
+	}
+}
+
+// TestNativeOverdraft checks that by default native balances go negative, without overflowing, and tokens don't.
+func TestNativeOverdraft(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Alloc = map[string]blockchain.Amount{"Bob": math.MaxInt64}
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Alice", Recipient: "Carol", Amount: 10}); err != nil {
+		t.Errorf("expected a native overdraft to be accepted by default, got %v", err)
+	}
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 1, Nonce: 1}); !errors.Is(err, blockchain.ErrOverflow) {
+		t.Errorf("expected a transfer overflowing the recipient to be rejected, got %v", err)
+	}
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Alice", Recipient: "Carol", Amount: -1, Nonce: 1}); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+		t.Errorf("expected a negative amount to be rejected, got %v", err)
+	}
+	token := blockchain.Transaction{Type: blockchain.TxTypeTokenCreate, Sender: "Dave", Token: &blockchain.TokenSpec{Symbol: "GOLD", Supply: 5}}
+	if _, err := bc.AddTransaction(&token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Dave", Recipient: "Carol", Asset: "GOLD", Amount: 6, Nonce: 1}); !errors.Is(err, blockchain.ErrInsufficientFunds) {
+		t.Errorf("expected a token overdraft to be rejected, got %v", err)
+	}
+	deploy := blockchain.Transaction{Type: blockchain.TxTypeDeploy, Sender: "Erin", Code: "00", GasLimit: 100}
+	if _, err := bc.AddTransaction(&deploy); err != nil {
+		t.Errorf("expected gas to be paid on credit by default, got %v", err)
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	if balance, _ := bc.Balance(blockchain.DefaultNativeSymbol, "Alice"); balance.Amount != -10 {
+		t.Errorf("expected Alice to owe 10, got %d", balance.Amount)
+	}
+	if balance, _ := bc.Balance(blockchain.DefaultNativeSymbol, "Erin"); balance.Amount >= 0 {
+		t.Errorf("expected Erin to owe the gas of the deployment, got %d", balance.Amount)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain with an overdraft to be valid")
+	}
+}
+
+// TestMintAndBurn verifies that only the issuer changes the supply of a token.
+func TestMintAndBurn(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	transactions := []blockchain.Transaction{
+		{Type: blockchain.TxTypeTokenCreate, Sender: "Alice", Token: &blockchain.TokenSpec{Symbol: "GOLD", Supply: 100}},
+		{Type: blockchain.TxTypeTokenMint, Sender: "Alice", Recipient: "Bob", Asset: "GOLD", Amount: 50, Nonce: 1},
+		{Type: blockchain.TxTypeTokenBurn, Sender: "Alice", Asset: "GOLD", Amount: 30, Nonce: 2},
+	}
+	for i := range transactions {
+		if _, err := bc.AddTransaction(&transactions[i]); err != nil {
+			t.Fatalf("expected %s to be accepted, got %v", transactions[i].Type, err)
+		}
+	}
+
+	rejected := map[string]struct {
+		transaction blockchain.Transaction
+		err         error
+	}{
+		"mint by holder":   {blockchain.Transaction{Type: blockchain.TxTypeTokenMint, Sender: "Bob", Asset: "GOLD", Amount: 1}, blockchain.ErrNotIssuer},
+		"mint native":      {blockchain.Transaction{Type: blockchain.TxTypeTokenMint, Sender: "Bob", Asset: blockchain.DefaultNativeSymbol, Amount: 1}, blockchain.ErrNotIssuer},
+		"burn over supply": {blockchain.Transaction{Type: blockchain.TxTypeTokenBurn, Sender: "Alice", Asset: "GOLD", Amount: 71, Nonce: 3}, blockchain.ErrInsufficientFunds},
+		"zero mint":        {blockchain.Transaction{Type: blockchain.TxTypeTokenMint, Sender: "Alice", Asset: "GOLD", Nonce: 3}, blockchain.ErrInvalidTransaction},
+	}
+	for name, test := range rejected {
+		if _, err := bc.AddTransaction(&test.transaction); !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", name, test.err, err)
+		}
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	if assets := bc.Assets(); assets[1].Supply != 120 {
+		t.Errorf("expected a supply of 120 GOLD, got %d", assets[1].Supply)
+	}
+	for address, expected := range map[string]blockchain.Amount{"Alice": 70, "Bob": 50} {
+		if balance, err := bc.Balance("GOLD", address); err != nil || balance.Amount != expected {
+			t.Errorf("expected %s to hold %d GOLD, got %d (%v)", address, expected, balance.Amount, err)
+		}
+	}
+	if _, err := bc.Balance("SILVER", "Alice"); !errors.Is(err, blockchain.ErrUnknownAsset) {
+		t.Errorf("expected ErrUnknownAsset, got %v", err)
+	}
+}
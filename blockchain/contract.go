@@ -0,0 +1,281 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+
+	"diy.blockchain.org/m/blockchain/vm"
+	"diy.blockchain.org/m/blockchain/wasm"
+)
+
+const (
+	// TxTypeDeploy is the type of transactions creating a contract from the bytecode in Code,
+	// either a WebAssembly module or code for the stack VM
+	TxTypeDeploy = "deploy"
+	// TxTypeCall is the type of transactions running the contract at Recipient with Args,
+	// after sending it Amount of the native asset
+	TxTypeCall = "call"
+
+	// ContractAddressPrefix starts every contract address
+	ContractAddressPrefix = "ct"
+	// MaxCodeSize is the size limit of the bytecode of a contract
+	MaxCodeSize = 4096
+	// DeployGasPerByte is the gas charged for every byte of deployed bytecode
+	DeployGasPerByte = 10
+
+	ReceiptStatusSuccess = "success"
+	ReceiptStatusFailed  = "failed"
+)
+
+// ErrUnknownContract is returned for calls to an address without a contract
+var ErrUnknownContract = errors.New("unknown contract")
+
+// Contract is deployed bytecode, Storage is only filled in the copies returned by Blockchain.Contract
+type Contract struct {
+	Address string          `json:"address"`
+	Creator string          `json:"creator"`
+	Code    string          `json:"code"`
+	Storage map[int64]int64 `json:"storage,omitempty"`
+}
+
+// Receipt is the outcome of a contract transaction. A failed execution is still included in the
+// block, it consumes the nonce of the sender but leaves the contract storage untouched.
+type Receipt struct {
+	TransactionID string `json:"transaction_id"`
+	Status        string `json:"status"`
+	GasUsed       uint64 `json:"gas_used"`
+	// Fee is the amount of the native asset the sender paid for the gas used
+	Fee             Amount `json:"fee"`
+	ContractAddress string `json:"contract_address,omitempty"`
+	Return          int64  `json:"return,omitempty"`
+	Logs            []Log  `json:"logs,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Log is an event emitted by a contract
+type Log struct {
+	Contract string `json:"contract"`
+	Topic    int64  `json:"topic"`
+	Value    int64  `json:"value"`
+}
+
+// ContractAddress returns the address of the contract created by a deploy transaction
+func ContractAddress(transactionID string) string {
+	hash := sha256.Sum256([]byte(transactionID))
+	return ContractAddressPrefix + hex.EncodeToString(hash[:20])
+}
+
+// validateContractTransaction runs the checks of deploy and call transactions that don't depend on the state of the chain
+func validateContractTransaction(transaction Transaction, genesis *Genesis) error {
+	if transaction.Asset != "" || (transaction.Type == TxTypeDeploy && transaction.Amount != 0) {
+		return fmt.Errorf("%w: contracts only receive the native asset through calls", ErrInvalidTransaction)
+	}
+	if transaction.GasLimit == 0 {
+		return fmt.Errorf("%w: contract transactions need a gas limit", ErrInvalidTransaction)
+	}
+	if limit := genesis.Consensus.MaxTransactionGas; transaction.GasLimit > limit {
+		return fmt.Errorf("%w: gas limit %d is over the %d allowed per transaction", ErrInvalidTransaction, transaction.GasLimit, limit)
+	}
+
+	if transaction.Type == TxTypeCall {
+		if transaction.Code != "" {
+			return fmt.Errorf("%w: calls don't carry code", ErrInvalidTransaction)
+		}
+		return nil
+	}
+	if transaction.Recipient != "" || len(transaction.Args) > 0 {
+		return fmt.Errorf("%w: deployments have no recipient nor arguments", ErrInvalidTransaction)
+	}
+	code, err := hex.DecodeString(transaction.Code)
+	if err != nil || len(code) == 0 {
+		return fmt.Errorf("%w: code must be hex encoded bytecode", ErrInvalidTransaction)
+	}
+	if len(code) > MaxCodeSize {
+		return fmt.Errorf("%w: code is %d bytes, the limit is %d", ErrInvalidTransaction, len(code), MaxCodeSize)
+	}
+	if wasm.IsModule(code) {
+		return validateWASMContract(code)
+	}
+	if _, err := vm.Validate(code); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+	}
+	return nil
+}
+
+// contractStorage exposes the storage of one contract to the VM, recording every write in the journal
+type contractStorage struct {
+	state   *State
+	address string
+}
+
+func (c contractStorage) Load(key int64) int64 {
+	return c.state.storage[c.address][key]
+}
+
+func (c contractStorage) Store(key, value int64) {
+	c.state.setStorage(c.address, key, value)
+}
+
+// applyContractTransaction runs a deploy or call transaction. Its sender pays for the whole gas limit before
+// execution starts, which can't without the funds for it, and is refunded the gas left afterwards.
+func (s *State) applyContractTransaction(transaction Transaction) (*Receipt, error) {
+	price := s.genesis.Consensus.GasPrice
+	if err := s.payGas(transaction.Sender, Amount(transaction.GasLimit)*price); err != nil {
+		return nil, err
+	}
+
+	var receipt *Receipt
+	if transaction.Type == TxTypeDeploy {
+		receipt = s.deploy(transaction)
+	} else {
+		var err error
+		if receipt, err = s.call(transaction); err != nil {
+			return nil, err
+		}
+	}
+	receipt.Fee = Amount(receipt.GasUsed) * price
+	return receipt, s.payGas(transaction.Sender, receipt.Fee-Amount(transaction.GasLimit)*price)
+}
+
+// payGas burns fee of the native asset from the balance of sender, a negative fee is a refund. Validate made sure
+// the price of any gas limit fits in an Amount.
+func (s *State) payGas(sender string, fee Amount) error {
+	native := s.genesis.NativeSymbol
+	balance, err := s.Balance(native, sender).Sub(fee)
+	if err != nil {
+		return err
+	}
+	if fee > 0 && balance < 0 && s.genesis.Consensus.EnforceBalances {
+		return fmt.Errorf("%w: %s holds %d %s, can't pay %d for gas", ErrInsufficientFunds, sender, s.Balance(native, sender), native, fee)
+	}
+	asset := s.assets[native]
+	if asset.Supply, err = asset.Supply.Sub(fee); err != nil {
+		return err
+	}
+	s.setAsset(asset)
+	s.setBalance(native, sender, balance)
+	return nil
+}
+
+// blockGas returns the sum of the gas limits of transactions, or math.MaxUint64 if it overflows
+func blockGas(transactions []Transaction) uint64 {
+	var total, carry uint64
+	for _, transaction := range transactions {
+		if total, carry = bits.Add64(total, transaction.GasLimit, 0); carry != 0 {
+			return math.MaxUint64
+		}
+	}
+	return total
+}
+
+// deploy creates the contract of a deploy transaction, charging gas for the size of its code
+func (s *State) deploy(transaction Transaction) *Receipt {
+	receipt := &Receipt{TransactionID: transaction.ID, Status: ReceiptStatusSuccess}
+	gas := uint64(len(transaction.Code)/2) * DeployGasPerByte
+	if gas > transaction.GasLimit {
+		receipt.Status, receipt.GasUsed, receipt.Error = ReceiptStatusFailed, transaction.GasLimit, vm.ErrOutOfGas.Error()
+		return receipt
+	}
+
+	receipt.GasUsed = gas
+	receipt.ContractAddress = ContractAddress(transaction.ID)
+	s.setContract(Contract{Address: receipt.ContractAddress, Creator: transaction.Sender, Code: transaction.Code})
+	return receipt
+}
+
+// call sends the amount of a transaction to a contract and runs it. A failed execution reverts
+// both the payment and the changes made by the contract.
+func (s *State) call(transaction Transaction) (*Receipt, error) {
+	contract, ok := s.contracts[transaction.Recipient]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContract, transaction.Recipient)
+	}
+	code, _ := hex.DecodeString(contract.Code) // Validated when it was deployed
+
+	snapshot := s.snapshot()
+	if transaction.Amount > 0 {
+		if err := s.transfer(s.genesis.NativeSymbol, transaction.Sender, contract.Address, transaction.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	receipt := &Receipt{TransactionID: transaction.ID, Status: ReceiptStatusSuccess}
+	var err error
+	if wasm.IsModule(code) {
+		err = s.runWASM(code, contract, transaction, receipt)
+	} else {
+		err = s.runVM(code, contract, transaction, receipt)
+	}
+	if err != nil {
+		s.revert(snapshot)
+		receipt.Status, receipt.Error = ReceiptStatusFailed, err.Error()
+		receipt.Return, receipt.Logs = 0, nil
+	}
+	return receipt, nil
+}
+
+// runVM runs a contract written for the stack VM
+func (s *State) runVM(code []byte, contract Contract, transaction Transaction, receipt *Receipt) error {
+	result, err := vm.Execute(code, vm.Context{
+		Args:     transaction.Args,
+		Storage:  contractStorage{state: s, address: contract.Address},
+		GasLimit: transaction.GasLimit,
+	})
+	receipt.GasUsed = result.GasUsed
+	receipt.Return = result.Return
+	for _, log := range result.Logs {
+		receipt.Logs = append(receipt.Logs, Log{Contract: contract.Address, Topic: log.Topic, Value: log.Value})
+	}
+	return err
+}
+
+func (s *State) setContract(contract Contract) {
+	s.journal = append(s.journal, func() { delete(s.contracts, contract.Address) })
+	s.contracts[contract.Address] = contract
+}
+
+func (s *State) setStorage(address string, key, value int64) {
+	slots, ok := s.storage[address]
+	if !ok {
+		slots = make(map[int64]int64)
+		s.storage[address] = slots
+	}
+	previous, existed := slots[key]
+	s.journal = append(s.journal, func() {
+		if existed {
+			slots[key] = previous
+		} else {
+			delete(slots, key)
+		}
+	})
+	slots[key] = value
+}
+
+// Contract returns a deployed contract with its storage
+func (bc *Blockchain) Contract(address string) (Contract, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	contract, ok := bc.state.contracts[address]
+	if !ok {
+		return Contract{}, false
+	}
+	contract.Storage = make(map[int64]int64, len(bc.state.storage[address]))
+	for key, value := range bc.state.storage[address] {
+		contract.Storage[key] = value
+	}
+	return contract, true
+}
+
+// receiptsJSON encodes receipts, treating a block without receipts like an empty list
+func receiptsJSON(receipts []Receipt) []byte {
+	if receipts == nil {
+		receipts = []Receipt{}
+	}
+	encoded, _ := json.Marshal(receipts) // Receipts are strings, numbers and slices of them
+	return encoded
+}
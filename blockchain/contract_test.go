@@ -0,0 +1,247 @@
+package blockchain_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+	"diy.blockchain.org/m/blockchain/vm"
+)
+
+// counterCode adds its first argument to storage slot 0, logs the new value under topic 1 and
+// returns it. It reverts when the argument is negative.
+const counterCode = `
+	PUSH 0 ARG PUSH 0 LT PUSH 74 JUMPI
+	PUSH 0 ARG PUSH 0 SLOAD ADD
+	DUP PUSH 0 SSTORE
+	DUP PUSH 1 LOG
+	RETURN
+	REVERT`
+
+func deployCounter(t *testing.T, bc *blockchain.Blockchain) blockchain.Transaction {
+	t.Helper()
+	code, err := vm.Assemble(counterCode)
+	if err != nil {
+		t.Fatalf("failed to assemble counter: %v", err)
+	}
+	deploy := blockchain.Transaction{Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: hex.EncodeToString(code), GasLimit: 10000}
+	if _, err := bc.AddTransaction(&deploy); err != nil {
+		t.Fatalf("expected deployment to be accepted, got %v", err)
+	}
+	return deploy
+}
+
+// TestContract deploys a counter and calls it, checking storage, receipts and logs.
+func TestContract(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	deploy := deployCounter(t, bc)
+	address := blockchain.ContractAddress(deploy.ID)
+
+	calls := []blockchain.Transaction{
+		{Type: blockchain.TxTypeCall, Sender: "Bob", Recipient: address, Args: []int64{5}, GasLimit: 1000},
+		{Type: blockchain.TxTypeCall, Sender: "Bob", Recipient: address, Args: []int64{-1}, GasLimit: 1000, Nonce: 1},
+		{Type: blockchain.TxTypeCall, Sender: "Bob", Recipient: address, Args: []int64{2}, GasLimit: 10, Nonce: 2},
+	}
+	for i := range calls {
+		if _, err := bc.AddTransaction(&calls[i]); err != nil {
+			t.Fatalf("expected call %d to be accepted, got %v", i, err)
+		}
+	}
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Type: blockchain.TxTypeCall, Sender: "Bob", Recipient: "ct00", GasLimit: 1000, Nonce: 3}); !errors.Is(err, blockchain.ErrUnknownContract) {
+		t.Errorf("expected ErrUnknownContract, got %v", err)
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	if len(block.Transactions) != 4 || len(block.Receipts) != 4 {
+		t.Fatalf("expected 4 transactions with receipts, got %d and %d", len(block.Transactions), len(block.Receipts))
+	}
+	if receipt := block.Receipts[0]; receipt.Status != blockchain.ReceiptStatusSuccess || receipt.ContractAddress != address {
+		t.Errorf("unexpected deployment receipt %+v", receipt)
+	}
+	if receipt := block.Receipts[1]; receipt.Status != blockchain.ReceiptStatusSuccess || receipt.Return != 5 ||
+		len(receipt.Logs) != 1 || receipt.Logs[0] != (blockchain.Log{Contract: address, Topic: 1, Value: 5}) {
+		t.Errorf("unexpected call receipt %+v", receipt)
+	}
+	for _, receipt := range block.Receipts[2:] {
+		if receipt.Status != blockchain.ReceiptStatusFailed || receipt.Error == "" {
+			t.Errorf("expected failed receipt, got %+v", receipt)
+		}
+	}
+	if block.Receipts[2].Error != vm.ErrReverted.Error() {
+		t.Errorf("expected the negative increment to revert, got %s", block.Receipts[2].Error)
+	}
+	if block.Receipts[3].GasUsed != 10 {
+		t.Errorf("expected the call running out of gas to use its whole limit, got %d", block.Receipts[3].GasUsed)
+	}
+
+	contract, ok := bc.Contract(address)
+	if !ok || contract.Creator != "Alice" || contract.Storage[0] != 5 {
+		t.Errorf("expected failed calls to leave the counter at 5, got %+v", contract)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+
+	// Receipts are re-executed and covered by the block hash
+	bc.Chain[1].Receipts[1].Return = 6
+	bc.Chain[1].Hash = bc.Hash(bc.Chain[1])
+	if bc.ValidChain(bc.Chain) {
+		t.Error("expected chain with a forged receipt to be invalid")
+	}
+}
+
+// TestInvalidContractTransactions checks the stateless rules of contract transactions.
+func TestInvalidContractTransactions(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Consensus.MaxTransactionGas = 5000
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]blockchain.Transaction{
+		"no gas limit":      {Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: "00"},
+		"over gas cap":      {Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: "00", GasLimit: 5001},
+		"malformed code":    {Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: "ff", GasLimit: 100},
+		"not hex":           {Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: "zz", GasLimit: 100},
+		"call with tokens":  {Type: blockchain.TxTypeCall, Sender: "Alice", Recipient: "ct00", Amount: 1, Asset: "GOLD", GasLimit: 100},
+		"deploy with funds": {Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: "00", Amount: 1, GasLimit: 100},
+		"transfer with gas": {Sender: "Alice", Recipient: "Bob", GasLimit: 100},
+	}
+	for name, transaction := range invalid {
+		if _, err := bc.AddTransaction(&transaction); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+			t.Errorf("%s: expected ErrInvalidTransaction, got %v", name, err)
+		}
+	}
+}
+
+// TestContractGas checks that gas is capped per transaction and per block, and paid by the sender.
+func TestContractGas(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Alloc = map[string]blockchain.Amount{"Alice": 10000}
+	genesis.Consensus.EnforceBalances = true
+	genesis.Consensus.MaxTransactionGas = 3000
+	genesis.Consensus.MaxBlockGas = 6000
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis, blockchain.WithDifficulty(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop, err := vm.Assemble("PUSH 0 JUMP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deploy := blockchain.Transaction{Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: hex.EncodeToString(loop), GasLimit: 1000}
+	if _, err := bc.AddTransaction(&deploy); err != nil {
+		t.Fatalf("expected deployment to be accepted, got %v", err)
+	}
+	broke := blockchain.Transaction{Type: blockchain.TxTypeDeploy, Sender: "Bob", Code: hex.EncodeToString(loop), GasLimit: 1000}
+	if _, err := bc.AddTransaction(&broke); !errors.Is(err, blockchain.ErrInsufficientFunds) {
+		t.Errorf("expected a sender who can't pay for the gas limit to be rejected, got %v", err)
+	}
+	// The endless loop stops at the gas limit, and the sender pays for all of it
+	for nonce := uint64(1); nonce <= 2; nonce++ {
+		call := blockchain.Transaction{Type: blockchain.TxTypeCall, Sender: "Alice", Recipient: blockchain.ContractAddress(deploy.ID), GasLimit: 3000, Nonce: nonce}
+		if _, err := bc.AddTransaction(&call); err != nil {
+			t.Fatalf("expected call %d to be accepted, got %v", nonce, err)
+		}
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	if len(block.Transactions) != 2 || len(bc.CurrentTransactions) != 1 {
+		t.Fatalf("expected the block to stop at 6000 gas, got %d transactions and %d pending", len(block.Transactions), len(bc.CurrentTransactions))
+	}
+	receipt := block.Receipts[1]
+	if receipt.Status != blockchain.ReceiptStatusFailed || receipt.GasUsed != 3000 || receipt.Fee != 3000 {
+		t.Errorf("expected the loop to run out of gas and cost 3000, got %+v", receipt)
+	}
+	fees := block.Receipts[0].Fee + receipt.Fee
+	if balance, _ := bc.Balance(blockchain.DefaultNativeSymbol, "Alice"); balance.Amount != 10000-fees {
+		t.Errorf("expected Alice to hold %d after the fees, got %d", 10000-fees, balance.Amount)
+	}
+	for _, asset := range bc.Assets() {
+		if asset.Symbol == blockchain.DefaultNativeSymbol && asset.Supply != 10000-fees {
+			t.Errorf("expected the fees to be burnt, got a supply of %d", asset.Supply)
+		}
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+
+	genesis.Consensus.MaxTransactionGas = 0
+	if err := genesis.Validate(); err == nil {
+		t.Error("expected a genesis without a gas cap to be rejected")
+	}
+}
+
+// splitterModule is a WebAssembly contract that adds its first argument to storage slot 0, emits
+// the total under topic 1 and forwards its second argument of native funds to Carol:
+//
+//	(module
+//	  (import "env" "storage_get" (func $get (param i64) (result i64)))
+//	  (import "env" "storage_set" (func $set (param i64 i64)))
+//	  (import "env" "emit" (func $emit (param i64 i64)))
+//	  (import "env" "arg" (func $arg (param i32) (result i64)))
+//	  (import "env" "transfer" (func $transfer (param i32 i32 i64) (result i32)))
+//	  (memory 1)
+//	  (data (i32.const 0) "Carol")
+//	  (func (export "call") (result i64) (local i64)
+//	    (call $set (i64.const 0) (local.tee 0 (i64.add (call $get (i64.const 0)) (call $arg (i32.const 0)))))
+//	    (call $emit (i64.const 1) (local.get 0))
+//	    (if (call $transfer (i32.const 0) (i32.const 5) (call $arg (i32.const 1))) (then unreachable))
+//	    (local.get 0)))
+const splitterModule = "0061736d01000000011b0560017e017e60027e7e0060017f017e60037f7f7e017f6000017e02490503656e760b73746f726167655f676574000003656e760b73746f726167655f736574000103656e7604656d6974000103656e7603617267000203656e76087472616e7366657200030302010405030100010708010463616c6c00050a2b012901017e420042001000410010037c22001001420120001002410041054101100310040440000b20000b0b0b010041000b054361726f6c"
+
+// TestWASMContract runs a WebAssembly contract using storage, events and native transfers.
+func TestWASMContract(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	deploy := blockchain.Transaction{Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: splitterModule, GasLimit: 10000}
+	if _, err := bc.AddTransaction(&deploy); err != nil {
+		t.Fatalf("expected deployment to be accepted, got %v", err)
+	}
+	address := blockchain.ContractAddress(deploy.ID)
+
+	calls := []blockchain.Transaction{
+		{Type: blockchain.TxTypeCall, Sender: "Bob", Recipient: address, Amount: 10, Args: []int64{5, 4}, GasLimit: 1000},
+		// The contract only holds 6 after the first call, so the transfer fails and the call traps
+		{Type: blockchain.TxTypeCall, Sender: "Bob", Recipient: address, Amount: 1, Args: []int64{1, 100}, GasLimit: 1000, Nonce: 1},
+	}
+	for i := range calls {
+		if _, err := bc.AddTransaction(&calls[i]); err != nil {
+			t.Fatalf("expected call %d to be accepted, got %v", i, err)
+		}
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	if len(block.Receipts) != 3 {
+		t.Fatalf("expected 3 receipts, got %d", len(block.Receipts))
+	}
+	if receipt := block.Receipts[1]; receipt.Status != blockchain.ReceiptStatusSuccess || receipt.Return != 5 || receipt.GasUsed == 0 ||
+		len(receipt.Logs) != 1 || receipt.Logs[0] != (blockchain.Log{Contract: address, Topic: 1, Value: 5}) {
+		t.Errorf("unexpected call receipt %+v", receipt)
+	}
+	if receipt := block.Receipts[2]; receipt.Status != blockchain.ReceiptStatusFailed || len(receipt.Logs) != 0 {
+		t.Errorf("expected the second call to fail without logs, got %+v", receipt)
+	}
+
+	// Bob paid for the gas of his calls on top of what the first one sent
+	fees := block.Receipts[1].Fee + block.Receipts[2].Fee
+	balances := map[string]blockchain.Amount{"Bob": -10 - fees, address: 6, "Carol": 4}
+	for holder, expected := range balances {
+		if balance, _ := bc.Balance(blockchain.DefaultNativeSymbol, holder); balance.Amount != expected {
+			t.Errorf("expected %s to hold %d, got %d", holder, expected, balance.Amount)
+		}
+	}
+	if contract, _ := bc.Contract(address); contract.Storage[0] != 5 {
+		t.Errorf("expected the failed call to leave storage at 5, got %d", contract.Storage[0])
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+
+	invalid := blockchain.Transaction{Type: blockchain.TxTypeDeploy, Sender: "Alice", Code: "0061736d01000000", GasLimit: 10000, Nonce: 1}
+	if _, err := bc.AddTransaction(&invalid); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+		t.Errorf("expected a module without entry point to be rejected, got %v", err)
+	}
+}
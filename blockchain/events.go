@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// EventTransactionPending is published with the Transaction accepted into the pending ones
+	EventTransactionPending = "transaction.pending"
+	// EventBlockMined is published with the Block mined by this node
+	EventBlockMined = "block.mined"
+	// EventBlockReceived is published with every Block connected from the chain of a peer
+	EventBlockReceived = "block.received"
+	// EventChainReplaced is published with a ChainReplacement once the chain of a peer replaced ours
+	EventChainReplaced = "chain.replaced"
+	// EventPeerAdded is published with the address of a newly registered node
+	EventPeerAdded = "peer.added"
+	// EventPeerRemoved is published with the address of a node no longer registered
+	EventPeerRemoved = "peer.removed"
+)
+
+// eventTopics lists every topic the blockchain publishes on
+var eventTopics = []string{EventTransactionPending, EventBlockMined, EventBlockReceived, EventChainReplaced, EventPeerAdded, EventPeerRemoved}
+
+// Event is something that happened to the chain, Data depends on the topic
+type Event struct {
+	ID        uint64      `json:"id"`
+	Topic     string      `json:"topic"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// ChainReplacement describes the blocks swapped when the chain of a peer replaced ours.
+// It is a reorganization when blocks were disconnected.
+type ChainReplacement struct {
+	// Fork is the index of the last block both chains share
+	Fork         int      `json:"fork"`
+	Disconnected []string `json:"disconnected"`
+	Connected    []string `json:"connected"`
+	Length       int      `json:"length"`
+}
+
+// EventBus fans out events to subscribers. Publishing never blocks: a subscriber whose buffer is full misses the event.
+type EventBus struct {
+	subscriptions map[*Subscription]bool
+	lastID        uint64
+	mu            sync.Mutex
+}
+
+// Subscription receives the events of the topics it subscribed to on C
+type Subscription struct {
+	C      <-chan Event
+	events chan Event
+	topics []string
+	bus    *EventBus
+	// dropped counts the events missed because the buffer was full
+	dropped int
+}
+
+// NewEventBus returns a bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscriptions: make(map[*Subscription]bool)}
+}
+
+// Subscribe returns a subscription buffering up to buffer events. A topic matches itself and, without its
+// suffix, every topic below it: "block" matches "block.mined" and "block.received". No topics matches them all.
+func (b *EventBus) Subscribe(buffer int, topics ...string) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan Event, buffer)
+	subscription := &Subscription{C: events, events: events, topics: topics, bus: b}
+	b.subscriptions[subscription] = true
+	return subscription
+}
+
+// Publish sends an event to every subscription matching its topic and returns it
+func (b *EventBus) Publish(topic string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event := Event{ID: b.lastID, Topic: topic, Timestamp: time.Now().Unix(), Data: data}
+	for subscription := range b.subscriptions {
+		if !subscription.matches(topic) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.dropped++
+		}
+	}
+	return event
+}
+
+// Close stops the subscription and closes C
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if s.bus.subscriptions[s] {
+		delete(s.bus.subscriptions, s)
+		close(s.events)
+	}
+}
+
+// Dropped returns the number of events missed so far because the subscriber was too slow
+func (s *Subscription) Dropped() int {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.dropped
+}
+
+// ValidTopic tells if a topic filter matches any topic the blockchain publishes on
+func ValidTopic(filter string) bool {
+	for _, topic := range eventTopics {
+		if TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscription) matches(topic string) bool {
+	if len(s.topics) == 0 {
+		return true
+	}
+	for _, filter := range s.topics {
+		if TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// TopicMatches tells if a topic filter matches a topic
+func TopicMatches(filter, topic string) bool {
+	return topic == filter || strings.HasPrefix(topic, filter+".")
+}
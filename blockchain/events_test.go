@@ -0,0 +1,89 @@
+package blockchain_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestEventBus filters events by topic and never blocks on slow subscribers.
+func TestEventBus(t *testing.T) {
+	bus := blockchain.NewEventBus()
+	blocks := bus.Subscribe(1, "block")
+	all := bus.Subscribe(10)
+
+	bus.Publish(blockchain.EventBlockMined, "first")
+	bus.Publish(blockchain.EventPeerAdded, "peer")
+	bus.Publish(blockchain.EventBlockReceived, "second")
+
+	if event := <-blocks.C; event.Topic != blockchain.EventBlockMined || event.Data != "first" || event.ID != 1 {
+		t.Errorf("expected the first block event, got %+v", event)
+	}
+	if dropped := blocks.Dropped(); dropped != 1 {
+		t.Errorf("expected the second block event to be dropped, got %d dropped", dropped)
+	}
+	if len(all.C) != 3 {
+		t.Errorf("expected every event without topics, got %d", len(all.C))
+	}
+
+	all.Close()
+	all.Close()
+	bus.Publish(blockchain.EventBlockMined, "third")
+	if _, ok := <-all.C; !ok {
+		t.Error("expected buffered events to remain readable after closing")
+	}
+}
+
+// TestChainEvents publishes the events of pending transactions, mined blocks, peers and chain replacements.
+func TestChainEvents(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	subscription := bc.Events().Subscribe(10)
+	defer subscription.Close()
+
+	transaction := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 1}
+	if _, err := bc.AddTransaction(&transaction); err != nil {
+		t.Fatal(err)
+	}
+	mined := bc.NewBlock(bc.LastBlock().Hash)
+
+	// A peer whose longer chain doesn't contain our block
+	peer := blockchain.NewBlockchain()
+	peer.NewBlock(peer.LastBlock().Hash)
+	peer.NewBlock(peer.LastBlock().Hash)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"length": len(peer.Chain), "chain": peer.Chain})
+	}))
+	defer server.Close()
+	bc.RegisterNode(server.Listener.Addr().String())
+	bc.RegisterNode(server.Listener.Addr().String())
+	if !bc.ResolveConflicts() {
+		t.Fatal("expected the chain of the peer to replace ours")
+	}
+
+	expected := []string{
+		blockchain.EventTransactionPending,
+		blockchain.EventBlockMined,
+		blockchain.EventPeerAdded,
+		blockchain.EventBlockReceived,
+		blockchain.EventBlockReceived,
+		blockchain.EventChainReplaced,
+		// Our transaction goes back to the pending ones, without being published again
+	}
+	if len(subscription.C) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(subscription.C))
+	}
+	var last blockchain.Event
+	for _, topic := range expected {
+		last = <-subscription.C
+		if last.Topic != topic {
+			t.Errorf("expected a %s event, got %s", topic, last.Topic)
+		}
+	}
+	replacement := last.Data.(blockchain.ChainReplacement)
+	if replacement.Fork != 1 || len(replacement.Disconnected) != 1 || replacement.Disconnected[0] != mined.Hash || len(replacement.Connected) != 2 || replacement.Length != 3 {
+		t.Errorf("expected our block to be replaced by two blocks of the peer, got %+v", replacement)
+	}
+}
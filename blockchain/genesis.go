@@ -0,0 +1,178 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultChainID identifies the network used when no genesis file is configured
+	DefaultChainID = "diy-devnet"
+	// DefaultGenesisTimestamp is a fixed point in time so every node derives the same genesis block
+	DefaultGenesisTimestamp int64 = 1731196800
+	// DefaultDifficulty is the number of leading zeros a proof hash must have
+	DefaultDifficulty = 4
+	// GenesisSender is the sender of the allocation transactions stored in the genesis block
+	GenesisSender = "0"
+	// DefaultNativeSymbol is the symbol of the asset allocated in the genesis block
+	DefaultNativeSymbol = "DIY"
+	// DefaultMaxTransactionGas caps the gas limit of a contract transaction
+	DefaultMaxTransactionGas uint64 = 1_000_000
+	// DefaultMaxBlockGas caps the sum of the gas limits of the transactions of a block
+	DefaultMaxBlockGas uint64 = 10_000_000
+	// DefaultGasPrice is the amount of the native asset paid for each unit of gas
+	DefaultGasPrice Amount = 1
+)
+
+// Genesis describes how a network starts. Nodes built from the same spec
+// produce the same genesis block and therefore share the same genesis hash.
+// The genesis block commits to every field of the spec, so nodes that differ
+// in any consensus rule don't accept each other's chains.
+type Genesis struct {
+	ChainID        string            `yaml:"chain_id" json:"chain_id"`
+	Timestamp      int64             `yaml:"timestamp" json:"timestamp"`
+	Difficulty     int               `yaml:"difficulty" json:"difficulty"`
+	NativeSymbol   string            `yaml:"native_symbol" json:"native_symbol"`
+	NativeDecimals int               `yaml:"native_decimals" json:"native_decimals"`
+	Alloc          map[string]Amount `yaml:"alloc" json:"alloc"`
+	Consensus      ConsensusParams   `yaml:"consensus" json:"consensus"`
+}
+
+// ConsensusParams holds the rules every node of a network must agree on
+type ConsensusParams struct {
+	// MaxBlockTransactions caps the transactions of a block, 0 means no limit
+	MaxBlockTransactions int `yaml:"max_block_transactions" json:"max_block_transactions"`
+	// LedgerMode is either account (default) or utxo
+	LedgerMode string `yaml:"ledger_mode" json:"ledger_mode"`
+	// EnforceBalances rejects transfers and gas fees of the native asset exceeding the balance of the sender.
+	// It is off by default: blocks pay no reward, so the native asset only exists through alloc, and a
+	// network allocating nothing, like the default devnet, could never move it. Overdrafts still can't
+	// overflow a balance, and token balances never go negative either way.
+	EnforceBalances bool `yaml:"enforce_balances" json:"enforce_balances"`
+	// MaxTransactionGas caps the gas limit of contract transactions, so every execution ends
+	MaxTransactionGas uint64 `yaml:"max_transaction_gas" json:"max_transaction_gas"`
+	// MaxBlockGas caps the sum of the gas limits of the transactions of a block
+	MaxBlockGas uint64 `yaml:"max_block_gas" json:"max_block_gas"`
+	// GasPrice is the amount of the native asset the sender of a contract transaction burns for each unit of gas used
+	GasPrice Amount `yaml:"gas_price" json:"gas_price"`
+}
+
+// DefaultGenesis returns the spec used when no genesis file is provided
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		ChainID:      DefaultChainID,
+		Timestamp:    DefaultGenesisTimestamp,
+		Difficulty:   DefaultDifficulty,
+		NativeSymbol: DefaultNativeSymbol,
+		Alloc:        map[string]Amount{},
+		Consensus: ConsensusParams{
+			LedgerMode:        LedgerModeAccount,
+			MaxTransactionGas: DefaultMaxTransactionGas,
+			MaxBlockGas:       DefaultMaxBlockGas,
+			GasPrice:          DefaultGasPrice,
+		},
+	}
+}
+
+// Validate checks that the spec can produce a usable chain
+func (g *Genesis) Validate() error {
+	if g.ChainID == "" {
+		return fmt.Errorf("genesis chain_id is required")
+	}
+	if g.Timestamp <= 0 {
+		return fmt.Errorf("genesis timestamp must be positive, got %d", g.Timestamp)
+	}
+	if g.Difficulty < 1 || g.Difficulty > 64 {
+		return fmt.Errorf("genesis difficulty must be between 1 and 64, got %d", g.Difficulty)
+	}
+	if g.Consensus.MaxBlockTransactions < 0 {
+		return fmt.Errorf("consensus max_block_transactions can't be negative, got %d", g.Consensus.MaxBlockTransactions)
+	}
+	if g.Consensus.LedgerMode != LedgerModeAccount && g.Consensus.LedgerMode != LedgerModeUTXO {
+		return fmt.Errorf("consensus ledger_mode must be %s or %s, got %q", LedgerModeAccount, LedgerModeUTXO, g.Consensus.LedgerMode)
+	}
+	if g.Consensus.MaxTransactionGas == 0 {
+		return fmt.Errorf("consensus max_transaction_gas must be positive")
+	}
+	if g.Consensus.MaxBlockGas < g.Consensus.MaxTransactionGas {
+		return fmt.Errorf("consensus max_block_gas must be at least max_transaction_gas %d, got %d", g.Consensus.MaxTransactionGas, g.Consensus.MaxBlockGas)
+	}
+	if price := g.Consensus.GasPrice; price < 0 || (price > 0 && g.Consensus.MaxTransactionGas > math.MaxInt64/uint64(price)) {
+		return fmt.Errorf("consensus gas_price can't be negative nor make max_transaction_gas cost more than an amount holds, got %d", price)
+	}
+	if !symbolPattern.MatchString(g.NativeSymbol) {
+		return fmt.Errorf("genesis native_symbol %q must be 2 to 10 uppercase letters or digits, starting with a letter", g.NativeSymbol)
+	}
+	if g.NativeDecimals < 0 || g.NativeDecimals > MaxDecimals {
+		return fmt.Errorf("genesis native_decimals must be between 0 and %d, got %d", MaxDecimals, g.NativeDecimals)
+	}
+	for address, amount := range g.Alloc {
+		if address == "" {
+			return fmt.Errorf("genesis alloc contains an empty address")
+		}
+		if amount <= 0 {
+			return fmt.Errorf("genesis alloc for %s must be positive, got %d", address, amount)
+		}
+	}
+	if _, err := g.supply(); err != nil {
+		return fmt.Errorf("genesis alloc total: %w", err)
+	}
+	return nil
+}
+
+// supply returns the total amount of the native asset allocated in the genesis block
+func (g *Genesis) supply() (Amount, error) {
+	amounts := make([]Amount, 0, len(g.Alloc))
+	for _, amount := range g.Alloc {
+		amounts = append(amounts, amount)
+	}
+	return sumAmounts(amounts...)
+}
+
+// SpecHash returns the hex encoded SHA-256 of the JSON encoding of the spec, allocations sorted by address
+func (g *Genesis) SpecHash() string {
+	encoded, _ := json.Marshal(g)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// block builds the genesis block, without its hash. Allocations are sorted by
+// address because map iteration order would otherwise change the hash.
+// In utxo mode they become the outputs of a single transaction.
+func (g *Genesis) block() Block {
+	addresses := make([]string, 0, len(g.Alloc))
+	for address := range g.Alloc {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	transactions := []Transaction{}
+	if g.Consensus.LedgerMode == LedgerModeUTXO {
+		transaction := Transaction{ChainID: g.ChainID, Sender: GenesisSender}
+		for _, address := range addresses {
+			transaction.Outputs = append(transaction.Outputs, TxOutput{Address: address, Amount: g.Alloc[address]})
+		}
+		transaction.ID = transaction.ComputeID()
+		transactions = append(transactions, transaction)
+	} else {
+		for _, address := range addresses {
+			transaction := Transaction{ChainID: g.ChainID, Sender: GenesisSender, Recipient: address, Amount: g.Alloc[address]}
+			transaction.ID = transaction.ComputeID()
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	return Block{
+		ChainID:      g.ChainID,
+		Index:        1,
+		Timestamp:    g.Timestamp,
+		Transactions: transactions,
+		// There is no previous block, the spec hash takes its place
+		PreviousHash: g.SpecHash(),
+		Proof:        100, // A valid proof for the genesis block
+	}
+}
@@ -0,0 +1,147 @@
+package blockchain_test
+
+import (
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestGenesisIsDeterministic verifies that two nodes started from the same spec share the genesis hash.
+func TestGenesisIsDeterministic(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Alloc = map[string]blockchain.Amount{"Bob": 50, "Alice": 100}
+
+	bc1, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	bc2, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+
+	if bc1.GenesisHash() != bc2.GenesisHash() {
+		t.Errorf("expected identical genesis hashes, got %s and %s", bc1.GenesisHash(), bc2.GenesisHash())
+	}
+	if bc1.GenesisHash() == blockchain.NewBlockchain().GenesisHash() {
+		t.Error("expected allocations to change the genesis hash")
+	}
+
+	// Allocations are stored sorted by address
+	allocations := bc1.Chain[0].Transactions
+	if len(allocations) != 2 || allocations[0].Recipient != "Alice" || allocations[1].Recipient != "Bob" {
+		t.Errorf("unexpected genesis allocations: %v", allocations)
+	}
+	if allocations[0].Sender != blockchain.GenesisSender || allocations[0].Amount != 100 {
+		t.Errorf("unexpected allocation to Alice: %v", allocations[0])
+	}
+}
+
+// TestGenesisValidate checks that invalid specs are rejected.
+func TestGenesisValidate(t *testing.T) {
+	tests := map[string]func(g *blockchain.Genesis){
+		"missing chain id":    func(g *blockchain.Genesis) { g.ChainID = "" },
+		"zero timestamp":      func(g *blockchain.Genesis) { g.Timestamp = 0 },
+		"zero difficulty":     func(g *blockchain.Genesis) { g.Difficulty = 0 },
+		"negative allocation": func(g *blockchain.Genesis) { g.Alloc["Alice"] = -1 },
+		"negative block cap":  func(g *blockchain.Genesis) { g.Consensus.MaxBlockTransactions = -1 },
+	}
+
+	for name, mutate := range tests {
+		genesis := blockchain.DefaultGenesis()
+		mutate(genesis)
+		if _, err := blockchain.NewBlockchainWithGenesis(genesis); err == nil {
+			t.Errorf("%s: expected an error, got none", name)
+		}
+	}
+}
+
+// TestGenesisCommitsToConsensus verifies that nodes with different consensus rules don't share a genesis hash.
+func TestGenesisCommitsToConsensus(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	tests := map[string]func(g *blockchain.Genesis){
+		"difficulty":             func(g *blockchain.Genesis) { g.Difficulty = 3 },
+		"ledger_mode":            func(g *blockchain.Genesis) { g.Consensus.LedgerMode = blockchain.LedgerModeUTXO },
+		"max_block_transactions": func(g *blockchain.Genesis) { g.Consensus.MaxBlockTransactions = 10 },
+		"enforce_balances":       func(g *blockchain.Genesis) { g.Consensus.EnforceBalances = true },
+		"gas_price":              func(g *blockchain.Genesis) { g.Consensus.GasPrice = 2 },
+		"native_symbol":          func(g *blockchain.Genesis) { g.NativeSymbol = "COIN" },
+	}
+	for name, mutate := range tests {
+		genesis := blockchain.DefaultGenesis()
+		mutate(genesis)
+		other, err := blockchain.NewBlockchainWithGenesis(genesis)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if other.GenesisHash() == bc.GenesisHash() || bc.ValidChain(other.Chain) {
+			t.Errorf("%s: expected a different genesis hash and the chain to be rejected", name)
+		}
+	}
+
+	if blockchain.NewBlockchain(blockchain.WithDifficulty(2)).GenesisHash() == bc.GenesisHash() {
+		t.Error("expected the difficulty option to change the genesis hash")
+	}
+}
+
+// TestValidChainRejectsForeignGenesis verifies that a chain started from another genesis is not accepted.
+func TestValidChainRejectsForeignGenesis(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	genesis := blockchain.DefaultGenesis()
+	genesis.ChainID = "other-network"
+	genesis.Timestamp++
+	other, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	other.NewBlock(other.LastBlock().Hash)
+
+	if !other.ValidChain(other.Chain) {
+		t.Error("expected chain to be valid on its own network")
+	}
+	if bc.ValidChain(other.Chain) {
+		t.Error("expected chain with a different genesis to be rejected")
+	}
+}
+
+// TestMaxBlockTransactions verifies that pending transactions above the consensus cap wait for the next block.
+func TestMaxBlockTransactions(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Consensus.MaxBlockTransactions = 2
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+
+	bc.NewTransaction("Alice", "Bob", 1)
+	bc.NewTransaction("Alice", "Bob", 2)
+	bc.NewTransaction("Alice", "Bob", 3)
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	if len(block.Transactions) != 2 {
+		t.Errorf("expected 2 transactions in block, got %d", len(block.Transactions))
+	}
+	if len(bc.CurrentTransactions) != 1 || bc.CurrentTransactions[0].Amount != 3 {
+		t.Errorf("expected the last transaction to stay pending, got %v", bc.CurrentTransactions)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+}
+
+// TestValidChainRejectsForeignChainID verifies that blocks carrying another chain id are rejected.
+func TestValidChainRejectsForeignChainID(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.NewTransaction("Alice", "Bob", 10)
+	bc.NewBlock(bc.LastBlock().Hash)
+
+	// Re-hash the block for another network so only the chain id is wrong
+	block := &bc.Chain[1]
+	block.ChainID = "other-network"
+	block.Hash = bc.Hash(*block)
+
+	if bc.ValidChain(bc.Chain) {
+		t.Error("expected block from another chain to be rejected")
+	}
+}
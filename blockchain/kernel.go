@@ -1,114 +1,523 @@
 package blockchain
 
 import (
-	"crypto/sha256"
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"diy.blockchain.org/m/logger"
 )
 
+const (
+	// MedianTimeBlocks is the number of blocks whose median timestamp a new block must be later than
+	MedianTimeBlocks = 11
+	// MaxFutureBlockTime is how far ahead of the clock of a node the blocks it accepts can be stamped
+	MaxFutureBlockTime = 2 * time.Hour
+	// PeerTimeout bounds every request made to another node, a peer that doesn't answer can't stall syncing
+	PeerTimeout = 30 * time.Second
+	// UndoDepth is the number of recent blocks whose changes to the state are kept to disconnect them,
+	// disconnecting older blocks replays the chain from the genesis block
+	UndoDepth = 100
+)
+
 // Block represents each 'item' in the blockchain
 type Block struct {
+	ChainID      string        `json:"chain_id"`
 	Index        int           `json:"index"`
 	Timestamp    int64         `json:"timestamp"`
 	Transactions []Transaction `json:"transactions"`
 	PreviousHash string        `json:"previous_hash"`
 	Proof        int           `json:"proof"`
-	Hash         string        `json:"hash"`
+	// Receipts holds the outcome of the contract transactions of the block, in order
+	Receipts []Receipt `json:"receipts,omitempty"`
+	Hash     string    `json:"hash"`
 }
 
 // Transaction represents a transaction
 type Transaction struct {
+	ID        string `json:"id"`
+	ChainID   string `json:"chain_id"`
+	Type      string `json:"type,omitempty"`
 	Sender    string `json:"sender"`
 	Recipient string `json:"recipient"`
-	Amount    int    `json:"amount"`
+	Amount    Amount `json:"amount"`
+	Nonce     uint64 `json:"nonce"`
+	// Asset is the symbol of the asset moved by a transfer, empty for the native asset
+	Asset string `json:"asset,omitempty"`
+	// Token describes the asset issued by token creation transactions
+	Token *TokenSpec `json:"token,omitempty"`
+	// Data is a free-form memo of up to MaxDataSize bytes
+	Data string `json:"data,omitempty"`
+	// AnchorHash is the content hash notarized by anchor transactions
+	AnchorHash string `json:"anchor_hash,omitempty"`
+	// Inputs and Outputs are only used in utxo ledger mode
+	Inputs  []TxInput  `json:"inputs,omitempty"`
+	Outputs []TxOutput `json:"outputs,omitempty"`
+	// Multisig defines the account of a multisig sender, Signatures approve the transaction
+	Multisig   *MultisigAccount `json:"multisig,omitempty"`
+	Signatures []Signature      `json:"signatures,omitempty"`
+	// LockHeight and LockTime keep the transaction out of blocks below that index or older than that unix time
+	LockHeight int   `json:"lock_height,omitempty"`
+	LockTime   int64 `json:"lock_time,omitempty"`
+	// Code is the hex encoded bytecode of deploy transactions, Args the arguments of calls
+	Code     string  `json:"code,omitempty"`
+	Args     []int64 `json:"args,omitempty"`
+	GasLimit uint64  `json:"gas_limit,omitempty"`
 }
 
+var (
+	// ErrWrongChain is returned for transactions meant for another network
+	ErrWrongChain = errors.New("wrong chain id")
+	// ErrDuplicateTransaction is returned for transactions already pending or confirmed
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+	// ErrInvalidNonce is returned for transactions that don't carry the next nonce of their sender
+	ErrInvalidNonce = errors.New("invalid nonce")
+	// ErrInvalidTransaction is returned for malformed transactions
+	ErrInvalidTransaction = errors.New("invalid transaction")
+	// ErrInvalidSignature is returned when a signature doesn't match the data or the key it claims
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrNotEnoughSignatures is returned for multisig transactions below the threshold of their account
+	ErrNotEnoughSignatures = errors.New("not enough signatures")
+	// ErrTransactionLocked is returned for transactions included in a block before their lock expires
+	ErrTransactionLocked = errors.New("transaction is time-locked")
+	// ErrUnknownTransaction is returned when a transaction can't be found
+	ErrUnknownTransaction = errors.New("unknown transaction")
+	// ErrMissingInput is returned for inputs spending outputs that don't exist or are already spent
+	ErrMissingInput = errors.New("missing input")
+)
+
 // Blockchain represents the entire blockchain
 type Blockchain struct {
 	Chain               []Block
 	CurrentTransactions []Transaction
 	Nodes               map[string]bool
+
+	genesis *Genesis
+	state   *State
+	// undo holds the journals of the changes the last UndoDepth blocks made to the state, oldest first
+	undo [][]func()
+	// confirmed maps the ID of every confirmed transaction to where it is in the chain
+	confirmed map[string]txLocation
+	// hashes maps the hash of every block of the chain to its position, the genesis block being 1
+	hashes map[string]int
+	// partials holds the multisig transactions still collecting signatures, by ID
+	partials map[string]*Transaction
+	// anchors holds the confirmed anchors of every document hash, oldest first
+	anchors map[string][]Anchor
+	// observers are notified of every block connected or disconnected
+	observers []BlockObserver
+	events    *EventBus
+	// client fetches the chains of the other nodes
+	client *http.Client
+	// now tells the time blocks are stamped with
+	now        func() time.Time
+	difficulty int
+	hasher     Hasher
+	proof      ProofStrategy
+	mu         sync.Mutex
 }
 
-// NewBlockchain initializes a new blockchain
-func NewBlockchain() *Blockchain {
-	genesisBlock := Block{
-		Index:        1,
-		Timestamp:    time.Now().Unix(),
-		Transactions: []Transaction{}, // Initial empty transactions
-		PreviousHash: "0000",
-		Proof:        100, // A valid proof for the genesis block
-		Hash:         "",  // Hash will be computed later
+// NewBlockchain initializes a new blockchain from the default genesis spec. It panics if the options are invalid.
+func NewBlockchain(options ...Option) *Blockchain {
+	bc, err := NewBlockchainWithGenesis(DefaultGenesis(), options...)
+	if err != nil {
+		panic(err)
+	}
+	return bc
+}
+
+// NewBlockchainWithGenesis initializes a new blockchain whose first block is derived from the given spec
+func NewBlockchainWithGenesis(genesis *Genesis, options ...Option) (*Blockchain, error) {
+	if err := genesis.Validate(); err != nil {
+		return nil, err
 	}
 
 	bc := &Blockchain{
 		Chain:               []Block{},
 		CurrentTransactions: []Transaction{},
 		Nodes:               make(map[string]bool),
+		genesis:             genesis,
+		state:               newState(genesis),
+		confirmed:           make(map[string]txLocation),
+		hashes:              make(map[string]int),
+		partials:            make(map[string]*Transaction),
+		anchors:             make(map[string][]Anchor),
+		events:              NewEventBus(),
+		client:              &http.Client{Timeout: PeerTimeout},
+		now:                 time.Now,
+		difficulty:          genesis.Difficulty,
+		hasher:              SHA256,
+	}
+	for _, option := range options {
+		option(bc)
+	}
+	if bc.proof == nil {
+		if bc.difficulty < 1 || bc.difficulty > 64 {
+			return nil, fmt.Errorf("difficulty must be between 1 and 64, got %d", bc.difficulty)
+		}
+		bc.proof = LeadingZerosProof{Difficulty: bc.difficulty, Hash: bc.hasher}
+	}
+	if bc.difficulty != genesis.Difficulty {
+		// The genesis block commits to the difficulty blocks are really mined with
+		spec := *genesis
+		spec.Difficulty = bc.difficulty
+		bc.genesis = &spec
 	}
 
 	// Compute the hash for the genesis block and add it to the chain
+	genesisBlock := bc.genesis.block()
 	genesisBlock.Hash = bc.Hash(genesisBlock)
-	bc.Chain = append(bc.Chain, genesisBlock)
+	bc.state.applyGenesis(genesisBlock)
+	bc.appendBlock(genesisBlock)
 
-	return bc
+	return bc, nil
+}
+
+// SetHTTPClient sets the client used to reach the other nodes, a client with PeerTimeout by default
+func (bc *Blockchain) SetHTTPClient(client *http.Client) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.client = client
 }
 
-// NewBlock creates a new block and adds it to the chain
+// SetClock replaces the clock of a running blockchain, WithClock sets it at construction
+func (bc *Blockchain) SetClock(now func() time.Time) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.now = now
+}
+
+// Events returns the bus the blockchain publishes its events on
+func (bc *Blockchain) Events() *EventBus {
+	return bc.events
+}
+
+// Genesis returns the spec this blockchain was created from
+func (bc *Blockchain) Genesis() *Genesis {
+	return bc.genesis
+}
+
+// Difficulty returns the number of leading zeros proofs of work must have, when the default proof strategy is used
+func (bc *Blockchain) Difficulty() int {
+	return bc.difficulty
+}
+
+// ChainID returns the identifier of the network this blockchain belongs to
+func (bc *Blockchain) ChainID() string {
+	return bc.genesis.ChainID
+}
+
+// GenesisHash returns the hash every valid chain of this network must start with
+func (bc *Blockchain) GenesisHash() string {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.Chain[0].Hash
+}
+
+// NewBlock creates a new block and adds it to the chain. The block always extends the last block: previousHash
+// is the one the caller saw, and if the chain was replaced since, the block is mined on the new last block instead.
 func (bc *Blockchain) NewBlock(previousHash string) Block {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
 	lastBlock := bc.LastBlock()
-	lastProof := 0
-	if lastBlock != nil {
-		lastProof = lastBlock.Proof
+	if previousHash != lastBlock.Hash {
+		logger.Warnf("Block %s isn't the last block anymore, mining on top of %s", previousHash, lastBlock.Hash)
+		previousHash = lastBlock.Hash
 	}
+	proof := bc.ProofOfWork(lastBlock.Proof, previousHash)
 
-	proof := bc.ProofOfWork(lastProof, previousHash)
-
+	// Blocks mined within the same second still have to be later than the median
+	timestamp := max(bc.now().Unix(), medianTime(bc.Chain)+1)
 	block := Block{
+		ChainID:      bc.ChainID(),
 		Index:        len(bc.Chain) + 1,
-		Timestamp:    time.Now().Unix(),
-		Transactions: bc.CurrentTransactions,
+		Timestamp:    timestamp,
 		PreviousHash: previousHash,
 		Hash:         "", // This will be filled after hashing
 		Proof:        proof,
 	}
 
+	// Take as many pending transactions as the consensus rules allow, the rest wait for the next block.
+	// Time-locked transactions wait until they unlock, and so do the ones that depend on them.
+	transactions := []Transaction{}
+	pending := []Transaction{}
+	limit := bc.genesis.Consensus.MaxBlockTransactions
+	gas, gasLimit := uint64(0), bc.genesis.Consensus.MaxBlockGas
+	for _, transaction := range bc.CurrentTransactions {
+		full := (limit > 0 && len(transactions) == limit) || gas+transaction.GasLimit > gasLimit
+		if full || !transaction.Unlocked(block.Index, block.Timestamp) {
+			pending = append(pending, transaction)
+			continue
+		}
+		receipt, err := bc.state.applyTransaction(transaction)
+		if err != nil {
+			if len(pending) > 0 {
+				pending = append(pending, transaction)
+				continue
+			}
+			logger.Warnf("Dropping transaction %s: %v", transaction.ID, err)
+			continue
+		}
+		transactions = append(transactions, transaction)
+		gas += transaction.GasLimit
+		if receipt != nil {
+			block.Receipts = append(block.Receipts, *receipt)
+		}
+	}
+	block.Transactions = transactions
+
 	block.Hash = bc.Hash(block)
-	bc.Chain = append(bc.Chain, block)
-	bc.CurrentTransactions = []Transaction{} // Reset current transactions
+	bc.appendBlock(block)
+	bc.CurrentTransactions = pending
+	bc.events.Publish(EventBlockMined, block)
 	return block
 }
 
-// NewTransaction adds a new transaction to the list of transactions
-func (bc *Blockchain) NewTransaction(sender, recipient string, amount int) int {
-	transaction := Transaction{Sender: sender, Recipient: recipient, Amount: amount}
-	bc.CurrentTransactions = append(bc.CurrentTransactions, transaction)
+// NewTransaction adds a new transaction for this chain to the list of transactions, using the next nonce of the sender
+func (bc *Blockchain) NewTransaction(sender, recipient string, amount Amount) int {
+	index, err := bc.AddTransaction(&Transaction{
+		ChainID:   bc.ChainID(),
+		Sender:    sender,
+		Recipient: recipient,
+		Amount:    amount,
+		Nonce:     bc.PendingNonce(sender),
+	})
+	if err != nil {
+		logger.Errorf("Transaction from %s rejected: %v", sender, err)
+	}
+	return index
+}
+
+// AddTransaction adds a transaction to the list of transactions and returns the index of the block that will hold it.
+// Transactions without a chain ID are considered to belong to this chain, and transactions without an ID get one.
+func (bc *Blockchain) AddTransaction(transaction *Transaction) (int, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.addTransaction(transaction)
+}
+
+func (bc *Blockchain) addTransaction(transaction *Transaction) (int, error) {
+	if err := bc.checkPendingTransaction(transaction); err != nil {
+		return 0, err
+	}
+
+	bc.CurrentTransactions = append(bc.CurrentTransactions, *transaction)
+	bc.events.Publish(EventTransactionPending, *transaction)
 	if bc.LastBlock() == nil {
-		return 1
+		return max(1, transaction.LockHeight), nil
+	}
+
+	return max(bc.LastBlock().Index+1, transaction.LockHeight), nil
+}
+
+// checkPendingTransaction fills in the defaults of a transaction and checks it can follow the pending ones
+func (bc *Blockchain) checkPendingTransaction(transaction *Transaction) error {
+	if transaction.ChainID == "" {
+		transaction.ChainID = bc.ChainID()
+	}
+	if transaction.ID == "" {
+		transaction.ID = transaction.ComputeID()
+	}
+	if err := validateTransaction(*transaction, bc.genesis); err != nil {
+		return err
+	}
+
+	if location, ok := bc.confirmed[transaction.ID]; ok {
+		return fmt.Errorf("%w: transaction %s is already confirmed in block %d", ErrDuplicateTransaction, transaction.ID, location.BlockIndex)
+	}
+	for _, pending := range bc.CurrentTransactions {
+		if pending.ID == transaction.ID {
+			return fmt.Errorf("%w: transaction %s is already pending", ErrDuplicateTransaction, transaction.ID)
+		}
+	}
+
+	// The transaction must apply on top of the pending ones, which are valid by construction
+	snapshot := bc.state.snapshot()
+	defer bc.state.revert(snapshot)
+	for _, pending := range bc.CurrentTransactions {
+		bc.state.applyTransaction(pending)
+	}
+	_, err := bc.state.applyTransaction(*transaction)
+	return err
+}
+
+// PendingNonce returns the nonce the next transaction submitted by address must carry
+func (bc *Blockchain) PendingNonce(address string) uint64 {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.pendingNonce(address)
+}
+
+func (bc *Blockchain) pendingNonce(address string) uint64 {
+	nonce := bc.state.Nonce(address)
+	for _, pending := range bc.CurrentTransactions {
+		if pending.Sender == address {
+			nonce++
+		}
+	}
+	return nonce
+}
+
+// UnspentOutputs returns the outputs of address that can be spent, only meaningful in utxo ledger mode
+func (bc *Blockchain) UnspentOutputs(address string) []UnspentOutput {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.state.UnspentOutputs(address)
+}
+
+// connectBlock applies the transactions of a block to the state and appends it to the chain
+func (bc *Blockchain) connectBlock(block Block) error {
+	snapshot := bc.state.snapshot()
+	for _, transaction := range block.Transactions {
+		if _, err := bc.state.applyTransaction(transaction); err != nil {
+			bc.state.revert(snapshot)
+			return fmt.Errorf("block %d: transaction %s: %w", block.Index, transaction.ID, err)
+		}
+	}
+	bc.appendBlock(block)
+	return nil
+}
+
+// appendBlock appends a block whose transactions are the changes made to the state since the last commit
+func (bc *Blockchain) appendBlock(block Block) {
+	bc.Chain = append(bc.Chain, block)
+	bc.commitState()
+	// The indexes hold where the block is in the chain, never the index it claims
+	index := len(bc.Chain)
+	bc.hashes[block.Hash] = index
+	for position, transaction := range block.Transactions {
+		bc.confirmed[transaction.ID] = txLocation{BlockIndex: index, Position: position}
+	}
+	bc.indexAnchors(block)
+	for _, observer := range bc.observers {
+		observer.BlockConnected(block)
+	}
+}
+
+// disconnectBlock removes the last block from the chain and reverts its changes to the state
+func (bc *Blockchain) disconnectBlock() Block {
+	last := len(bc.Chain) - 1
+	block := bc.Chain[last]
+	bc.Chain = bc.Chain[:last]
+	if len(bc.undo) > 0 {
+		bc.state.rollback(bc.undo[len(bc.undo)-1])
+		bc.undo = bc.undo[:len(bc.undo)-1]
+	} else {
+		bc.rebuildState()
+	}
+	delete(bc.hashes, block.Hash)
+	for _, transaction := range block.Transactions {
+		delete(bc.confirmed, transaction.ID)
+	}
+	bc.unindexAnchors(block)
+	for _, observer := range bc.observers {
+		observer.BlockDisconnected(block)
+	}
+	return block
+}
+
+// commitState keeps the journal of the changes made to the state by the block just appended
+func (bc *Blockchain) commitState() {
+	bc.undo = append(bc.undo, bc.state.commit())
+	if len(bc.undo) > UndoDepth {
+		bc.undo[0] = nil
+		bc.undo = bc.undo[1:]
+	}
+}
+
+// rebuildState replays the blocks of the chain on a new state, when a block older than the journals kept is disconnected
+func (bc *Blockchain) rebuildState() {
+	bc.state = newState(bc.genesis)
+	bc.undo = nil
+	bc.state.applyGenesis(bc.Chain[0])
+	bc.commitState()
+	for _, block := range bc.Chain[1:] {
+		for _, transaction := range block.Transactions {
+			bc.state.applyTransaction(transaction) // Valid, it was applied when the block was connected
+		}
+		bc.commitState()
+	}
+}
+
+// replaceChain disconnects our blocks down to the last one shared with chain, then connects the blocks of chain.
+// Transactions of the disconnected blocks go back to the pending ones if they are still valid.
+func (bc *Blockchain) replaceChain(chain []Block) error {
+	fork := 1
+	for fork < len(bc.Chain) && fork < len(chain) && bc.Chain[fork].Hash == chain[fork].Hash {
+		fork++
+	}
+
+	disconnected := []Block{}
+	for len(bc.Chain) > fork {
+		disconnected = append(disconnected, bc.disconnectBlock())
+	}
+	for _, block := range chain[fork:] {
+		if err := bc.connectBlock(block); err != nil {
+			// Restore our own chain
+			for len(bc.Chain) > fork {
+				bc.disconnectBlock()
+			}
+			for i := len(disconnected) - 1; i >= 0; i-- {
+				bc.connectBlock(disconnected[i])
+			}
+			return err
+		}
+	}
+
+	replacement := ChainReplacement{Fork: fork, Disconnected: []string{}, Connected: []string{}, Length: len(bc.Chain)}
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		replacement.Disconnected = append(replacement.Disconnected, disconnected[i].Hash)
+	}
+	for _, block := range chain[fork:] {
+		replacement.Connected = append(replacement.Connected, block.Hash)
+		bc.events.Publish(EventBlockReceived, block)
 	}
+	bc.events.Publish(EventChainReplaced, replacement)
 
-	return bc.LastBlock().Index + 1
+	pending := []Transaction{}
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		pending = append(pending, disconnected[i].Transactions...)
+	}
+	pending = append(pending, bc.CurrentTransactions...)
+	bc.CurrentTransactions = []Transaction{}
+	for _, transaction := range pending {
+		if err := bc.checkPendingTransaction(&transaction); err != nil {
+			logger.Infof("Dropping pending transaction %s: %v", transaction.ID, err)
+			continue
+		}
+		bc.CurrentTransactions = append(bc.CurrentTransactions, transaction)
+	}
+	return nil
 }
 
-// Hash creates a SHA-256 hash of a Block
+// Hash creates a hash of a Block with the hasher of the blockchain, SHA-256 by default
 func (bc *Blockchain) Hash(block Block) string {
 	// Convert transactions to JSON
 	transactionsJSON, err := json.Marshal(block.Transactions)
 	if err != nil {
-		fmt.Println("Error marshaling transactions:", err)
+		logger.Errorf("Failed to marshal the transactions of block %d: %v", block.Index, err)
 		return ""
 	}
 
-	record := fmt.Sprintf("%d%d%s%s", block.Index, block.Timestamp, block.PreviousHash, transactionsJSON)
+	record := fmt.Sprintf("%s%d%d%s%s", block.ChainID, block.Index, block.Timestamp, block.PreviousHash, transactionsJSON)
+	if len(block.Receipts) > 0 {
+		receiptsJSON, err := json.Marshal(block.Receipts)
+		if err != nil {
+			logger.Errorf("Failed to marshal the receipts of block %d: %v", block.Index, err)
+			return ""
+		}
+		record += string(receiptsJSON)
+	}
 
-	hash := sha256.New()
-	hash.Write([]byte(record))
-	return hex.EncodeToString(hash.Sum(nil))
+	return hex.EncodeToString(bc.hasher([]byte(record)))
 }
 
 // LastBlock returns the last Block in the chain
@@ -119,82 +528,163 @@ func (bc *Blockchain) LastBlock() *Block {
 	return &bc.Chain[len(bc.Chain)-1]
 }
 
+// ProofOfWork finds the proof of the block following the one with lastProof and previousHash
 func (bc *Blockchain) ProofOfWork(lastProof int, previousHash string) int {
-	proof := 0
-	for !bc.ValidProof(lastProof, proof, previousHash) {
-		proof++
-	}
-	return proof
+	return bc.proof.Prove(lastProof, previousHash)
 }
 
+// ValidProof tells if proof is a valid proof of work for the block following the one with lastProof and previousHash
 func (bc *Blockchain) ValidProof(lastProof int, proof int, previousHash string) bool {
-	guess := fmt.Sprintf("%d%d%s", lastProof, proof, previousHash)
-	guessHash := sha256.Sum256([]byte(guess))
-	return hex.EncodeToString(guessHash[:])[:4] == "0000"
+	return bc.proof.Valid(lastProof, proof, previousHash)
 }
 
 // ValidChain checks if a given blockchain is valid
 func (bc *Blockchain) ValidChain(chain []Block) bool {
+	if err := bc.validateChain(chain); err != nil {
+		logger.Errorf("Invalid chain: %v", err)
+		return false
+	}
+	return true
+}
+
+// validateChain replays a chain from its genesis block on a fresh state
+func (bc *Blockchain) validateChain(chain []Block) error {
 	// Validate genesis block separately
 	if len(chain) == 0 {
-		logger.Errorf("Chain is empty")
-		return false
+		return errors.New("chain is empty")
 	}
 	genesisBlock := chain[0]
 	if genesisBlock.Hash != bc.Hash(genesisBlock) {
-		logger.Errorf("Genesis block hash mismatch: expected %s, got %s", bc.Hash(genesisBlock), genesisBlock.Hash)
-		return false
+		return fmt.Errorf("genesis block hash mismatch: expected %s, got %s", bc.Hash(genesisBlock), genesisBlock.Hash)
+	}
+	if genesisBlock.Hash != bc.GenesisHash() {
+		return fmt.Errorf("chain belongs to another network: expected genesis %s, got %s", bc.GenesisHash(), genesisBlock.Hash)
 	}
 	logger.Infof("Genesis block validated: %s", genesisBlock.Hash)
 
 	// Validate subsequent blocks
+	state := newState(bc.genesis)
+	state.applyGenesis(genesisBlock)
 	for i := 1; i < len(chain); i++ {
 		block := chain[i]
 		prevBlock := chain[i-1]
 
+		// Time locks and the lookup indexes rely on the index, it must be the position of the block
+		if block.Index != i+1 {
+			return fmt.Errorf("block %d has index %d, expected %d", i, block.Index, i+1)
+		}
+		if block.ChainID != bc.ChainID() {
+			return fmt.Errorf("block %d belongs to chain %s, expected %s", i, block.ChainID, bc.ChainID())
+		}
 		if block.PreviousHash != prevBlock.Hash {
-			logger.Errorf("Block %d has incorrect previous hash: expected %s, got %s", i, prevBlock.Hash, block.PreviousHash)
-			return false
+			return fmt.Errorf("block %d has incorrect previous hash: expected %s, got %s", i, prevBlock.Hash, block.PreviousHash)
 		}
 		if block.Hash != bc.Hash(block) {
-			logger.Errorf("Block %d has incorrect hash: expected %s, got %s", i, bc.Hash(block), block.Hash)
-			return false
+			return fmt.Errorf("block %d has incorrect hash: expected %s, got %s", i, bc.Hash(block), block.Hash)
+		}
+		// Time locks are checked against the timestamps miners choose, so they must move forward and stay
+		// close to the actual time
+		if median := medianTime(chain[:i]); block.Timestamp <= median {
+			return fmt.Errorf("block %d has timestamp %d, it must be later than %d, the median of the blocks before it", i, block.Timestamp, median)
+		}
+		if limit := bc.now().Add(MaxFutureBlockTime).Unix(); block.Timestamp > limit {
+			return fmt.Errorf("block %d has timestamp %d, more than %s in the future", i, block.Timestamp, MaxFutureBlockTime)
+		}
+		if limit := bc.genesis.Consensus.MaxBlockTransactions; limit > 0 && len(block.Transactions) > limit {
+			return fmt.Errorf("block %d has %d transactions, the limit is %d", i, len(block.Transactions), limit)
+		}
+		if gas, limit := blockGas(block.Transactions), bc.genesis.Consensus.MaxBlockGas; gas > limit {
+			return fmt.Errorf("block %d has transactions with %d gas in total, the limit is %d", i, gas, limit)
 		}
 		if !bc.ValidProof(prevBlock.Proof, block.Proof, block.PreviousHash) {
-			logger.Errorf("Block %d has invalid proof of work", i)
-			return false
+			return fmt.Errorf("block %d has invalid proof of work", i)
+		}
+		receipts := []Receipt{}
+		for _, transaction := range block.Transactions {
+			if err := validateTransaction(transaction, bc.genesis); err != nil {
+				return fmt.Errorf("block %d: %w", i, err)
+			}
+			if !transaction.Unlocked(block.Index, block.Timestamp) {
+				return fmt.Errorf("block %d: %w: transaction %s can't be included before block %d and time %d", i, ErrTransactionLocked, transaction.ID, transaction.LockHeight, transaction.LockTime)
+			}
+			receipt, err := state.applyTransaction(transaction)
+			if err != nil {
+				return fmt.Errorf("block %d: transaction %s: %w", i, transaction.ID, err)
+			}
+			if receipt != nil {
+				receipts = append(receipts, *receipt)
+			}
+		}
+		if expected := receiptsJSON(receipts); !bytes.Equal(expected, receiptsJSON(block.Receipts)) {
+			return fmt.Errorf("block %d has receipts that don't match its execution: expected %s", i, expected)
 		}
 		logger.Infof("Block %d validated: %s", i, block.Hash)
 	}
-	return true
+	return nil
+}
+
+// medianTime returns the median timestamp of the last MedianTimeBlocks blocks of chain
+func medianTime(chain []Block) int64 {
+	timestamps := []int64{}
+	for _, block := range chain[max(0, len(chain)-MedianTimeBlocks):] {
+		timestamps = append(timestamps, block.Timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
 }
 
 // RegisterNode adds a new node to the list of nodes
 func (bc *Blockchain) RegisterNode(address string) {
-	bc.Nodes[address] = true
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if !bc.Nodes[address] {
+		bc.Nodes[address] = true
+		bc.events.Publish(EventPeerAdded, address)
+	}
+}
+
+// RemoveNode forgets a node, its chain isn't fetched anymore when resolving conflicts
+func (bc *Blockchain) RemoveNode(address string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.Nodes[address] {
+		delete(bc.Nodes, address)
+		bc.events.Publish(EventPeerRemoved, address)
+	}
+}
+
+// Peers returns the addresses of the registered nodes, sorted
+func (bc *Blockchain) Peers() []string {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	nodes := make([]string, 0, len(bc.Nodes))
+	for node := range bc.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
 }
 
 // ResolveConflicts is our Consensus Algorithm
 func (bc *Blockchain) ResolveConflicts() bool {
-	var newChain []Block
+	bc.mu.Lock()
+	nodes := make([]string, 0, len(bc.Nodes))
+	for node := range bc.Nodes {
+		nodes = append(nodes, node)
+	}
 	maxLength := len(bc.Chain)
+	client := bc.client
+	bc.mu.Unlock()
+	// Ask the peers in a stable order, so which of two equally long chains wins doesn't depend on map iteration
+	sort.Strings(nodes)
 
-	for node := range bc.Nodes {
+	var newChain []Block
+	for _, node := range nodes {
 		// Fetch the chain from the node
-		response, err := http.Get(fmt.Sprintf("http://%s/chain", node))
-		if err != nil || response.StatusCode != http.StatusOK {
-			// If there is an error, skip this node
-			continue
-		}
-
-		defer response.Body.Close()
-		var result struct {
-			Length int     `json:"length"`
-			Chain  []Block `json:"chain"`
-		}
-		err = json.NewDecoder(response.Body).Decode(&result)
+		result, err := fetchChain(client, node)
 		if err != nil {
-			// If we can't decode the chain, skip this node
+			// If there is an error, skip this node
+			logger.Infof("Chain of node %s couldn't be fetched: %v", node, err)
 			continue
 		}
 
@@ -204,24 +694,76 @@ func (bc *Blockchain) ResolveConflicts() bool {
 		// Verify if the chain is valid and longer than the current one
 		if result.Length > maxLength {
 			logger.Infof("Chain is longer than current chain. Verifying validity...")
-			if bc.ValidChain(result.Chain) {
+			if err := bc.validateChain(result.Chain); err == nil {
 				// Found a longer valid chain, replace the current chain
 				maxLength = result.Length
 				newChain = result.Chain
 				logger.Infof("New longer valid chain found, replacing current chain.")
 			} else {
-				logger.Infof("Received chain is invalid. Skipping replacement.")
+				logger.Infof("Received chain is invalid: %v. Skipping replacement.", err)
 			}
 		}
 	}
 
 	// If a new chain was found, replace the current chain
 	if len(newChain) > 0 {
-		logger.Infof("Replacing chain with new chain of length %d", len(newChain))
-		bc.Chain = newChain
-		return true
+		replaced, err := bc.adoptValidChain(newChain)
+		if err != nil {
+			logger.Errorf("Chain couldn't be replaced: %v", err)
+		}
+		return replaced
 	}
 
 	logger.Infof("No valid longer chain found. No replacement made.")
 	return false
 }
+
+// AdoptChain replaces our chain with chain if it is valid and longer, and tells if it did. It is how nodes
+// that exchange chains by other means than the HTTP API, like the simulator, apply the longest chain rule.
+func (bc *Blockchain) AdoptChain(chain []Block) (bool, error) {
+	if err := bc.validateChain(chain); err != nil {
+		return false, err
+	}
+	return bc.adoptValidChain(chain)
+}
+
+// adoptValidChain replaces our chain with a validated chain, unless ours grew as long while it was validated
+func (bc *Blockchain) adoptValidChain(chain []Block) (bool, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if len(chain) <= len(bc.Chain) {
+		logger.Infof("Chain has %d blocks, ours %d. No replacement made.", len(chain), len(bc.Chain))
+		return false, nil
+	}
+
+	logger.Infof("Replacing chain with new chain of length %d", len(chain))
+	if err := bc.replaceChain(chain); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// chainResponse is the body of /chain without query parameters
+type chainResponse struct {
+	Length int     `json:"length"`
+	Chain  []Block `json:"chain"`
+}
+
+// fetchChain downloads the whole chain of a node, registered either as host:port or as a URL
+func fetchChain(client *http.Client, node string) (chainResponse, error) {
+	var result chainResponse
+	base := node
+	if !strings.Contains(node, "://") {
+		base = "http://" + node
+	}
+	response, err := client.Get(strings.TrimSuffix(base, "/") + "/chain")
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("node answered %s", response.Status)
+	}
+	err = json.NewDecoder(response.Body).Decode(&result)
+	return result, err
+}
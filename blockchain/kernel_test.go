@@ -2,6 +2,7 @@ package blockchain_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -30,8 +31,8 @@ func TestNewBlockchain(t *testing.T) {
 	if genesisBlock.Index != 1 {
 		t.Errorf("expected genesis block index to be 1, got %d", genesisBlock.Index)
 	}
-	if genesisBlock.PreviousHash != "0000" {
-		t.Errorf("expected genesis block previous hash to be '0000', got %s", genesisBlock.PreviousHash)
+	if specHash := bc.Genesis().SpecHash(); genesisBlock.PreviousHash != specHash {
+		t.Errorf("expected genesis block previous hash to be the spec hash %s, got %s", specHash, genesisBlock.PreviousHash)
 	}
 	if genesisBlock.Hash == "" {
 		t.Error("expected genesis block hash to be non-empty")
@@ -87,6 +88,144 @@ func TestNewBlock(t *testing.T) {
 	}
 }
 
+// TestAddTransactionChainID verifies that transactions for another network are rejected.
+func TestAddTransactionChainID(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	if _, err := bc.AddTransaction(&blockchain.Transaction{ChainID: "other-network", Sender: "Alice", Recipient: "Bob", Amount: 10}); !errors.Is(err, blockchain.ErrWrongChain) {
+		t.Errorf("expected ErrWrongChain, got %v", err)
+	}
+
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10}); err != nil {
+		t.Fatalf("expected transaction without chain id to be accepted, got %v", err)
+	}
+	if bc.CurrentTransactions[0].ChainID != bc.ChainID() {
+		t.Errorf("expected transaction to be stamped with chain id %s, got %s", bc.ChainID(), bc.CurrentTransactions[0].ChainID)
+	}
+}
+
+// TestAddTransactionNonces verifies that transactions must carry the next nonce of their sender and can't be submitted twice.
+func TestAddTransactionNonces(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+
+	first := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10}
+	if _, err := bc.AddTransaction(&first); err != nil {
+		t.Fatalf("expected first transaction to be accepted, got %v", err)
+	}
+	if first.ID != first.ComputeID() {
+		t.Errorf("expected transaction id to be filled in, got %q", first.ID)
+	}
+
+	duplicate := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10}
+	if _, err := bc.AddTransaction(&duplicate); !errors.Is(err, blockchain.ErrDuplicateTransaction) {
+		t.Errorf("expected ErrDuplicateTransaction for a pending transaction, got %v", err)
+	}
+
+	skipped := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10, Nonce: 2}
+	if _, err := bc.AddTransaction(&skipped); !errors.Is(err, blockchain.ErrInvalidNonce) {
+		t.Errorf("expected ErrInvalidNonce for a skipped nonce, got %v", err)
+	}
+
+	next := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10, Nonce: 1}
+	if _, err := bc.AddTransaction(&next); err != nil {
+		t.Fatalf("expected next transaction to be accepted, got %v", err)
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	if nonce := bc.PendingNonce("Alice"); nonce != 2 {
+		t.Errorf("expected next nonce of Alice to be 2, got %d", nonce)
+	}
+
+	replayed := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10}
+	if _, err := bc.AddTransaction(&replayed); !errors.Is(err, blockchain.ErrDuplicateTransaction) {
+		t.Errorf("expected ErrDuplicateTransaction for a confirmed transaction, got %v", err)
+	}
+}
+
+// TestValidChainRejectsReplayedNonce verifies that a block can't reuse the nonce of a confirmed transaction.
+func TestValidChainRejectsReplayedNonce(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	bc.NewTransaction("Alice", "Bob", 10)
+	block := bc.NewBlock(bc.LastBlock().Hash)
+
+	// Forge a block replaying a different transaction with the same nonce
+	replay := blockchain.Transaction{ChainID: bc.ChainID(), Sender: "Alice", Recipient: "Mallory", Amount: 10}
+	replay.ID = replay.ComputeID()
+	forged := blockchain.Block{
+		ChainID:      bc.ChainID(),
+		Index:        block.Index + 1,
+		Timestamp:    time.Now().Unix(),
+		Transactions: []blockchain.Transaction{replay},
+		PreviousHash: block.Hash,
+		Proof:        bc.ProofOfWork(block.Proof, block.Hash),
+	}
+	forged.Hash = bc.Hash(forged)
+
+	if bc.ValidChain(append(bc.Chain, forged)) {
+		t.Error("expected chain with a replayed nonce to be invalid")
+	}
+}
+
+// TestTimeLockedTransaction verifies that locked transactions, and the ones following them, wait in the pending list.
+func TestTimeLockedTransaction(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	bc := blockchain.NewBlockchain(blockchain.WithClock(func() time.Time { return now }))
+
+	locked := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 10, LockHeight: 3}
+	index, err := bc.AddTransaction(&locked)
+	if err != nil {
+		t.Fatalf("expected locked transaction to be accepted, got %v", err)
+	}
+	if index != 3 {
+		t.Errorf("expected locked transaction to target block 3, got %d", index)
+	}
+	bc.NewTransaction("Alice", "Bob", 20)
+	bc.NewTransaction("Carol", "Bob", 30)
+	future := blockchain.Transaction{Sender: "Dave", Recipient: "Bob", Amount: 40, LockTime: now.Add(time.Hour).Unix()}
+	if _, err := bc.AddTransaction(&future); err != nil {
+		t.Fatalf("expected transaction locked in time to be accepted, got %v", err)
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	if len(block.Transactions) != 1 || block.Transactions[0].Sender != "Carol" {
+		t.Errorf("expected only Carol's transaction in block 2, got %v", block.Transactions)
+	}
+	if len(bc.CurrentTransactions) != 3 {
+		t.Errorf("expected 3 transactions to stay pending, got %d", len(bc.CurrentTransactions))
+	}
+
+	block = bc.NewBlock(bc.LastBlock().Hash)
+	if len(block.Transactions) != 2 || block.Transactions[0].ID != locked.ID {
+		t.Errorf("expected both of Alice's transactions in block 3, got %v", block.Transactions)
+	}
+	if len(bc.CurrentTransactions) != 1 || bc.CurrentTransactions[0].ID != future.ID {
+		t.Errorf("expected Dave's transaction to stay pending, got %v", bc.CurrentTransactions)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+
+	// A block including a transaction before its lock expires is invalid
+	last := bc.LastBlock()
+	premature := blockchain.Block{
+		ChainID:      bc.ChainID(),
+		Index:        last.Index + 1,
+		Timestamp:    last.Timestamp + 1,
+		Transactions: []blockchain.Transaction{future},
+		PreviousHash: last.Hash,
+		Proof:        bc.ProofOfWork(last.Proof, last.Hash),
+	}
+	premature.Hash = bc.Hash(premature)
+	if bc.ValidChain(append(bc.Chain, premature)) {
+		t.Error("expected chain with a premature transaction to be invalid")
+	}
+
+	now = now.Add(time.Hour)
+	if block := bc.NewBlock(bc.LastBlock().Hash); len(block.Transactions) != 1 || block.Transactions[0].ID != future.ID {
+		t.Errorf("expected Dave's transaction in block 4 once its lock expired, got %v", block.Transactions)
+	}
+}
+
 // TestLastBlock ensures the last block is correctly retrieved.
 func TestLastBlock(t *testing.T) {
 	bc := blockchain.NewBlockchain()
@@ -95,6 +234,10 @@ func TestLastBlock(t *testing.T) {
 	if bc.LastBlock().Index != block1.Index {
 		t.Errorf("expected last block index to be %d, got %d", block1.Index, bc.LastBlock().Index)
 	}
+	// A stale previous hash doesn't fork the chain, the block extends the last one
+	if block1.PreviousHash != bc.GenesisHash() {
+		t.Errorf("expected the block to extend the genesis block, got previous hash %s", block1.PreviousHash)
+	}
 	bc.NewTransaction("Bob", "Charlie", 50)
 	block2 := bc.NewBlock(block1.Hash)
 	if bc.LastBlock().Index != block2.Index {
@@ -181,31 +324,36 @@ func TestResolveConflicts(t *testing.T) {
 	bc := blockchain.NewBlockchain()
 	t.Logf("Blockchain initialized with length: %d", len(bc.Chain))
 
-	// Create a mock chain with real transactions
+	// Create a mock chain with real transactions, built on top of our genesis block
 	mockChain := []blockchain.Block{
+		bc.Chain[0],
 		{
-			Index:        1,
+			ChainID:      bc.ChainID(),
+			Index:        2,
 			Timestamp:    time.Now().Unix(),
-			Transactions: []blockchain.Transaction{{Sender: "Alice", Recipient: "Bob", Amount: 10}},
-			PreviousHash: "0000",
+			Transactions: []blockchain.Transaction{{ChainID: bc.ChainID(), Sender: "Alice", Recipient: "Bob", Amount: 10}},
+			PreviousHash: "",
 			Hash:         "",
 		},
 		{
-			Index:        2,
-			Timestamp:    time.Now().Unix(),
-			Transactions: []blockchain.Transaction{{Sender: "Bob", Recipient: "Charlie", Amount: 5}},
+			ChainID:      bc.ChainID(),
+			Index:        3,
+			Timestamp:    time.Now().Unix() + 1,
+			Transactions: []blockchain.Transaction{{ChainID: bc.ChainID(), Sender: "Bob", Recipient: "Charlie", Amount: 5}},
 			PreviousHash: "",
 			Hash:         "",
 		},
 	}
 
-	// Calculate hashes and proofs for mock chain
-	mockChain[0].Hash = bc.Hash(mockChain[0]) // Hash the first block
-	mockChain[0].Proof = bc.ProofOfWork(0, mockChain[0].PreviousHash)
-
-	mockChain[1].PreviousHash = mockChain[0].Hash // Set previous hash for second block
-	mockChain[1].Proof = bc.ProofOfWork(mockChain[0].Proof, mockChain[1].PreviousHash)
-	mockChain[1].Hash = bc.Hash(mockChain[1]) // Hash the second block
+	// Calculate transaction ids, hashes and proofs for mock chain
+	for i := 1; i < len(mockChain); i++ {
+		for j := range mockChain[i].Transactions {
+			mockChain[i].Transactions[j].ID = mockChain[i].Transactions[j].ComputeID()
+		}
+		mockChain[i].PreviousHash = mockChain[i-1].Hash // Set previous hash of the block
+		mockChain[i].Proof = bc.ProofOfWork(mockChain[i-1].Proof, mockChain[i].PreviousHash)
+		mockChain[i].Hash = bc.Hash(mockChain[i])
+	}
 
 	// Validate the proofs of the mock chain before sending it
 	for i := 1; i < len(mockChain); i++ {
@@ -255,3 +403,144 @@ func TestResolveConflicts(t *testing.T) {
 		t.Logf("Blockchain after conflict resolution: %v", bc.Chain)
 	}
 }
+
+// TestResolveConflictsNodeURL reaches nodes registered with a scheme as well as the ones registered as host:port.
+func TestResolveConflictsNodeURL(t *testing.T) {
+	peer := blockchain.NewBlockchain()
+	peer.NewBlock(peer.LastBlock().Hash)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chain" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"length": len(peer.Chain), "chain": peer.Chain})
+	}))
+	defer server.Close()
+
+	bc := blockchain.NewBlockchain()
+	bc.RegisterNode(server.URL + "/")
+	if !bc.ResolveConflicts() || bc.LatestBlock().Hash != peer.LatestBlock().Hash {
+		t.Errorf("expected the chain of %s to replace ours", server.URL)
+	}
+}
+
+// TestBlockTimestamps rejects blocks stamped before the median of the blocks before them, or too far in the future.
+func TestBlockTimestamps(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+	bc := blockchain.NewBlockchain(blockchain.WithDifficulty(1), blockchain.WithClock(func() time.Time { return now }))
+	for i := 0; i < 3; i++ {
+		bc.NewBlock(bc.LastBlock().Hash)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Fatal("expected blocks mined within the same second to be valid")
+	}
+
+	restamp := func(timestamp int64) []blockchain.Block {
+		chain := append([]blockchain.Block{}, bc.Chain...)
+		last := chain[len(chain)-1]
+		last.Timestamp = timestamp
+		last.Hash = bc.Hash(last)
+		chain[len(chain)-1] = last
+		return chain
+	}
+	// The median of the genesis block and blocks 2 and 3 is the timestamp of block 2
+	if bc.ValidChain(restamp(bc.Chain[1].Timestamp)) {
+		t.Error("expected a block stamped at the median of the blocks before it to be rejected")
+	}
+	if bc.ValidChain(restamp(now.Add(blockchain.MaxFutureBlockTime + time.Second).Unix())) {
+		t.Error("expected a block stamped too far in the future to be rejected")
+	}
+	if !bc.ValidChain(restamp(now.Add(blockchain.MaxFutureBlockTime).Unix())) {
+		t.Error("expected a block stamped within the allowed drift to be valid")
+	}
+}
+
+// TestAdoptChain adopts longer valid chains only.
+func TestAdoptChain(t *testing.T) {
+	at := time.Unix(1767225600, 0)
+	peer := blockchain.NewBlockchain(blockchain.WithClock(func() time.Time { return at }))
+	peer.NewBlock(peer.LastBlock().Hash)
+	peer.NewBlock(peer.LastBlock().Hash)
+	if peer.Chain[1].Timestamp != at.Unix() {
+		t.Errorf("expected block stamped at %d, got %d", at.Unix(), peer.Chain[1].Timestamp)
+	}
+
+	bc := blockchain.NewBlockchain()
+	bc.NewBlock(bc.LastBlock().Hash)
+	if adopted, err := bc.AdoptChain(peer.Chain); !adopted || err != nil || bc.LastBlock().Hash != peer.LastBlock().Hash {
+		t.Errorf("expected the longer chain to be adopted, got %v (%v)", adopted, err)
+	}
+	if adopted, err := bc.AdoptChain(peer.Chain[:2]); adopted || err != nil {
+		t.Errorf("expected a shorter chain to be ignored, got %v (%v)", adopted, err)
+	}
+
+	tampered := append([]blockchain.Block{}, peer.Chain...)
+	tampered = append(tampered, tampered[2])
+	if adopted, err := bc.AdoptChain(tampered); adopted || err == nil {
+		t.Errorf("expected an invalid chain to be rejected, got %v", adopted)
+	}
+}
+
+// TestForgedBlockIndex rejects a chain whose block claims a later index to release a time-locked transaction.
+func TestForgedBlockIndex(t *testing.T) {
+	peer := blockchain.NewBlockchain(blockchain.WithDifficulty(1))
+	locked := blockchain.Transaction{ChainID: peer.ChainID(), Sender: "Alice", Recipient: "Bob", Amount: 10, LockHeight: 1000}
+	if _, err := peer.AddTransaction(&locked); err != nil {
+		t.Fatalf("expected locked transaction to be accepted, got %v", err)
+	}
+	genesis := *peer.LastBlock()
+	forged := blockchain.Block{
+		ChainID:      peer.ChainID(),
+		Index:        1000,
+		Timestamp:    genesis.Timestamp + 1,
+		Transactions: []blockchain.Transaction{locked},
+		PreviousHash: genesis.Hash,
+		Proof:        peer.ProofOfWork(genesis.Proof, genesis.Hash),
+	}
+	forged.Hash = peer.Hash(forged)
+
+	bc := blockchain.NewBlockchain(blockchain.WithDifficulty(1))
+	if adopted, err := bc.AdoptChain([]blockchain.Block{genesis, forged}); adopted || err == nil {
+		t.Errorf("expected a block with a forged index to be rejected, got %v", adopted)
+	}
+	forged.Index = 2
+	forged.Hash = peer.Hash(forged)
+	if bc.ValidChain([]blockchain.Block{genesis, forged}) {
+		t.Error("expected the locked transaction to be rejected at its real index")
+	}
+}
+
+// TestDeepReorg replaces more blocks than the state keeps journals for, so the state is replayed.
+func TestDeepReorg(t *testing.T) {
+	mine := func(bc *blockchain.Blockchain, sender string, blocks int) {
+		for i := 0; i < blocks; i++ {
+			bc.NewTransaction(sender, "carol", 1)
+			bc.NewBlock(bc.LastBlock().Hash)
+		}
+	}
+	bc := blockchain.NewBlockchain(blockchain.WithDifficulty(1))
+	mine(bc, "alice", blockchain.UndoDepth+2)
+	peer := blockchain.NewBlockchain(blockchain.WithDifficulty(1))
+	mine(peer, "bob", blockchain.UndoDepth+5)
+
+	if adopted, err := bc.AdoptChain(peer.Chain); !adopted || err != nil {
+		t.Fatalf("expected the longer chain to be adopted, got %v (%v)", adopted, err)
+	}
+	balances := map[string]blockchain.Amount{"alice": 0, "bob": -blockchain.UndoDepth - 5, "carol": blockchain.UndoDepth + 5}
+	for holder, expected := range balances {
+		if balance, _ := bc.Balance(blockchain.DefaultNativeSymbol, holder); balance.Amount != expected {
+			t.Errorf("expected %s to hold %d, got %d", holder, expected, balance.Amount)
+		}
+	}
+	if len(bc.CurrentTransactions) != blockchain.UndoDepth+2 {
+		t.Errorf("expected the transfers of alice back in the pending ones, got %d", len(bc.CurrentTransactions))
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	if balance, _ := bc.Balance(blockchain.DefaultNativeSymbol, "alice"); balance.Amount != -blockchain.UndoDepth-2 {
+		t.Errorf("expected the transfers of alice mined again, got a balance of %d", balance.Amount)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+}
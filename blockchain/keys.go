@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// GenerateKey creates a new ed25519 key pair, hex encoded
+func GenerateKey() (publicKey string, privateKey string, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(public), hex.EncodeToString(private), nil
+}
+
+// AddressFromPublicKey derives the address owned by a hex encoded public key
+func AddressFromPublicKey(publicKey string) (string, error) {
+	public, err := decodePublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(public)
+	return hex.EncodeToString(hash[:20]), nil
+}
+
+// PublicKeyFromPrivateKey returns the hex encoded public key of a hex encoded private key
+func PublicKeyFromPrivateKey(privateKey string) (string, error) {
+	private, err := decodePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(private.Public().(ed25519.PublicKey)), nil
+}
+
+// Sign signs a message with a hex encoded private key and returns the hex encoded signature
+func Sign(privateKey string, message []byte) (string, error) {
+	private, err := decodePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(ed25519.Sign(private, message)), nil
+}
+
+// verifySignature checks a hex encoded signature of message against a hex encoded public key
+func verifySignature(publicKey string, message []byte, signature string) error {
+	public, err := decodePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(signature)
+	if err != nil || !ed25519.Verify(public, message, decoded) {
+		return fmt.Errorf("%w: signature doesn't match public key %s", ErrInvalidSignature, publicKey)
+	}
+	return nil
+}
+
+func decodePublicKey(publicKey string) (ed25519.PublicKey, error) {
+	public, err := hex.DecodeString(publicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: invalid public key %q", ErrInvalidSignature, publicKey)
+	}
+	return public, nil
+}
+
+func decodePrivateKey(privateKey string) (ed25519.PrivateKey, error) {
+	private, err := hex.DecodeString(privateKey)
+	if err != nil || len(private) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	return private, nil
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import "fmt"
+
+const (
+	TxStatusPending   = "pending"
+	TxStatusConfirmed = "confirmed"
+)
+
+// TransactionStatus is a transaction with where it stands. Block fields are only set once it is confirmed,
+// Confirmations counts its block and the ones mined on top of it. Position is the index of the transaction
+// in its block, or among the pending transactions.
+type TransactionStatus struct {
+	Transaction   Transaction `json:"transaction"`
+	Status        string      `json:"status"`
+	BlockIndex    int         `json:"block_index,omitempty"`
+	BlockHash     string      `json:"block_hash,omitempty"`
+	Position      int         `json:"position"`
+	Confirmations int         `json:"confirmations"`
+}
+
+// BlockByIndex returns the block at index, the genesis block being 1
+func (bc *Blockchain) BlockByIndex(index int) (Block, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.blockAt(index)
+}
+
+// blockAt returns the block at index, or false when the chain doesn't reach it
+func (bc *Blockchain) blockAt(index int) (Block, bool) {
+	if index < 1 || index > len(bc.Chain) {
+		return Block{}, false
+	}
+	return bc.Chain[index-1], true
+}
+
+// BlockByHash returns the block of the chain with the given hash
+func (bc *Blockchain) BlockByHash(hash string) (Block, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	index, ok := bc.hashes[hash]
+	if !ok {
+		return Block{}, false
+	}
+	return bc.blockAt(index)
+}
+
+// LatestBlock returns the last block of the chain
+func (bc *Blockchain) LatestBlock() Block {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return *bc.LastBlock()
+}
+
+// Transaction returns a confirmed or pending transaction, or ErrUnknownTransaction
+func (bc *Blockchain) Transaction(id string) (TransactionStatus, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if location, ok := bc.confirmed[id]; ok {
+		if block, ok := bc.blockAt(location.BlockIndex); ok && location.Position < len(block.Transactions) {
+			return TransactionStatus{
+				Transaction:   block.Transactions[location.Position],
+				Status:        TxStatusConfirmed,
+				BlockIndex:    location.BlockIndex,
+				BlockHash:     block.Hash,
+				Position:      location.Position,
+				Confirmations: len(bc.Chain) - location.BlockIndex + 1,
+			}, nil
+		}
+	}
+	for position, pending := range bc.CurrentTransactions {
+		if pending.ID == id {
+			return TransactionStatus{Transaction: pending, Status: TxStatusPending, Position: position}, nil
+		}
+	}
+	return TransactionStatus{}, fmt.Errorf("%w: %s", ErrUnknownTransaction, id)
+}
+
+// PendingTransactions returns the transactions waiting to be mined, in the order they will be
+func (bc *Blockchain) PendingTransactions() []Transaction {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return append([]Transaction{}, bc.CurrentTransactions...)
+}
+
+// BlockHeader is a block without its transactions and receipts
+type BlockHeader struct {
+	ChainID          string `json:"chain_id"`
+	Index            int    `json:"index"`
+	Timestamp        int64  `json:"timestamp"`
+	PreviousHash     string `json:"previous_hash"`
+	Proof            int    `json:"proof"`
+	Hash             string `json:"hash"`
+	TransactionCount int    `json:"transaction_count"`
+}
+
+// Header returns the header of the block
+func (b Block) Header() BlockHeader {
+	return BlockHeader{
+		ChainID:          b.ChainID,
+		Index:            b.Index,
+		Timestamp:        b.Timestamp,
+		PreviousHash:     b.PreviousHash,
+		Proof:            b.Proof,
+		Hash:             b.Hash,
+		TransactionCount: len(b.Transactions),
+	}
+}
+
+// Blocks returns the blocks from index from to index to, both included and clamped to the chain,
+// along with the length of the chain they were taken from
+func (bc *Blockchain) Blocks(from, to int) ([]Block, int) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	from, to = max(from, 1), min(to, len(bc.Chain))
+	if from > to {
+		return []Block{}, len(bc.Chain)
+	}
+	return append([]Block{}, bc.Chain[from-1:to]...), len(bc.Chain)
+}
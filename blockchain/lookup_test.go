@@ -0,0 +1,98 @@
+package blockchain_test
+
+import (
+	"errors"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestLookups finds blocks by index and hash, and transactions as they go from pending to confirmed.
+func TestLookups(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	transaction := blockchain.Transaction{Sender: "Ivan", Recipient: "Judy", Amount: 1}
+	if _, err := bc.AddTransaction(&transaction); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := bc.Transaction(transaction.ID)
+	if err != nil || status.Status != blockchain.TxStatusPending || status.Confirmations != 0 {
+		t.Errorf("expected pending transaction, got %+v (%v)", status, err)
+	}
+	if pending := bc.PendingTransactions(); len(pending) != 1 || pending[0].ID != transaction.ID {
+		t.Errorf("expected one pending transaction, got %+v", pending)
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	bc.NewBlock(bc.LastBlock().Hash)
+
+	status, err = bc.Transaction(transaction.ID)
+	if err != nil || status.Status != blockchain.TxStatusConfirmed || status.BlockHash != block.Hash || status.Confirmations != 2 {
+		t.Errorf("expected transaction confirmed twice in block %d, got %+v (%v)", block.Index, status, err)
+	}
+	if _, err := bc.Transaction("unknown"); !errors.Is(err, blockchain.ErrUnknownTransaction) {
+		t.Errorf("expected ErrUnknownTransaction, got %v", err)
+	}
+
+	if found, ok := bc.BlockByIndex(block.Index); !ok || found.Hash != block.Hash {
+		t.Errorf("expected block %d, got %+v", block.Index, found)
+	}
+	if found, ok := bc.BlockByHash(block.Hash); !ok || found.Index != block.Index {
+		t.Errorf("expected block with hash %s, got %+v", block.Hash, found)
+	}
+	if latest := bc.LatestBlock(); latest.Index != 3 {
+		t.Errorf("expected block 3 to be the latest, got %d", latest.Index)
+	}
+	for _, index := range []int{0, 4} {
+		if _, ok := bc.BlockByIndex(index); ok {
+			t.Errorf("expected no block %d", index)
+		}
+	}
+
+	// A longer chain whose block claims another index is never indexed
+	forged := append([]blockchain.Block{}, bc.Chain...)
+	last := forged[len(forged)-1]
+	next := blockchain.Block{ChainID: bc.ChainID(), Index: 1000, Timestamp: last.Timestamp + 1, PreviousHash: last.Hash, Proof: bc.ProofOfWork(last.Proof, last.Hash)}
+	next.Hash = bc.Hash(next)
+	if adopted, err := bc.AdoptChain(append(forged, next)); adopted || err == nil {
+		t.Errorf("expected a chain with a forged index to be rejected, got %v", adopted)
+	}
+	if _, ok := bc.BlockByHash(next.Hash); ok {
+		t.Error("expected no block with the forged hash")
+	}
+}
+
+// TestBlocks takes ranges of the chain and clamps them to its length.
+func TestBlocks(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	for i := 0; i < 3; i++ {
+		bc.NewBlock(bc.LastBlock().Hash)
+	}
+
+	tests := []struct {
+		from, to int
+		expected []int
+	}{
+		{1, 4, []int{1, 2, 3, 4}},
+		{2, 3, []int{2, 3}},
+		{0, 100, []int{1, 2, 3, 4}},
+		{5, 10, []int{}},
+	}
+	for _, test := range tests {
+		blocks, length := bc.Blocks(test.from, test.to)
+		if length != 4 || len(blocks) != len(test.expected) {
+			t.Errorf("expected blocks %v out of 4, got %d out of %d", test.expected, len(blocks), length)
+			continue
+		}
+		for i, block := range blocks {
+			if block.Index != test.expected[i] {
+				t.Errorf("expected block %d, got %d", test.expected[i], block.Index)
+			}
+		}
+	}
+
+	header := bc.LastBlock().Header()
+	if header.Hash != bc.LastBlock().Hash || header.TransactionCount != 0 {
+		t.Errorf("expected the header of the last block, got %+v", header)
+	}
+}
@@ -0,0 +1,232 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// MultisigAddressPrefix marks addresses controlled by a multisig account
+	MultisigAddressPrefix = "ms"
+	// MaxMultisigKeys caps the number of keys of a multisig account
+	MaxMultisigKeys = 16
+)
+
+// multisigAddressPattern matches the addresses of multisig accounts: the prefix and 20 hex encoded bytes.
+// Free-form addresses starting with the prefix, like msmith, are plain addresses.
+var multisigAddressPattern = regexp.MustCompile("^" + MultisigAddressPrefix + "[0-9a-f]{40}$")
+
+// MultisigAccount is an address controlled by a set of public keys, Threshold of them must sign its transactions
+type MultisigAccount struct {
+	PublicKeys []string `json:"public_keys"`
+	Threshold  int      `json:"threshold"`
+}
+
+// Signature is a hex encoded ed25519 signature of the signing bytes of a transaction
+type Signature struct {
+	PublicKey string `json:"public_key"`
+	Signature string `json:"signature"`
+}
+
+// PartialTransaction is a multisig transaction collecting signatures. It's submitted to the pending
+// transactions as soon as it reaches the threshold of its account.
+type PartialTransaction struct {
+	Transaction Transaction `json:"transaction"`
+	Signatures  int         `json:"signatures"`
+	Threshold   int         `json:"threshold"`
+	Submitted   bool        `json:"submitted"`
+	BlockIndex  int         `json:"block_index,omitempty"`
+}
+
+// Validate checks that the account can be satisfied
+func (m *MultisigAccount) Validate() error {
+	if len(m.PublicKeys) == 0 || len(m.PublicKeys) > MaxMultisigKeys {
+		return fmt.Errorf("%w: multisig accounts need between 1 and %d public keys, got %d", ErrInvalidTransaction, MaxMultisigKeys, len(m.PublicKeys))
+	}
+	if m.Threshold < 1 || m.Threshold > len(m.PublicKeys) {
+		return fmt.Errorf("%w: multisig threshold must be between 1 and %d, got %d", ErrInvalidTransaction, len(m.PublicKeys), m.Threshold)
+	}
+	seen := make(map[string]bool)
+	for _, publicKey := range m.PublicKeys {
+		if _, err := decodePublicKey(publicKey); err != nil {
+			return err
+		}
+		if seen[publicKey] {
+			return fmt.Errorf("%w: multisig public key %s is repeated", ErrInvalidTransaction, publicKey)
+		}
+		seen[publicKey] = true
+	}
+	return nil
+}
+
+// Address derives the address of the account. Keys are sorted so their order doesn't matter.
+func (m *MultisigAccount) Address() string {
+	keys := append([]string{}, m.PublicKeys...)
+	sort.Strings(keys)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", m.Threshold, strings.Join(keys, ","))))
+	return MultisigAddressPrefix + hex.EncodeToString(hash[:20])
+}
+
+// IsMultisigAddress tells whether address is controlled by a multisig account
+func IsMultisigAddress(address string) bool {
+	return multisigAddressPattern.MatchString(address)
+}
+
+// Sign adds the signature of privateKey to an account transaction
+func (t *Transaction) Sign(privateKey string) error {
+	publicKey, err := PublicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	signature, err := Sign(privateKey, t.SigningBytes())
+	if err != nil {
+		return err
+	}
+	t.Signatures = append(t.Signatures, Signature{PublicKey: publicKey, Signature: signature})
+	return nil
+}
+
+// validateSignatures checks the signatures of an account transaction. Multisig senders need at least
+// the threshold of their account, other senders may sign with the key their address derives from.
+func validateSignatures(transaction Transaction) error {
+	if transaction.Multisig == nil {
+		if IsMultisigAddress(transaction.Sender) {
+			return fmt.Errorf("%w: sender %s needs its multisig account definition", ErrInvalidTransaction, transaction.Sender)
+		}
+		message := transaction.SigningBytes()
+		for _, signature := range transaction.Signatures {
+			if address, err := AddressFromPublicKey(signature.PublicKey); err != nil || address != transaction.Sender {
+				return fmt.Errorf("%w: public key %s doesn't own %s", ErrInvalidSignature, signature.PublicKey, transaction.Sender)
+			}
+			if err := verifySignature(signature.PublicKey, message, signature.Signature); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	signatures, err := countMultisigSignatures(transaction)
+	if err != nil {
+		return err
+	}
+	if signatures < transaction.Multisig.Threshold {
+		return fmt.Errorf("%w: %d of %d required signatures", ErrNotEnoughSignatures, signatures, transaction.Multisig.Threshold)
+	}
+	return nil
+}
+
+// countMultisigSignatures checks that every signature of a multisig transaction is valid and comes
+// from a different key of the account, and returns how many there are
+func countMultisigSignatures(transaction Transaction) (int, error) {
+	account := transaction.Multisig
+	if err := account.Validate(); err != nil {
+		return 0, err
+	}
+	if account.Address() != transaction.Sender {
+		return 0, fmt.Errorf("%w: multisig account resolves to %s, not to sender %s", ErrInvalidTransaction, account.Address(), transaction.Sender)
+	}
+
+	members := make(map[string]bool)
+	for _, publicKey := range account.PublicKeys {
+		members[publicKey] = true
+	}
+	message := transaction.SigningBytes()
+	signed := make(map[string]bool)
+	for _, signature := range transaction.Signatures {
+		if !members[signature.PublicKey] {
+			return 0, fmt.Errorf("%w: public key %s isn't part of the multisig account", ErrInvalidSignature, signature.PublicKey)
+		}
+		if signed[signature.PublicKey] {
+			return 0, fmt.Errorf("%w: public key %s signed twice", ErrInvalidSignature, signature.PublicKey)
+		}
+		if err := verifySignature(signature.PublicKey, message, signature.Signature); err != nil {
+			return 0, err
+		}
+		signed[signature.PublicKey] = true
+	}
+	return len(signed), nil
+}
+
+// NewPartialTransaction stores a multisig transaction so the signers of its account can add their signatures
+func (bc *Blockchain) NewPartialTransaction(transaction *Transaction) (PartialTransaction, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	if transaction.Multisig == nil {
+		return PartialTransaction{}, fmt.Errorf("%w: partial transactions need a multisig account", ErrInvalidTransaction)
+	}
+	if transaction.ChainID == "" {
+		transaction.ChainID = bc.ChainID()
+	}
+	if transaction.ID == "" {
+		transaction.ID = transaction.ComputeID()
+	}
+	if err := validateTransaction(*transaction, bc.genesis); err != nil && !errors.Is(err, ErrNotEnoughSignatures) {
+		return PartialTransaction{}, err
+	}
+	if _, ok := bc.partials[transaction.ID]; ok {
+		return PartialTransaction{}, fmt.Errorf("%w: partial transaction %s already exists", ErrDuplicateTransaction, transaction.ID)
+	}
+
+	partial := *transaction
+	bc.partials[partial.ID] = &partial
+	return bc.submitPartialTransaction(partial.ID)
+}
+
+// AddPartialSignature adds a signature to a partial transaction, submitting it once it has enough of them
+func (bc *Blockchain) AddPartialSignature(id string, signature Signature) (PartialTransaction, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	partial, ok := bc.partials[id]
+	if !ok {
+		return PartialTransaction{}, fmt.Errorf("%w: no partial transaction %s", ErrUnknownTransaction, id)
+	}
+
+	signed := *partial
+	signed.Signatures = append(append([]Signature{}, partial.Signatures...), signature)
+	if _, err := countMultisigSignatures(signed); err != nil {
+		return PartialTransaction{}, err
+	}
+
+	*partial = signed
+	return bc.submitPartialTransaction(id)
+}
+
+// PartialTransactions returns the multisig transactions still collecting signatures, ordered by ID
+func (bc *Blockchain) PartialTransactions() []PartialTransaction {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	partials := []PartialTransaction{}
+	for _, transaction := range bc.partials {
+		signatures, _ := countMultisigSignatures(*transaction)
+		partials = append(partials, PartialTransaction{Transaction: *transaction, Signatures: signatures, Threshold: transaction.Multisig.Threshold})
+	}
+	sort.Slice(partials, func(i, j int) bool { return partials[i].Transaction.ID < partials[j].Transaction.ID })
+	return partials
+}
+
+// submitPartialTransaction moves a partial transaction to the pending ones if it reached its threshold
+func (bc *Blockchain) submitPartialTransaction(id string) (PartialTransaction, error) {
+	transaction := bc.partials[id]
+	signatures, _ := countMultisigSignatures(*transaction)
+	partial := PartialTransaction{Transaction: *transaction, Signatures: signatures, Threshold: transaction.Multisig.Threshold}
+	if signatures < partial.Threshold {
+		return partial, nil
+	}
+
+	index, err := bc.addTransaction(transaction)
+	if err != nil {
+		return partial, err
+	}
+	delete(bc.partials, id)
+	partial.Submitted = true
+	partial.BlockIndex = index
+	return partial, nil
+}
@@ -0,0 +1,144 @@
+package blockchain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type signer struct {
+	publicKey  string
+	privateKey string
+}
+
+func newSigners(t *testing.T, n int) []signer {
+	signers := make([]signer, n)
+	for i := range signers {
+		publicKey, privateKey, err := blockchain.GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		signers[i] = signer{publicKey: publicKey, privateKey: privateKey}
+	}
+	return signers
+}
+
+func signatureOf(t *testing.T, s signer, transaction blockchain.Transaction) blockchain.Signature {
+	signature, err := blockchain.Sign(s.privateKey, transaction.SigningBytes())
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return blockchain.Signature{PublicKey: s.publicKey, Signature: signature}
+}
+
+// TestMultisigAddress verifies that the address doesn't depend on the order of the keys.
+func TestMultisigAddress(t *testing.T) {
+	signers := newSigners(t, 2)
+	account := blockchain.MultisigAccount{PublicKeys: []string{signers[0].publicKey, signers[1].publicKey}, Threshold: 2}
+	reordered := blockchain.MultisigAccount{PublicKeys: []string{signers[1].publicKey, signers[0].publicKey}, Threshold: 2}
+	lowerThreshold := blockchain.MultisigAccount{PublicKeys: account.PublicKeys, Threshold: 1}
+
+	if account.Address() != reordered.Address() {
+		t.Error("expected key order not to change the address")
+	}
+	if account.Address() == lowerThreshold.Address() {
+		t.Error("expected the threshold to change the address")
+	}
+	if !blockchain.IsMultisigAddress(account.Address()) {
+		t.Errorf("expected %s to be a multisig address", account.Address())
+	}
+	if blockchain.IsMultisigAddress("msmith") {
+		t.Error("expected a free-form address starting with the prefix not to be a multisig address")
+	}
+	bc := blockchain.NewBlockchain()
+	if _, err := bc.AddTransaction(&blockchain.Transaction{Sender: "msmith", Recipient: "raul", Amount: 1}); err != nil {
+		t.Errorf("expected msmith to send without a multisig account, got %v", err)
+	}
+	if err := (&blockchain.MultisigAccount{PublicKeys: account.PublicKeys, Threshold: 3}).Validate(); err == nil {
+		t.Error("expected a threshold above the number of keys to be rejected")
+	}
+}
+
+// TestPartialTransaction walks a 2-of-3 transaction from creation to confirmation.
+func TestPartialTransaction(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	signers := newSigners(t, 3)
+	outsider := newSigners(t, 1)[0]
+	account := &blockchain.MultisigAccount{
+		PublicKeys: []string{signers[0].publicKey, signers[1].publicKey, signers[2].publicKey},
+		Threshold:  2,
+	}
+
+	transaction := blockchain.Transaction{ChainID: bc.ChainID(), Sender: account.Address(), Recipient: "Bob", Amount: 10, Multisig: account}
+	transaction.Signatures = []blockchain.Signature{signatureOf(t, signers[0], transaction)}
+
+	// Multisig transactions below the threshold can't be submitted directly
+	direct := transaction
+	if _, err := bc.AddTransaction(&direct); !errors.Is(err, blockchain.ErrNotEnoughSignatures) {
+		t.Errorf("expected ErrNotEnoughSignatures, got %v", err)
+	}
+
+	partial, err := bc.NewPartialTransaction(&transaction)
+	if err != nil {
+		t.Fatalf("failed to create partial transaction: %v", err)
+	}
+	if partial.Submitted || partial.Signatures != 1 || partial.Threshold != 2 {
+		t.Errorf("expected 1 of 2 signatures and no submission, got %+v", partial)
+	}
+
+	if _, err := bc.AddPartialSignature(transaction.ID, signatureOf(t, signers[0], transaction)); !errors.Is(err, blockchain.ErrInvalidSignature) {
+		t.Errorf("expected a repeated signer to be rejected, got %v", err)
+	}
+	if _, err := bc.AddPartialSignature(transaction.ID, signatureOf(t, outsider, transaction)); !errors.Is(err, blockchain.ErrInvalidSignature) {
+		t.Errorf("expected a signer outside the account to be rejected, got %v", err)
+	}
+	if _, err := bc.AddPartialSignature("unknown", signatureOf(t, signers[1], transaction)); !errors.Is(err, blockchain.ErrUnknownTransaction) {
+		t.Errorf("expected ErrUnknownTransaction, got %v", err)
+	}
+
+	partial, err = bc.AddPartialSignature(transaction.ID, signatureOf(t, signers[2], transaction))
+	if err != nil {
+		t.Fatalf("failed to add signature: %v", err)
+	}
+	if !partial.Submitted || partial.Signatures != 2 {
+		t.Errorf("expected the transaction to be submitted with 2 signatures, got %+v", partial)
+	}
+	if len(bc.PartialTransactions()) != 0 || len(bc.CurrentTransactions) != 1 {
+		t.Errorf("expected the transaction to move to the pending ones, got %d partial and %d pending", len(bc.PartialTransactions()), len(bc.CurrentTransactions))
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain with a multisig transaction to be valid")
+	}
+}
+
+// TestSignedTransaction verifies that signatures on single key transactions must come from the sender's key.
+func TestSignedTransaction(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	owner, other := newSigners(t, 1)[0], newSigners(t, 1)[0]
+	address, _ := blockchain.AddressFromPublicKey(owner.publicKey)
+
+	signed := blockchain.Transaction{ChainID: bc.ChainID(), Sender: address, Recipient: "Bob", Amount: 10}
+	if err := signed.Sign(other.privateKey); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if _, err := bc.AddTransaction(&signed); !errors.Is(err, blockchain.ErrInvalidSignature) {
+		t.Errorf("expected a signature from another key to be rejected, got %v", err)
+	}
+
+	signed.Signatures = nil
+	if err := signed.Sign(owner.privateKey); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if _, err := bc.AddTransaction(&signed); err != nil {
+		t.Errorf("expected transaction signed by its sender to be accepted, got %v", err)
+	}
+
+	unsigned := blockchain.Transaction{Sender: blockchain.MultisigAddressPrefix + strings.Repeat("0", 40), Recipient: "Bob", Amount: 10}
+	if _, err := bc.AddTransaction(&unsigned); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+		t.Errorf("expected a multisig sender without account to be rejected, got %v", err)
+	}
+}
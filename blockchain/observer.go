@@ -0,0 +1,19 @@
+package blockchain
+
+// BlockObserver is notified of the blocks connected to and disconnected from the end of the chain.
+// Calls are made in chain order with the chain locked, so observers must not call back into the Blockchain.
+type BlockObserver interface {
+	BlockConnected(block Block)
+	BlockDisconnected(block Block)
+}
+
+// AddObserver registers an observer and replays to it the blocks already in the chain
+func (bc *Blockchain) AddObserver(observer BlockObserver) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	for _, block := range bc.Chain {
+		observer.BlockConnected(block)
+	}
+	bc.observers = append(bc.observers, observer)
+}
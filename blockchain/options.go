@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Option customizes a blockchain when it is created. Blockchains only accept the chains of nodes created with
+// the same difficulty, hasher and proof strategy.
+type Option func(*Blockchain)
+
+// Hasher digests data. Blocks and proofs of work are hashed with it.
+type Hasher func(data []byte) []byte
+
+// SHA256 is the default hasher
+func SHA256(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// ProofStrategy finds and checks the proofs of work that let blocks extend the chain
+type ProofStrategy interface {
+	// Prove returns a proof for the block following the one with lastProof and previousHash
+	Prove(lastProof int, previousHash string) int
+	// Valid tells if proof is a proof for the block following the one with lastProof and previousHash
+	Valid(lastProof, proof int, previousHash string) bool
+}
+
+// LeadingZerosProof is the default strategy: the hex encoded hash of the last proof, the proof and the previous
+// hash must start with Difficulty zeros. Proofs are searched from 0 upwards, so the same block always gets the
+// same proof.
+type LeadingZerosProof struct {
+	Difficulty int
+	Hash       Hasher
+}
+
+func (p LeadingZerosProof) Prove(lastProof int, previousHash string) int {
+	proof := 0
+	for !p.Valid(lastProof, proof, previousHash) {
+		proof++
+	}
+	return proof
+}
+
+func (p LeadingZerosProof) Valid(lastProof, proof int, previousHash string) bool {
+	guess := fmt.Sprintf("%d%d%s", lastProof, proof, previousHash)
+	return strings.HasPrefix(hex.EncodeToString(p.Hash([]byte(guess))), strings.Repeat("0", p.Difficulty))
+}
+
+// NoProof proves every block with 0 and accepts any proof, so blocks are mined instantly. It is meant for tests.
+type NoProof struct{}
+
+func (NoProof) Prove(int, string) int {
+	return 0
+}
+
+func (NoProof) Valid(int, int, string) bool {
+	return true
+}
+
+// WithClock sets the clock blocks take their timestamp from, time.Now by default
+func WithClock(now func() time.Time) Option {
+	return func(bc *Blockchain) {
+		bc.now = now
+	}
+}
+
+// WithDifficulty overrides the difficulty of the genesis spec, the genesis hash changes with it. It is ignored
+// by proof strategies other than the default one.
+func WithDifficulty(difficulty int) Option {
+	return func(bc *Blockchain) {
+		bc.difficulty = difficulty
+	}
+}
+
+// WithHasher sets the hash function of blocks and of the default proof strategy, SHA256 by default
+func WithHasher(hash Hasher) Option {
+	return func(bc *Blockchain) {
+		bc.hasher = hash
+	}
+}
+
+// WithProofStrategy replaces the default proof strategy, a LeadingZerosProof with the difficulty and hasher
+// of the blockchain
+func WithProofStrategy(strategy ProofStrategy) Option {
+	return func(bc *Blockchain) {
+		bc.proof = strategy
+	}
+}
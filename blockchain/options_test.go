@@ -0,0 +1,64 @@
+package blockchain_test
+
+import (
+	"crypto/sha512"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestReproducibleChains mines the same chain twice, instantly, with a fixed clock and no proof of work.
+func TestReproducibleChains(t *testing.T) {
+	at := time.Unix(1767225600, 0)
+	mine := func() *blockchain.Blockchain {
+		bc := blockchain.NewBlockchain(
+			blockchain.WithClock(func() time.Time { return at }),
+			blockchain.WithProofStrategy(blockchain.NoProof{}),
+		)
+		for i := 0; i < 100; i++ {
+			bc.NewTransaction("Rupert", "Sybil", 1)
+			bc.NewBlock(bc.LastBlock().Hash)
+		}
+		return bc
+	}
+
+	first, second := mine(), mine()
+	if first.LastBlock().Hash != second.LastBlock().Hash || first.Chain[1].Timestamp != at.Unix() {
+		t.Errorf("expected identical chains stamped from %d, got %+v and %+v", at.Unix(), first.LastBlock(), second.LastBlock())
+	}
+	if !first.ValidChain(second.Chain) {
+		t.Error("expected chains without proof of work to be valid for a blockchain without proof of work")
+	}
+	if adopted, err := blockchain.NewBlockchain().AdoptChain(first.Chain); adopted || err == nil {
+		t.Error("expected chains without proof of work to be rejected by the default proof strategy")
+	}
+}
+
+// TestDifficultyAndHasher mines with a lower difficulty and another hash function.
+func TestDifficultyAndHasher(t *testing.T) {
+	bc := blockchain.NewBlockchain(blockchain.WithDifficulty(1))
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	if bc.Difficulty() != 1 || !bc.ValidProof(bc.Chain[0].Proof, block.Proof, block.PreviousHash) {
+		t.Errorf("expected a proof valid at difficulty 1, got %d", block.Proof)
+	}
+
+	hasher := func(data []byte) []byte {
+		sum := sha512.Sum512_256(data)
+		return sum[:]
+	}
+	hashed := blockchain.NewBlockchain(blockchain.WithHasher(hasher), blockchain.WithDifficulty(1))
+	if hashed.GenesisHash() == bc.GenesisHash() {
+		t.Error("expected another hash function to give another genesis hash")
+	}
+	hashed.NewBlock(hashed.LastBlock().Hash)
+	if !hashed.ValidChain(hashed.Chain) || bc.ValidChain(hashed.Chain) {
+		t.Error("expected the chain to be valid with its own hash function only")
+	}
+
+	for _, difficulty := range []int{0, 65} {
+		if _, err := blockchain.NewBlockchainWithGenesis(blockchain.DefaultGenesis(), blockchain.WithDifficulty(difficulty)); err == nil {
+			t.Errorf("expected difficulty %d to be rejected", difficulty)
+		}
+	}
+}
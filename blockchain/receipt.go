@@ -0,0 +1,47 @@
+package blockchain
+
+import "fmt"
+
+// txLocation is where a confirmed transaction is in the chain
+type txLocation struct {
+	BlockIndex int
+	Position   int
+}
+
+// TransactionReceipt tells where a confirmed transaction was mined and what it did. Only contract
+// transactions can fail, their receipts come from the block, the others always succeed.
+type TransactionReceipt struct {
+	Receipt
+	BlockIndex int    `json:"block_index"`
+	BlockHash  string `json:"block_hash"`
+	Position   int    `json:"position"`
+}
+
+// Receipt returns the receipt of a confirmed transaction, or ErrUnknownTransaction
+// if it isn't in the chain, pending transactions included.
+func (bc *Blockchain) Receipt(id string) (TransactionReceipt, error) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	location, ok := bc.confirmed[id]
+	if !ok {
+		return TransactionReceipt{}, fmt.Errorf("%w: %s isn't confirmed", ErrUnknownTransaction, id)
+	}
+	block, ok := bc.blockAt(location.BlockIndex)
+	if !ok {
+		return TransactionReceipt{}, fmt.Errorf("%w: %s isn't confirmed", ErrUnknownTransaction, id)
+	}
+	receipt := TransactionReceipt{
+		Receipt:    Receipt{TransactionID: id, Status: ReceiptStatusSuccess},
+		BlockIndex: location.BlockIndex,
+		BlockHash:  block.Hash,
+		Position:   location.Position,
+	}
+	for _, blockReceipt := range block.Receipts {
+		if blockReceipt.TransactionID == id {
+			receipt.Receipt = blockReceipt
+			break
+		}
+	}
+	return receipt, nil
+}
@@ -0,0 +1,45 @@
+package blockchain_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// TestReceipt checks the receipts of a transfer and a contract call, before and after mining.
+func TestReceipt(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	transfer := blockchain.Transaction{Sender: "Carol", Recipient: "Dave", Amount: 3}
+	if _, err := bc.AddTransaction(&transfer); err != nil {
+		t.Fatal(err)
+	}
+	deploy := deployCounter(t, bc)
+
+	if _, err := bc.Receipt(transfer.ID); !errors.Is(err, blockchain.ErrUnknownTransaction) {
+		t.Errorf("expected pending transaction to have no receipt, got %v", err)
+	}
+
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	receipt, err := bc.Receipt(transfer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := blockchain.TransactionReceipt{
+		Receipt:    blockchain.Receipt{TransactionID: transfer.ID, Status: blockchain.ReceiptStatusSuccess},
+		BlockIndex: block.Index,
+		BlockHash:  block.Hash,
+	}
+	if !reflect.DeepEqual(receipt, expected) {
+		t.Errorf("expected %+v, got %+v", expected, receipt)
+	}
+
+	receipt, err = bc.Receipt(deploy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Position != 1 || receipt.ContractAddress != blockchain.ContractAddress(deploy.ID) || receipt.GasUsed == 0 {
+		t.Errorf("expected the deployment receipt of the block, got %+v", receipt)
+	}
+}
@@ -0,0 +1,164 @@
+package blockchain
+
+import "fmt"
+
+// State is the result of applying, in order, the transactions of every block of the chain.
+// Every change is recorded in a journal until it is committed, the journal of each committed
+// block is kept so the block can be disconnected when the chain is replaced.
+type State struct {
+	genesis *Genesis
+	// nonces holds the nonce the next transaction of each sender must carry
+	nonces map[string]uint64
+	// utxos is the UTXO set, only used in utxo ledger mode
+	utxos map[OutPoint]TxOutput
+	// assets holds every asset by symbol, balances the holdings of each address by asset symbol
+	assets   map[string]Asset
+	balances map[string]map[string]Amount
+	// contracts holds the deployed contracts by address, storage their key/value stores
+	contracts map[string]Contract
+	storage   map[string]map[int64]int64
+
+	journal []func()
+}
+
+func newState(genesis *Genesis) *State {
+	return &State{
+		genesis:   genesis,
+		nonces:    make(map[string]uint64),
+		utxos:     make(map[OutPoint]TxOutput),
+		assets:    make(map[string]Asset),
+		balances:  make(map[string]map[string]Amount),
+		contracts: make(map[string]Contract),
+		storage:   make(map[string]map[int64]int64),
+	}
+}
+
+// Nonce returns the nonce the next confirmed transaction of address must carry
+func (s *State) Nonce(address string) uint64 {
+	return s.nonces[address]
+}
+
+// applyGenesis creates the native asset and applies the allocations of the genesis block
+func (s *State) applyGenesis(block Block) {
+	supply, _ := s.genesis.supply() // Validate already checked the total fits in an Amount
+	s.setAsset(Asset{Symbol: s.genesis.NativeSymbol, Decimals: s.genesis.NativeDecimals, Supply: supply})
+	for _, transaction := range block.Transactions {
+		s.addOutputs(transaction)
+		if transaction.Recipient != "" {
+			s.setBalance(s.genesis.NativeSymbol, transaction.Recipient, transaction.Amount)
+		}
+	}
+}
+
+// applyTransaction updates the state with a transaction, or leaves it untouched if the transaction isn't valid.
+// Contract transactions return the receipt of their execution.
+func (s *State) applyTransaction(transaction Transaction) (*Receipt, error) {
+	if s.genesis.Consensus.LedgerMode == LedgerModeUTXO {
+		return nil, s.applyUTXOTransaction(transaction)
+	}
+
+	if expected := s.nonces[transaction.Sender]; transaction.Nonce != expected {
+		return nil, fmt.Errorf("%w: %s must use nonce %d, got %d", ErrInvalidNonce, transaction.Sender, expected, transaction.Nonce)
+	}
+
+	snapshot := s.snapshot()
+	var receipt *Receipt
+	var err error
+	switch transaction.Type {
+	case TxTypeTransfer:
+		err = s.transfer(s.assetOf(transaction), transaction.Sender, transaction.Recipient, transaction.Amount)
+	case TxTypeTokenCreate:
+		err = s.createToken(transaction.Sender, *transaction.Token)
+	case TxTypeTokenMint:
+		err = s.mint(transaction.Sender, transaction.Asset, transaction.Recipient, transaction.Amount)
+	case TxTypeTokenBurn:
+		err = s.burn(transaction.Sender, transaction.Asset, transaction.Amount)
+	case TxTypeDeploy, TxTypeCall:
+		receipt, err = s.applyContractTransaction(transaction)
+	}
+	if err != nil {
+		s.revert(snapshot)
+		return nil, err
+	}
+	s.setNonce(transaction.Sender, transaction.Nonce+1)
+	return receipt, nil
+}
+
+// snapshot returns a point of the journal the state can be reverted to
+func (s *State) snapshot() int {
+	return len(s.journal)
+}
+
+// revert undoes, newest first, every change made since snapshot
+func (s *State) revert(snapshot int) {
+	for i := len(s.journal) - 1; i >= snapshot; i-- {
+		s.journal[i]()
+	}
+	s.journal = s.journal[:snapshot]
+}
+
+// commit starts a new journal and returns the previous one, whose changes only rollback can undo
+func (s *State) commit() []func() {
+	journal := s.journal
+	s.journal = nil
+	return journal
+}
+
+// rollback undoes, newest first, the changes of a journal returned by commit
+func (s *State) rollback(journal []func()) {
+	for i := len(journal) - 1; i >= 0; i-- {
+		journal[i]()
+	}
+}
+
+func (s *State) setNonce(address string, nonce uint64) {
+	previous, existed := s.nonces[address]
+	s.journal = append(s.journal, func() {
+		if existed {
+			s.nonces[address] = previous
+		} else {
+			delete(s.nonces, address)
+		}
+	})
+	s.nonces[address] = nonce
+}
+
+func (s *State) setAsset(asset Asset) {
+	previous, existed := s.assets[asset.Symbol]
+	s.journal = append(s.journal, func() {
+		if existed {
+			s.assets[asset.Symbol] = previous
+		} else {
+			delete(s.assets, asset.Symbol)
+		}
+	})
+	s.assets[asset.Symbol] = asset
+}
+
+func (s *State) setBalance(symbol, address string, amount Amount) {
+	holders, ok := s.balances[symbol]
+	if !ok {
+		holders = make(map[string]Amount)
+		s.balances[symbol] = holders
+	}
+	previous, existed := holders[address]
+	s.journal = append(s.journal, func() {
+		if existed {
+			holders[address] = previous
+		} else {
+			delete(holders, address)
+		}
+	})
+	holders[address] = amount
+}
+
+func (s *State) addOutput(outPoint OutPoint, output TxOutput) {
+	s.journal = append(s.journal, func() { delete(s.utxos, outPoint) })
+	s.utxos[outPoint] = output
+}
+
+func (s *State) spendOutput(outPoint OutPoint) {
+	output := s.utxos[outPoint]
+	s.journal = append(s.journal, func() { s.utxos[outPoint] = output })
+	delete(s.utxos, outPoint)
+}
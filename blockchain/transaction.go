@@ -0,0 +1,103 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// SigningBytes returns the canonical encoding of a transaction: every field but the ID and the signatures.
+// It's what signatures commit to, and since it includes the chain ID a signature is only valid on one network.
+func (t Transaction) SigningBytes() []byte {
+	t.ID = ""
+	t.Signatures = nil
+	inputs := make([]TxInput, len(t.Inputs))
+	for i, input := range t.Inputs {
+		inputs[i] = TxInput{TxID: input.TxID, Index: input.Index}
+	}
+	t.Inputs = inputs
+	encoded, _ := json.Marshal(t) // A struct of strings, numbers and slices of them always marshals
+	return encoded
+}
+
+// ComputeID returns the SHA-256 hash of the canonical encoding of the transaction
+func (t Transaction) ComputeID() string {
+	hash := sha256.Sum256(t.SigningBytes())
+	return hex.EncodeToString(hash[:])
+}
+
+// Unlocked tells whether the transaction can be included in the block with the given index and timestamp
+func (t Transaction) Unlocked(blockIndex int, blockTimestamp int64) bool {
+	return blockIndex >= t.LockHeight && blockTimestamp >= t.LockTime
+}
+
+// validateTransaction runs the checks that don't depend on the state of the chain
+func validateTransaction(transaction Transaction, genesis *Genesis) error {
+	if transaction.ChainID != genesis.ChainID {
+		return fmt.Errorf("%w: transaction is for chain %s, this node runs %s", ErrWrongChain, transaction.ChainID, genesis.ChainID)
+	}
+	if transaction.Sender == GenesisSender {
+		return fmt.Errorf("%w: sender %s is reserved for the genesis block", ErrInvalidTransaction, GenesisSender)
+	}
+	if len(transaction.Data) > MaxDataSize {
+		return fmt.Errorf("%w: data is %d bytes, the limit is %d", ErrInvalidTransaction, len(transaction.Data), MaxDataSize)
+	}
+	if transaction.Amount < 0 {
+		return fmt.Errorf("%w: amount can't be negative, got %d", ErrInvalidTransaction, transaction.Amount)
+	}
+	if transaction.LockHeight < 0 || transaction.LockTime < 0 {
+		return fmt.Errorf("%w: lock height and lock time can't be negative", ErrInvalidTransaction)
+	}
+	if id := transaction.ComputeID(); transaction.ID != id {
+		return fmt.Errorf("%w: id %s doesn't match its content, expected %s", ErrInvalidTransaction, transaction.ID, id)
+	}
+
+	if genesis.Consensus.LedgerMode == LedgerModeUTXO {
+		return validateUTXOTransaction(transaction)
+	}
+	if len(transaction.Inputs) > 0 || len(transaction.Outputs) > 0 {
+		return fmt.Errorf("%w: inputs and outputs are only allowed in utxo ledger mode", ErrInvalidTransaction)
+	}
+
+	if transaction.Type != TxTypeDeploy && transaction.Type != TxTypeCall && (transaction.Code != "" || len(transaction.Args) > 0 || transaction.GasLimit != 0) {
+		return fmt.Errorf("%w: only contract transactions carry code, arguments or a gas limit", ErrInvalidTransaction)
+	}
+	if transaction.Type != TxTypeTokenCreate && transaction.Token != nil {
+		return fmt.Errorf("%w: only token creation transactions carry a token spec", ErrInvalidTransaction)
+	}
+	if transaction.Asset != "" && transaction.Type != TxTypeTransfer && transaction.Type != TxTypeTokenMint && transaction.Type != TxTypeTokenBurn {
+		return fmt.Errorf("%w: only transfers, mints and burns carry an asset", ErrInvalidTransaction)
+	}
+
+	switch transaction.Type {
+	case TxTypeTransfer:
+		if transaction.AnchorHash != "" {
+			return fmt.Errorf("%w: only anchor transactions carry an anchor hash", ErrInvalidTransaction)
+		}
+		if transaction.Asset != "" {
+			if err := validateSymbol(transaction.Asset); err != nil {
+				return err
+			}
+		}
+	case TxTypeTokenCreate:
+		if err := validateTokenCreation(transaction); err != nil {
+			return err
+		}
+	case TxTypeTokenMint, TxTypeTokenBurn:
+		if err := validateSupplyChange(transaction); err != nil {
+			return err
+		}
+	case TxTypeDeploy, TxTypeCall:
+		if err := validateContractTransaction(transaction, genesis); err != nil {
+			return err
+		}
+	case TxTypeAnchor:
+		if err := validateAnchor(transaction); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown transaction type %q", ErrInvalidTransaction, transaction.Type)
+	}
+	return validateSignatures(transaction)
+}
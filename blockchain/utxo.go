@@ -0,0 +1,162 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// LedgerModeAccount tracks balances per address, transactions move an amount from a sender to a recipient
+	LedgerModeAccount = "account"
+	// LedgerModeUTXO tracks unspent outputs, transactions spend previous outputs and create new ones
+	LedgerModeUTXO = "utxo"
+)
+
+// OutPoint references an output of a confirmed transaction
+type OutPoint struct {
+	TxID  string `json:"txid"`
+	Index int    `json:"index"`
+}
+
+// TxInput spends a previous output. The signature covers the signing bytes of the whole transaction
+// and the public key must own the address of the spent output.
+type TxInput struct {
+	TxID      string `json:"txid"`
+	Index     int    `json:"index"`
+	PublicKey string `json:"public_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// TxOutput assigns an amount to an address
+type TxOutput struct {
+	Address string `json:"address"`
+	Amount  Amount `json:"amount"`
+}
+
+// UnspentOutput is an output that can still be spent
+type UnspentOutput struct {
+	OutPoint
+	TxOutput
+}
+
+// OutPoint returns the output an input spends
+func (i TxInput) OutPoint() OutPoint {
+	return OutPoint{TxID: i.TxID, Index: i.Index}
+}
+
+// SignInput signs the input at index with the private key owning the output it spends.
+// Inputs can be signed in any order since signatures aren't part of the signing bytes.
+func (t *Transaction) SignInput(index int, privateKey string) error {
+	if index < 0 || index >= len(t.Inputs) {
+		return fmt.Errorf("transaction has no input %d", index)
+	}
+	publicKey, err := PublicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	signature, err := Sign(privateKey, t.SigningBytes())
+	if err != nil {
+		return err
+	}
+	t.Inputs[index].PublicKey = publicKey
+	t.Inputs[index].Signature = signature
+	return nil
+}
+
+// validateUTXOTransaction runs the checks of a UTXO transaction that don't depend on the state of the chain
+func validateUTXOTransaction(transaction Transaction) error {
+	if transaction.Sender != "" || transaction.Recipient != "" || transaction.Amount != 0 || transaction.Nonce != 0 {
+		return fmt.Errorf("%w: utxo transactions move funds through inputs and outputs only", ErrInvalidTransaction)
+	}
+	if transaction.Type != TxTypeTransfer || transaction.AnchorHash != "" {
+		return fmt.Errorf("%w: utxo transactions can only be transfers", ErrInvalidTransaction)
+	}
+	if transaction.Asset != "" || transaction.Token != nil {
+		return fmt.Errorf("%w: utxo transactions can only move the native asset", ErrInvalidTransaction)
+	}
+	if transaction.Multisig != nil || len(transaction.Signatures) > 0 {
+		return fmt.Errorf("%w: utxo transactions are signed per input", ErrInvalidTransaction)
+	}
+	if len(transaction.Inputs) == 0 || len(transaction.Outputs) == 0 {
+		return fmt.Errorf("%w: utxo transactions need at least one input and one output", ErrInvalidTransaction)
+	}
+
+	spent := make(map[OutPoint]bool)
+	for _, input := range transaction.Inputs {
+		if spent[input.OutPoint()] {
+			return fmt.Errorf("%w: output %s:%d is spent twice", ErrInvalidTransaction, input.TxID, input.Index)
+		}
+		spent[input.OutPoint()] = true
+	}
+	for i, output := range transaction.Outputs {
+		if output.Address == "" || output.Amount <= 0 {
+			return fmt.Errorf("%w: output %d needs an address and a positive amount", ErrInvalidTransaction, i)
+		}
+	}
+	return nil
+}
+
+// applyUTXOTransaction spends the inputs of a transaction and adds its outputs to the UTXO set
+func (s *State) applyUTXOTransaction(transaction Transaction) error {
+	message := transaction.SigningBytes()
+	inputs := make([]Amount, 0, len(transaction.Inputs))
+	for _, input := range transaction.Inputs {
+		output, ok := s.utxos[input.OutPoint()]
+		if !ok {
+			return fmt.Errorf("%w: output %s:%d doesn't exist or is already spent", ErrMissingInput, input.TxID, input.Index)
+		}
+		if address, err := AddressFromPublicKey(input.PublicKey); err != nil || address != output.Address {
+			return fmt.Errorf("%w: input %s:%d isn't signed by the owner of %s", ErrInvalidSignature, input.TxID, input.Index, output.Address)
+		}
+		if err := verifySignature(input.PublicKey, message, input.Signature); err != nil {
+			return err
+		}
+		inputs = append(inputs, output.Amount)
+	}
+
+	outputs := make([]Amount, 0, len(transaction.Outputs))
+	for _, output := range transaction.Outputs {
+		outputs = append(outputs, output.Amount)
+	}
+	totalIn, err := sumAmounts(inputs...)
+	if err != nil {
+		return err
+	}
+	totalOut, err := sumAmounts(outputs...)
+	if err != nil {
+		return err
+	}
+	if totalOut > totalIn {
+		return fmt.Errorf("%w: outputs spend %d but inputs only hold %d", ErrInvalidTransaction, totalOut, totalIn)
+	}
+
+	for _, input := range transaction.Inputs {
+		s.spendOutput(input.OutPoint())
+	}
+	s.addOutputs(transaction)
+	return nil
+}
+
+// addOutputs adds every output of a transaction to the UTXO set
+func (s *State) addOutputs(transaction Transaction) {
+	for i, output := range transaction.Outputs {
+		s.addOutput(OutPoint{TxID: transaction.ID, Index: i}, output)
+	}
+}
+
+// UnspentOutputs returns the outputs of address that can be spent, ordered by outpoint
+func (s *State) UnspentOutputs(address string) []UnspentOutput {
+	unspent := []UnspentOutput{}
+	for outPoint, output := range s.utxos {
+		if output.Address == address {
+			unspent = append(unspent, UnspentOutput{OutPoint: outPoint, TxOutput: output})
+		}
+	}
+	sort.Slice(unspent, func(i, j int) bool {
+		if unspent[i].TxID != unspent[j].TxID {
+			return unspent[i].TxID < unspent[j].TxID
+		}
+		return unspent[i].Index < unspent[j].Index
+	})
+	return unspent
+}
@@ -0,0 +1,150 @@
+package blockchain_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type wallet struct {
+	address    string
+	privateKey string
+}
+
+func newWallet(t *testing.T) wallet {
+	publicKey, privateKey, err := blockchain.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	address, err := blockchain.AddressFromPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("failed to derive address: %v", err)
+	}
+	return wallet{address: address, privateKey: privateKey}
+}
+
+// newUTXOBlockchain creates a utxo ledger where alice owns 100 coins in the genesis block
+func newUTXOBlockchain(t *testing.T, alice wallet) *blockchain.Blockchain {
+	genesis := blockchain.DefaultGenesis()
+	genesis.Consensus.LedgerMode = blockchain.LedgerModeUTXO
+	genesis.Alloc = map[string]blockchain.Amount{alice.address: 100}
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return bc
+}
+
+// spend builds a transaction signed by owner spending outPoint into outputs
+func spend(t *testing.T, owner wallet, outPoint blockchain.OutPoint, outputs ...blockchain.TxOutput) *blockchain.Transaction {
+	transaction := &blockchain.Transaction{
+		ChainID: blockchain.DefaultChainID,
+		Inputs:  []blockchain.TxInput{{TxID: outPoint.TxID, Index: outPoint.Index}},
+		Outputs: outputs,
+	}
+	if err := transaction.SignInput(0, owner.privateKey); err != nil {
+		t.Fatalf("failed to sign input: %v", err)
+	}
+	return transaction
+}
+
+// TestUTXOTransfer verifies that spending an output moves it to the recipients and returns the change.
+func TestUTXOTransfer(t *testing.T) {
+	alice, bob := newWallet(t), newWallet(t)
+	bc := newUTXOBlockchain(t, alice)
+
+	unspent := bc.UnspentOutputs(alice.address)
+	if len(unspent) != 1 || unspent[0].Amount != 100 {
+		t.Fatalf("expected alice to own a single output of 100, got %v", unspent)
+	}
+
+	transaction := spend(t, alice, unspent[0].OutPoint,
+		blockchain.TxOutput{Address: bob.address, Amount: 30},
+		blockchain.TxOutput{Address: alice.address, Amount: 70})
+	if _, err := bc.AddTransaction(transaction); err != nil {
+		t.Fatalf("expected transaction to be accepted, got %v", err)
+	}
+
+	// Spending the same output again is rejected while the first spend is pending
+	doubleSpend := spend(t, alice, unspent[0].OutPoint, blockchain.TxOutput{Address: bob.address, Amount: 100})
+	if _, err := bc.AddTransaction(doubleSpend); !errors.Is(err, blockchain.ErrMissingInput) {
+		t.Errorf("expected ErrMissingInput for a double spend, got %v", err)
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+
+	if unspent := bc.UnspentOutputs(bob.address); len(unspent) != 1 || unspent[0].Amount != 30 || unspent[0].TxID != transaction.ID {
+		t.Errorf("expected bob to own the 30 output of %s, got %v", transaction.ID, unspent)
+	}
+	if unspent := bc.UnspentOutputs(alice.address); len(unspent) != 1 || unspent[0].Amount != 70 {
+		t.Errorf("expected alice to own the 70 change output, got %v", unspent)
+	}
+	if !bc.ValidChain(bc.Chain) {
+		t.Error("expected chain to be valid")
+	}
+}
+
+// TestUTXORejectsInvalidSpends checks signatures, ownership and value conservation.
+func TestUTXORejectsInvalidSpends(t *testing.T) {
+	alice, mallory := newWallet(t), newWallet(t)
+	bc := newUTXOBlockchain(t, alice)
+	outPoint := bc.UnspentOutputs(alice.address)[0].OutPoint
+
+	stolen := spend(t, mallory, outPoint, blockchain.TxOutput{Address: mallory.address, Amount: 100})
+	if _, err := bc.AddTransaction(stolen); !errors.Is(err, blockchain.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature when spending someone else's output, got %v", err)
+	}
+
+	tampered := spend(t, alice, outPoint, blockchain.TxOutput{Address: alice.address, Amount: 10})
+	tampered.Outputs[0].Address = mallory.address
+	if _, err := bc.AddTransaction(tampered); !errors.Is(err, blockchain.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a tampered transaction, got %v", err)
+	}
+
+	inflated := spend(t, alice, outPoint, blockchain.TxOutput{Address: alice.address, Amount: 101})
+	if _, err := bc.AddTransaction(inflated); !errors.Is(err, blockchain.ErrInvalidTransaction) {
+		t.Errorf("expected ErrInvalidTransaction when outputs exceed inputs, got %v", err)
+	}
+
+	if bc.NewTransaction("Alice", "Bob", 10); len(bc.CurrentTransactions) != 0 {
+		t.Error("expected account transactions to be rejected in utxo mode")
+	}
+}
+
+// TestUTXOReorg verifies that replacing the chain disconnects our blocks and connects the ones of the peer.
+func TestUTXOReorg(t *testing.T) {
+	alice, bob, carol := newWallet(t), newWallet(t), newWallet(t)
+	local := newUTXOBlockchain(t, alice)
+	remote := newUTXOBlockchain(t, alice)
+	outPoint := local.UnspentOutputs(alice.address)[0].OutPoint
+
+	// Locally alice pays bob, while the longer remote chain has her paying carol with the same output
+	local.AddTransaction(spend(t, alice, outPoint, blockchain.TxOutput{Address: bob.address, Amount: 100}))
+	local.NewBlock(local.LastBlock().Hash)
+	remote.AddTransaction(spend(t, alice, outPoint, blockchain.TxOutput{Address: carol.address, Amount: 100}))
+	remote.NewBlock(remote.LastBlock().Hash)
+	remote.NewBlock(remote.LastBlock().Hash)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"length": len(remote.Chain), "chain": remote.Chain})
+	}))
+	defer server.Close()
+	local.RegisterNode(server.Listener.Addr().String())
+
+	if !local.ResolveConflicts() {
+		t.Fatal("expected chain to be replaced")
+	}
+	if unspent := local.UnspentOutputs(bob.address); len(unspent) != 0 {
+		t.Errorf("expected bob's output to be disconnected, got %v", unspent)
+	}
+	if unspent := local.UnspentOutputs(carol.address); len(unspent) != 1 || unspent[0].Amount != 100 {
+		t.Errorf("expected carol to own 100, got %v", unspent)
+	}
+	if len(local.CurrentTransactions) != 0 {
+		t.Errorf("expected the conflicting payment to bob to be dropped, got %v", local.CurrentTransactions)
+	}
+}
@@ -0,0 +1,134 @@
+package vm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Opcode is a single VM instruction. PUSH is followed by an 8 bytes big-endian operand, every other
+// opcode is one byte long.
+type Opcode byte
+
+const (
+	STOP Opcode = 0x00
+	PUSH Opcode = 0x01
+	POP  Opcode = 0x02
+	DUP  Opcode = 0x03
+	SWAP Opcode = 0x04
+
+	ADD Opcode = 0x10
+	SUB Opcode = 0x11
+	MUL Opcode = 0x12
+	DIV Opcode = 0x13
+	MOD Opcode = 0x14
+
+	LT  Opcode = 0x20
+	GT  Opcode = 0x21
+	EQ  Opcode = 0x22
+	NOT Opcode = 0x23
+
+	JUMP  Opcode = 0x30
+	JUMPI Opcode = 0x31
+
+	SLOAD  Opcode = 0x40
+	SSTORE Opcode = 0x41
+
+	ARG  Opcode = 0x50
+	ARGC Opcode = 0x51
+
+	LOG Opcode = 0x60
+
+	RETURN Opcode = 0x70
+	REVERT Opcode = 0x71
+)
+
+// operandSize is the length of the operand of PUSH
+const operandSize = 8
+
+var names = map[Opcode]string{
+	STOP: "STOP", PUSH: "PUSH", POP: "POP", DUP: "DUP", SWAP: "SWAP",
+	ADD: "ADD", SUB: "SUB", MUL: "MUL", DIV: "DIV", MOD: "MOD",
+	LT: "LT", GT: "GT", EQ: "EQ", NOT: "NOT",
+	JUMP: "JUMP", JUMPI: "JUMPI",
+	SLOAD: "SLOAD", SSTORE: "SSTORE",
+	ARG: "ARG", ARGC: "ARGC",
+	LOG:    "LOG",
+	RETURN: "RETURN", REVERT: "REVERT",
+}
+
+// gasCosts holds the gas charged for each opcode. Storage access is the most expensive because
+// it outlives the transaction.
+var gasCosts = map[Opcode]uint64{
+	MUL: 3, DIV: 3, MOD: 3,
+	JUMP: 2, JUMPI: 2,
+	SLOAD: 50, SSTORE: 100,
+	LOG: 20,
+}
+
+func (op Opcode) String() string {
+	if name, ok := names[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", byte(op))
+}
+
+// gas returns the cost of executing op
+func (op Opcode) gas() uint64 {
+	if cost, ok := gasCosts[op]; ok {
+		return cost
+	}
+	return 1
+}
+
+// Assemble turns whitespace separated mnemonics into bytecode, PUSH takes a decimal operand:
+// "PUSH 2 PUSH 3 ADD RETURN"
+func Assemble(source string) ([]byte, error) {
+	opcodes := make(map[string]Opcode, len(names))
+	for op, name := range names {
+		opcodes[name] = op
+	}
+
+	code := []byte{}
+	tokens := strings.Fields(source)
+	for i := 0; i < len(tokens); i++ {
+		op, ok := opcodes[strings.ToUpper(tokens[i])]
+		if !ok {
+			return nil, fmt.Errorf("unknown instruction %q", tokens[i])
+		}
+		code = append(code, byte(op))
+		if op != PUSH {
+			continue
+		}
+		if i++; i == len(tokens) {
+			return nil, fmt.Errorf("PUSH without operand")
+		}
+		value, err := strconv.ParseInt(tokens[i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PUSH operand %q", tokens[i])
+		}
+		code = binary.BigEndian.AppendUint64(code, uint64(value))
+	}
+	return code, nil
+}
+
+// Validate checks that code only holds known opcodes and that no PUSH operand is truncated.
+// It returns the offsets of the instructions, the only valid jump destinations.
+func Validate(code []byte) (map[int64]bool, error) {
+	instructions := make(map[int64]bool)
+	for pc := 0; pc < len(code); pc++ {
+		op := Opcode(code[pc])
+		if _, ok := names[op]; !ok {
+			return nil, fmt.Errorf("%w %s at %d", ErrInvalidOpcode, op, pc)
+		}
+		instructions[int64(pc)] = true
+		if op == PUSH {
+			if pc+operandSize >= len(code) {
+				return nil, fmt.Errorf("%w: truncated PUSH at %d", ErrInvalidOpcode, pc)
+			}
+			pc += operandSize
+		}
+	}
+	return instructions, nil
+}
@@ -0,0 +1,215 @@
+// Package vm implements a small deterministic stack machine running smart contracts.
+// Words are 64-bit signed integers, arithmetic wraps around and every instruction costs gas.
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MaxStackDepth is the number of words the stack can hold
+const MaxStackDepth = 1024
+
+var (
+	ErrOutOfGas        = errors.New("out of gas")
+	ErrStackUnderflow  = errors.New("stack underflow")
+	ErrStackOverflow   = errors.New("stack overflow")
+	ErrInvalidOpcode   = errors.New("invalid opcode")
+	ErrInvalidJump     = errors.New("invalid jump destination")
+	ErrDivisionByZero  = errors.New("division by zero")
+	ErrReverted        = errors.New("execution reverted")
+	ErrMissingStorage  = errors.New("no storage to access")
+	ErrInvalidArgument = errors.New("negative argument index")
+)
+
+// Storage is the persistent key/value store of a contract
+type Storage interface {
+	Load(key int64) int64
+	Store(key, value int64)
+}
+
+// Context holds what an execution can access
+type Context struct {
+	Args     []int64
+	Storage  Storage
+	GasLimit uint64
+}
+
+// Log is an event emitted by the LOG instruction
+type Log struct {
+	Topic int64 `json:"topic"`
+	Value int64 `json:"value"`
+}
+
+// Result is the outcome of an execution. GasUsed and Logs are set even when it fails.
+type Result struct {
+	GasUsed uint64
+	Return  int64
+	Logs    []Log
+}
+
+type machine struct {
+	code   []byte
+	pc     int
+	stack  []int64
+	ctx    Context
+	result Result
+}
+
+// Execute runs code until it stops, returns, fails or runs out of gas
+func Execute(code []byte, ctx Context) (Result, error) {
+	jumpDestinations, err := Validate(code)
+	if err != nil {
+		return Result{}, err
+	}
+
+	m := &machine{code: code, ctx: ctx}
+	for m.pc < len(m.code) {
+		op := Opcode(m.code[m.pc])
+		if m.result.GasUsed+op.gas() > ctx.GasLimit {
+			m.result.GasUsed = ctx.GasLimit
+			return m.result, fmt.Errorf("%w: %s at %d", ErrOutOfGas, op, m.pc)
+		}
+		m.result.GasUsed += op.gas()
+
+		next := m.pc + 1
+		switch op {
+		case STOP:
+			return m.result, nil
+		case PUSH:
+			err = m.push(int64(binary.BigEndian.Uint64(m.code[m.pc+1:])))
+			next += operandSize
+		case POP:
+			_, err = m.pop()
+		case DUP:
+			var a int64
+			if a, err = m.pop(); err == nil {
+				m.push(a)
+				err = m.push(a)
+			}
+		case SWAP:
+			err = m.binary(func(a, b int64) error { m.push(b); return m.push(a) })
+		case ADD:
+			err = m.binary(func(a, b int64) error { return m.push(a + b) })
+		case SUB:
+			err = m.binary(func(a, b int64) error { return m.push(a - b) })
+		case MUL:
+			err = m.binary(func(a, b int64) error { return m.push(a * b) })
+		case DIV, MOD:
+			err = m.binary(func(a, b int64) error {
+				switch {
+				case b == 0:
+					return ErrDivisionByZero
+				case op == DIV:
+					return m.push(a / b)
+				default:
+					return m.push(a % b)
+				}
+			})
+		case LT:
+			err = m.binary(func(a, b int64) error { return m.push(boolWord(a < b)) })
+		case GT:
+			err = m.binary(func(a, b int64) error { return m.push(boolWord(a > b)) })
+		case EQ:
+			err = m.binary(func(a, b int64) error { return m.push(boolWord(a == b)) })
+		case NOT:
+			var a int64
+			if a, err = m.pop(); err == nil {
+				m.push(boolWord(a == 0))
+			}
+		case JUMP, JUMPI:
+			var destination, condition int64 = 0, 1
+			if destination, err = m.pop(); err == nil && op == JUMPI {
+				condition, err = m.pop()
+			}
+			if err == nil && condition != 0 {
+				if !jumpDestinations[destination] {
+					return m.result, fmt.Errorf("%w: %d", ErrInvalidJump, destination)
+				}
+				next = int(destination)
+			}
+		case SLOAD:
+			var key int64
+			if ctx.Storage == nil {
+				err = ErrMissingStorage
+			} else if key, err = m.pop(); err == nil {
+				m.push(ctx.Storage.Load(key))
+			}
+		case SSTORE:
+			if ctx.Storage == nil {
+				err = ErrMissingStorage
+			} else {
+				err = m.binary(func(value, key int64) error { ctx.Storage.Store(key, value); return nil })
+			}
+		case ARG:
+			var index int64
+			if index, err = m.pop(); err == nil {
+				switch {
+				case index < 0:
+					err = ErrInvalidArgument
+				case index < int64(len(ctx.Args)):
+					m.push(ctx.Args[index])
+				default:
+					m.push(0)
+				}
+			}
+		case ARGC:
+			err = m.push(int64(len(ctx.Args)))
+		case LOG:
+			err = m.binary(func(value, topic int64) error {
+				m.result.Logs = append(m.result.Logs, Log{Topic: topic, Value: value})
+				return nil
+			})
+		case RETURN:
+			if m.result.Return, err = m.pop(); err == nil {
+				return m.result, nil
+			}
+		case REVERT:
+			return m.result, ErrReverted
+		}
+		if err != nil {
+			return m.result, fmt.Errorf("%s at %d: %w", op, m.pc, err)
+		}
+		m.pc = next
+	}
+	return m.result, nil
+}
+
+func (m *machine) push(word int64) error {
+	if len(m.stack) == MaxStackDepth {
+		return ErrStackOverflow
+	}
+	m.stack = append(m.stack, word)
+	return nil
+}
+
+func (m *machine) pop() (int64, error) {
+	if len(m.stack) == 0 {
+		return 0, ErrStackUnderflow
+	}
+	word := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return word, nil
+}
+
+// binary pops b, then a, and calls f with them. f can't overflow the stack since it pushes
+// at most the two words just popped.
+func (m *machine) binary(f func(a, b int64) error) error {
+	b, err := m.pop()
+	if err != nil {
+		return err
+	}
+	a, err := m.pop()
+	if err != nil {
+		return err
+	}
+	return f(a, b)
+}
+
+func boolWord(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,88 @@
+package vm_test
+
+import (
+	"errors"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain/vm"
+)
+
+type memoryStorage map[int64]int64
+
+func (s memoryStorage) Load(key int64) int64   { return s[key] }
+func (s memoryStorage) Store(key, value int64) { s[key] = value }
+
+func assemble(t *testing.T, source string) []byte {
+	t.Helper()
+	code, err := vm.Assemble(source)
+	if err != nil {
+		t.Fatalf("failed to assemble %q: %v", source, err)
+	}
+	return code
+}
+
+// TestExecute runs a loop adding up the arguments of the call.
+func TestExecute(t *testing.T) {
+	// i = ARGC; while i != 0 { i--; storage[0] += ARG(i) }; return storage[0]
+	// Offset 1 is the loop condition, 56 its exit
+	code := assemble(t, `
+		ARGC
+		DUP NOT PUSH 56 JUMPI
+		PUSH 1 SUB DUP ARG
+		PUSH 0 SLOAD ADD PUSH 0 SSTORE
+		PUSH 1 JUMP
+		POP PUSH 0 SLOAD RETURN`)
+
+	storage := memoryStorage{}
+	result, err := vm.Execute(code, vm.Context{Args: []int64{3, 4, 5}, Storage: storage, GasLimit: 10000})
+	if err != nil {
+		t.Fatalf("expected execution to succeed, got %v", err)
+	}
+	if result.Return != 12 || storage[0] != 12 {
+		t.Errorf("expected the sum 12, got %d with storage %v", result.Return, storage)
+	}
+	if result.GasUsed == 0 {
+		t.Error("expected gas to be used")
+	}
+}
+
+// TestLogs checks that LOG records a topic and a value.
+func TestLogs(t *testing.T) {
+	code := assemble(t, "PUSH 42 PUSH 7 LOG STOP")
+	result, err := vm.Execute(code, vm.Context{GasLimit: 100})
+	if err != nil {
+		t.Fatalf("expected execution to succeed, got %v", err)
+	}
+	if len(result.Logs) != 1 || result.Logs[0] != (vm.Log{Topic: 7, Value: 42}) {
+		t.Errorf("unexpected logs %+v", result.Logs)
+	}
+}
+
+// TestExecuteFailures verifies that invalid programs halt with the matching error.
+func TestExecuteFailures(t *testing.T) {
+	failures := map[string]struct {
+		source   string
+		gasLimit uint64
+		err      error
+	}{
+		"out of gas":       {"PUSH 1 PUSH 1 ADD", 2, vm.ErrOutOfGas},
+		"underflow":        {"PUSH 1 ADD", 100, vm.ErrStackUnderflow},
+		"invalid jump":     {"PUSH 1 JUMP", 100, vm.ErrInvalidJump},
+		"division by zero": {"PUSH 1 PUSH 0 DIV", 100, vm.ErrDivisionByZero},
+		"revert":           {"PUSH 1 REVERT", 100, vm.ErrReverted},
+		"no storage":       {"PUSH 1 SLOAD", 100, vm.ErrMissingStorage},
+	}
+	for name, failure := range failures {
+		_, err := vm.Execute(assemble(t, failure.source), vm.Context{GasLimit: failure.gasLimit})
+		if !errors.Is(err, failure.err) {
+			t.Errorf("%s: expected %v, got %v", name, failure.err, err)
+		}
+	}
+
+	if _, err := vm.Execute([]byte{0xff}, vm.Context{GasLimit: 100}); !errors.Is(err, vm.ErrInvalidOpcode) {
+		t.Errorf("expected ErrInvalidOpcode, got %v", err)
+	}
+	if _, err := vm.Execute([]byte{byte(vm.PUSH), 1}, vm.Context{GasLimit: 100}); !errors.Is(err, vm.ErrInvalidOpcode) {
+		t.Errorf("expected truncated PUSH to be rejected, got %v", err)
+	}
+}
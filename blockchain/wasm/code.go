@@ -0,0 +1,184 @@
+package wasm
+
+import "fmt"
+
+// Opcodes of the supported instructions
+const (
+	opUnreachable = 0x00
+	opNop         = 0x01
+	opBlock       = 0x02
+	opLoop        = 0x03
+	opIf          = 0x04
+	opElse        = 0x05
+	opEnd         = 0x0b
+	opBr          = 0x0c
+	opBrIf        = 0x0d
+	opBrTable     = 0x0e
+	opReturn      = 0x0f
+	opCall        = 0x10
+	opDrop        = 0x1a
+	opSelect      = 0x1b
+	opSelectTyped = 0x1c
+	opLocalGet    = 0x20
+	opLocalSet    = 0x21
+	opLocalTee    = 0x22
+	opGlobalGet   = 0x23
+	opGlobalSet   = 0x24
+	opI32Load     = 0x28
+	opI64Load     = 0x29
+	opI32Load8S   = 0x2c
+	opI64Load32U  = 0x35
+	opI32Store    = 0x36
+	opI64Store    = 0x37
+	opI32Store8   = 0x3a
+	opI64Store32  = 0x3e
+	opMemorySize  = 0x3f
+	opMemoryGrow  = 0x40
+	opI32Const    = 0x41
+	opI64Const    = 0x42
+	opI32Eqz      = 0x45
+	opI32Eq       = 0x46
+	opI32GeU      = 0x4f
+	opI64Eqz      = 0x50
+	opI64Eq       = 0x51
+	opI64GeU      = 0x5a
+	opI32Clz      = 0x67
+	opI32Rotr     = 0x78
+	opI64Clz      = 0x79
+	opI64Rotr     = 0x8a
+	opI32WrapI64  = 0xa7
+	opI64ExtendS  = 0xac
+	opI64ExtendU  = 0xad
+	opI32Extend8S = 0xc0
+	opI64Ext32S   = 0xc4
+)
+
+// MaxLocals caps the locals of a function, parameters included
+const MaxLocals = 1024
+
+// instruction is a decoded instruction. Structured instructions know where they end
+// so branches don't have to scan the code.
+type instruction struct {
+	op byte
+	// a and b hold the immediates: an index, a constant, the arity of a block or the offset of a memory access
+	a, b uint64
+	// end is the index of the matching end of block, loop, if and else, elseAt the index of the else of an if
+	end, elseAt int
+	// table holds the labels of br_table, the default one last
+	table []uint32
+}
+
+// decodeBody decodes the locals and instructions of a function, checking every index it references
+func (fn *function) decodeBody(body []byte, m *Module, types []FuncType) error {
+	r := &reader{data: body}
+	for _, group := range decodeVec(r, func() [2]uint32 { return [2]uint32{r.u32(), uint32(r.valueType())} }) {
+		if len(fn.typ.Params)+len(fn.locals)+int(group[0]) > MaxLocals {
+			return fmt.Errorf("%w: more than %d locals", ErrUnsupported, MaxLocals)
+		}
+		for i := uint32(0); i < group[0]; i++ {
+			fn.locals = append(fn.locals, ValueType(group[1]))
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	localCount := uint64(len(fn.typ.Params) + len(fn.locals))
+	open := []int{} // Indices of the blocks not ended yet
+	for r.err == nil {
+		if r.pos == len(r.data) {
+			return fmt.Errorf("%w: function body doesn't end", ErrInvalidModule)
+		}
+		in := instruction{op: r.byte(), end: -1, elseAt: -1}
+		index := len(fn.code)
+
+		switch op := in.op; {
+		case op == opUnreachable, op == opNop, op == opReturn, op == opDrop, op == opSelect:
+		case op == opBlock, op == opLoop, op == opIf:
+			switch blockType := r.byte(); {
+			case blockType == 0x40:
+			case ValueType(blockType) == I32 || ValueType(blockType) == I64:
+				in.a = 1
+			default:
+				return fmt.Errorf("%w: block type 0x%02x", ErrUnsupported, blockType)
+			}
+			if op == opLoop {
+				in.a = 0 // Branches to a loop carry no values since it has no parameters
+			}
+			open = append(open, index)
+		case op == opElse:
+			if len(open) == 0 || fn.code[open[len(open)-1]].op != opIf || fn.code[open[len(open)-1]].elseAt >= 0 {
+				return fmt.Errorf("%w: else without if", ErrInvalidModule)
+			}
+			fn.code[open[len(open)-1]].elseAt = index
+		case op == opEnd:
+			if len(open) == 0 {
+				if r.pos != len(r.data) {
+					return fmt.Errorf("%w: code after the end of the function", ErrInvalidModule)
+				}
+				fn.code = append(fn.code, in)
+				return nil
+			}
+			block := &fn.code[open[len(open)-1]]
+			block.end = index
+			if block.elseAt >= 0 {
+				fn.code[block.elseAt].end = index
+			}
+			open = open[:len(open)-1]
+		case op == opBr, op == opBrIf:
+			in.a = uint64(r.u32())
+			if in.a > uint64(len(open)) {
+				return fmt.Errorf("%w: branch to unknown label %d", ErrInvalidModule, in.a)
+			}
+		case op == opBrTable:
+			in.table = append(decodeVec(r, r.u32), r.u32())
+			for _, label := range in.table {
+				if int(label) > len(open) {
+					return fmt.Errorf("%w: branch to unknown label %d", ErrInvalidModule, label)
+				}
+			}
+		case op == opCall:
+			in.a = uint64(r.u32())
+		case op == opSelectTyped:
+			if types := r.valueTypes(); len(types) != 1 {
+				return fmt.Errorf("%w: typed select needs one type", ErrInvalidModule)
+			}
+		case op >= opLocalGet && op <= opLocalTee:
+			if in.a = uint64(r.u32()); in.a >= localCount {
+				return fmt.Errorf("%w: local %d doesn't exist", ErrInvalidModule, in.a)
+			}
+		case op == opGlobalGet, op == opGlobalSet:
+			if in.a = uint64(r.u32()); in.a >= uint64(len(m.globals)) {
+				return fmt.Errorf("%w: global %d doesn't exist", ErrInvalidModule, in.a)
+			}
+			if op == opGlobalSet && !m.globals[in.a].mutable {
+				return fmt.Errorf("%w: global %d is immutable", ErrInvalidModule, in.a)
+			}
+		case (op >= opI32Load && op <= opI64Load) || (op >= opI32Load8S && op <= opI64Store) || (op >= opI32Store8 && op <= opI64Store32):
+			r.u32() // The alignment is only a hint
+			in.a = uint64(r.u32())
+			fallthrough
+		case op == opMemorySize, op == opMemoryGrow:
+			if m.memory == nil {
+				return fmt.Errorf("%w: memory access without memory", ErrInvalidModule)
+			}
+			if op == opMemorySize || op == opMemoryGrow {
+				if r.byte() != 0x00 {
+					return fmt.Errorf("%w: unknown memory", ErrInvalidModule)
+				}
+			}
+		case op == opI32Const:
+			in.a = uint64(uint32(r.leb(32, true)))
+		case op == opI64Const:
+			in.a = r.leb(64, true)
+		case (op >= opI32Eqz && op <= opI32GeU) || (op >= opI64Eqz && op <= opI64GeU),
+			op >= opI32Clz && op <= opI64Rotr,
+			op == opI32WrapI64, op == opI64ExtendS, op == opI64ExtendU,
+			op >= opI32Extend8S && op <= opI64Ext32S:
+		default:
+			return fmt.Errorf("%w: instruction 0x%02x", ErrUnsupported, op)
+		}
+		fn.code = append(fn.code, in)
+	}
+	return r.err
+}
@@ -0,0 +1,573 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	// MaxStackSize is the number of values the operand stack can hold
+	MaxStackSize = 65536
+	// MaxCallDepth limits recursion
+	MaxCallDepth = 256
+)
+
+var (
+	// ErrTrap is returned when execution hits an error, like a division by zero or an out of bounds access
+	ErrTrap = errors.New("wasm trap")
+	// ErrOutOfFuel is returned when execution uses up its fuel
+	ErrOutOfFuel = errors.New("out of fuel")
+)
+
+// HostFunc is a function the host provides to a module. Fuel is charged on every call on top of
+// the instruction itself.
+type HostFunc struct {
+	Type FuncType
+	Fuel uint64
+	Call func(instance *Instance, args []uint64) ([]uint64, error)
+}
+
+// Instance is a module ready to run, with its own memory and globals
+type Instance struct {
+	module    *Module
+	imports   []HostFunc
+	memory    []byte
+	globals   []uint64
+	stack     []uint64
+	depth     int
+	fuel      uint64
+	fuelLimit uint64
+}
+
+type label struct {
+	arity  int
+	height int
+	// target is where a branch to the label continues
+	target int
+	loop   bool
+}
+
+// Instantiate links a module with host functions, keyed by "module.name", and runs its start function
+func (m *Module) Instantiate(hostFuncs map[string]HostFunc, fuelLimit uint64) (*Instance, error) {
+	instance := &Instance{module: m, fuelLimit: fuelLimit}
+	for _, imp := range m.Imports {
+		host, ok := hostFuncs[imp.Module+"."+imp.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown import %s.%s", ErrInvalidModule, imp.Module, imp.Name)
+		}
+		if !host.Type.equal(imp.Type) {
+			return nil, fmt.Errorf("%w: import %s.%s has type %s, expected %s", ErrInvalidModule, imp.Module, imp.Name, imp.Type, host.Type)
+		}
+		instance.imports = append(instance.imports, host)
+	}
+
+	for _, g := range m.globals {
+		instance.globals = append(instance.globals, g.value)
+	}
+	if m.memory != nil {
+		instance.memory = make([]byte, int(m.memory.min)*PageSize)
+	}
+	for _, segment := range m.data {
+		if uint64(segment.offset)+uint64(len(segment.data)) > uint64(len(instance.memory)) {
+			return nil, fmt.Errorf("%w: data segment out of memory bounds", ErrInvalidModule)
+		}
+		copy(instance.memory[segment.offset:], segment.data)
+	}
+
+	if m.start != nil {
+		if err := instance.invoke(*m.start); err != nil {
+			return nil, err
+		}
+	}
+	return instance, nil
+}
+
+// Call runs an exported function
+func (i *Instance) Call(name string, args ...uint64) ([]uint64, error) {
+	index, ok := i.module.Exports[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: function %s isn't exported", ErrInvalidModule, name)
+	}
+	typ := i.module.funcType(index)
+	if len(args) != len(typ.Params) {
+		return nil, fmt.Errorf("%w: %s takes %d arguments, got %d", ErrTrap, name, len(typ.Params), len(args))
+	}
+
+	i.stack = append(i.stack[:0], args...)
+	if err := i.invoke(index); err != nil {
+		return nil, err
+	}
+	return append([]uint64{}, i.stack...), nil
+}
+
+// FuelUsed returns the fuel consumed so far
+func (i *Instance) FuelUsed() uint64 {
+	return i.fuel
+}
+
+// Memory returns the linear memory of the instance, host functions use it to exchange data
+func (i *Instance) Memory() []byte {
+	return i.memory
+}
+
+// Consume charges fuel, failing with ErrOutOfFuel once the limit is reached
+func (i *Instance) Consume(fuel uint64) error {
+	if fuel > i.fuelLimit-i.fuel {
+		i.fuel = i.fuelLimit
+		return ErrOutOfFuel
+	}
+	i.fuel += fuel
+	return nil
+}
+
+func trap(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrTrap, fmt.Sprintf(format, args...))
+}
+
+func (i *Instance) push(value uint64) error {
+	if len(i.stack) == MaxStackSize {
+		return trap("stack overflow")
+	}
+	i.stack = append(i.stack, value)
+	return nil
+}
+
+func (i *Instance) pop() (uint64, error) {
+	if len(i.stack) == 0 {
+		return 0, trap("stack underflow")
+	}
+	value := i.stack[len(i.stack)-1]
+	i.stack = i.stack[:len(i.stack)-1]
+	return value, nil
+}
+
+// popN pops n values, returning them in the order they were pushed
+func (i *Instance) popN(n int) ([]uint64, error) {
+	if len(i.stack) < n {
+		return nil, trap("stack underflow")
+	}
+	values := append([]uint64{}, i.stack[len(i.stack)-n:]...)
+	i.stack = i.stack[:len(i.stack)-n]
+	return values, nil
+}
+
+// invoke calls the function at index with its arguments on the stack, leaving its results there
+func (i *Instance) invoke(index uint32) error {
+	typ := i.module.funcType(index)
+	args, err := i.popN(len(typ.Params))
+	if err != nil {
+		return err
+	}
+
+	if int(index) < len(i.imports) {
+		host := i.imports[index]
+		if err := i.Consume(host.Fuel); err != nil {
+			return err
+		}
+		results, err := host.Call(i, args)
+		if err != nil {
+			return err
+		}
+		if len(results) != len(typ.Results) {
+			return trap("host function returned %d values, expected %d", len(results), len(typ.Results))
+		}
+		for _, result := range results {
+			if err := i.push(result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if i.depth == MaxCallDepth {
+		return trap("call stack exhausted")
+	}
+	i.depth++
+	defer func() { i.depth-- }()
+
+	fn := &i.module.functions[int(index)-len(i.imports)]
+	locals := make([]uint64, len(typ.Params)+len(fn.locals))
+	copy(locals, args)
+	return i.execute(fn, locals)
+}
+
+// execute runs the body of a function
+func (i *Instance) execute(fn *function, locals []uint64) error {
+	base := len(i.stack)
+	labels := []label{{arity: len(fn.typ.Results), height: base, target: len(fn.code)}}
+
+	// branch unwinds the stack to the label at depth, keeping the values it carries
+	branch := func(depth int) (int, error) {
+		l := labels[len(labels)-1-depth]
+		if len(i.stack) < l.height+l.arity {
+			return 0, trap("stack underflow")
+		}
+		i.stack = append(i.stack[:l.height], i.stack[len(i.stack)-l.arity:]...)
+		if l.loop {
+			labels = labels[:len(labels)-depth]
+		} else {
+			labels = labels[:len(labels)-1-depth]
+		}
+		return l.target, nil
+	}
+
+	for pc := 0; pc < len(fn.code) && len(labels) > 0; {
+		in := &fn.code[pc]
+		if err := i.Consume(1); err != nil {
+			return err
+		}
+		next := pc + 1
+
+		var err error
+		switch op := in.op; {
+		case op == opUnreachable:
+			return trap("unreachable")
+		case op == opNop:
+		case op == opBlock:
+			labels = append(labels, label{arity: int(in.a), height: len(i.stack), target: in.end + 1})
+		case op == opLoop:
+			labels = append(labels, label{height: len(i.stack), target: pc + 1, loop: true})
+		case op == opIf:
+			var condition uint64
+			if condition, err = i.pop(); err == nil {
+				labels = append(labels, label{arity: int(in.a), height: len(i.stack), target: in.end + 1})
+				if condition == 0 {
+					next = in.end
+					if in.elseAt >= 0 {
+						next = in.elseAt + 1
+					}
+				}
+			}
+		case op == opElse:
+			next = in.end // The then branch is over, skip the else branch
+		case op == opEnd:
+			labels = labels[:len(labels)-1]
+		case op == opBr:
+			next, err = branch(int(in.a))
+		case op == opBrIf:
+			var condition uint64
+			if condition, err = i.pop(); err == nil && condition != 0 {
+				next, err = branch(int(in.a))
+			}
+		case op == opBrTable:
+			var index uint64
+			if index, err = i.pop(); err == nil {
+				depth := in.table[len(in.table)-1]
+				if index < uint64(len(in.table)-1) {
+					depth = in.table[index]
+				}
+				next, err = branch(int(depth))
+			}
+		case op == opReturn:
+			next, err = branch(len(labels) - 1)
+		case op == opCall:
+			err = i.invoke(uint32(in.a))
+		case op == opDrop:
+			_, err = i.pop()
+		case op == opSelect, op == opSelectTyped:
+			var values []uint64
+			if values, err = i.popN(3); err == nil {
+				if values[2] == 0 {
+					values[0] = values[1]
+				}
+				err = i.push(values[0])
+			}
+		case op == opLocalGet:
+			err = i.push(locals[in.a])
+		case op == opLocalSet, op == opLocalTee:
+			var value uint64
+			if value, err = i.pop(); err == nil {
+				locals[in.a] = value
+				if op == opLocalTee {
+					err = i.push(value)
+				}
+			}
+		case op == opGlobalGet:
+			err = i.push(i.globals[in.a])
+		case op == opGlobalSet:
+			i.globals[in.a], err = i.pop()
+		case op == opMemorySize:
+			err = i.push(uint64(len(i.memory) / PageSize))
+		case op == opMemoryGrow:
+			err = i.grow()
+		case op == opI32Const, op == opI64Const:
+			err = i.push(in.a)
+		case op == opI32Eqz, op == opI64Eqz:
+			var value uint64
+			if value, err = i.pop(); err == nil {
+				err = i.push(boolValue(value == 0))
+			}
+		case op >= opI32Eq && op <= opI32GeU:
+			err = i.binary(func(a, b uint64) (uint64, error) { return compare(op-opI32Eq, a, b, 32), nil })
+		case op >= opI64Eq && op <= opI64GeU:
+			err = i.binary(func(a, b uint64) (uint64, error) { return compare(op-opI64Eq, a, b, 64), nil })
+		case op >= opI32Clz && op <= opI32Rotr:
+			err = i.arithmetic(op-opI32Clz, 32)
+		case op >= opI64Clz && op <= opI64Rotr:
+			err = i.arithmetic(op-opI64Clz, 64)
+		case op == opI32WrapI64, op == opI64ExtendS, op == opI64ExtendU, op >= opI32Extend8S && op <= opI64Ext32S:
+			var value uint64
+			if value, err = i.pop(); err == nil {
+				err = i.push(convert(op, value))
+			}
+		default:
+			err = i.memoryAccess(op, in.a)
+		}
+		if err != nil {
+			return err
+		}
+		pc = next
+	}
+
+	results, err := i.popN(len(fn.typ.Results))
+	if err != nil || len(i.stack) < base {
+		return trap("stack underflow")
+	}
+	i.stack = append(i.stack[:base], results...)
+	return nil
+}
+
+// binary pops b, then a, and pushes f(a, b)
+func (i *Instance) binary(f func(a, b uint64) (uint64, error)) error {
+	values, err := i.popN(2)
+	if err != nil {
+		return err
+	}
+	result, err := f(values[0], values[1])
+	if err != nil {
+		return err
+	}
+	return i.push(result)
+}
+
+// arithmetic runs the integer instruction at offset from clz, in the order of the specification:
+// clz ctz popcnt add sub mul div_s div_u rem_s rem_u and or xor shl shr_s shr_u rotl rotr
+func (i *Instance) arithmetic(offset byte, size int) error {
+	if offset <= 2 {
+		value, err := i.pop()
+		if err != nil {
+			return err
+		}
+		var result int
+		switch {
+		case offset == 0 && size == 32:
+			result = bits.LeadingZeros32(uint32(value))
+		case offset == 0:
+			result = bits.LeadingZeros64(value)
+		case offset == 1 && size == 32:
+			result = bits.TrailingZeros32(uint32(value))
+		case offset == 1:
+			result = bits.TrailingZeros64(value)
+		case size == 32:
+			result = bits.OnesCount32(uint32(value))
+		default:
+			result = bits.OnesCount64(value)
+		}
+		return i.push(uint64(result))
+	}
+
+	return i.binary(func(a, b uint64) (uint64, error) {
+		mask := uint64(size - 1)
+		signedA, signedB := signed(a, size), signed(b, size)
+		var result uint64
+		switch offset {
+		case 3:
+			result = a + b
+		case 4:
+			result = a - b
+		case 5:
+			result = a * b
+		case 6, 7, 8, 9:
+			if b == 0 || signedB == 0 {
+				return 0, trap("integer divide by zero")
+			}
+			minimum := int64(-1) << (size - 1)
+			switch offset {
+			case 6:
+				if signedA == minimum && signedB == -1 {
+					return 0, trap("integer overflow")
+				}
+				result = uint64(signedA / signedB)
+			case 7:
+				result = a / b
+			case 8:
+				if signedB == -1 {
+					result = 0
+				} else {
+					result = uint64(signedA % signedB)
+				}
+			default:
+				result = a % b
+			}
+		case 10:
+			result = a & b
+		case 11:
+			result = a | b
+		case 12:
+			result = a ^ b
+		case 13:
+			result = a << (b & mask)
+		case 14:
+			result = uint64(signedA >> (b & mask))
+		case 15:
+			result = a >> (b & mask)
+		case 16, 17:
+			shift := int(b & mask)
+			if offset == 17 {
+				shift = -shift
+			}
+			if size == 32 {
+				result = uint64(bits.RotateLeft32(uint32(a), shift))
+			} else {
+				result = bits.RotateLeft64(a, shift)
+			}
+		}
+		return truncate(result, size), nil
+	})
+}
+
+// compare runs the comparison at offset from eq, in the order of the specification:
+// eq ne lt_s lt_u gt_s gt_u le_s le_u ge_s ge_u
+func compare(offset byte, a, b uint64, size int) uint64 {
+	signedA, signedB := signed(a, size), signed(b, size)
+	switch offset {
+	case 0:
+		return boolValue(a == b)
+	case 1:
+		return boolValue(a != b)
+	case 2:
+		return boolValue(signedA < signedB)
+	case 3:
+		return boolValue(a < b)
+	case 4:
+		return boolValue(signedA > signedB)
+	case 5:
+		return boolValue(a > b)
+	case 6:
+		return boolValue(signedA <= signedB)
+	case 7:
+		return boolValue(a <= b)
+	case 8:
+		return boolValue(signedA >= signedB)
+	default:
+		return boolValue(a >= b)
+	}
+}
+
+func convert(op byte, value uint64) uint64 {
+	switch op {
+	case opI32WrapI64:
+		return uint64(uint32(value))
+	case opI64ExtendS:
+		return uint64(int64(int32(value)))
+	case opI64ExtendU:
+		return uint64(uint32(value))
+	case opI32Extend8S:
+		return uint64(uint32(int32(int8(value))))
+	case opI32Extend8S + 1:
+		return uint64(uint32(int32(int16(value))))
+	case opI32Extend8S + 2:
+		return uint64(int64(int8(value)))
+	case opI32Extend8S + 3:
+		return uint64(int64(int16(value)))
+	default:
+		return uint64(int64(int32(value)))
+	}
+}
+
+// memoryAccess runs a load or a store, checking the accessed bytes are within the memory
+func (i *Instance) memoryAccess(op byte, offset uint64) error {
+	// Width in bytes, whether the value is an i64 and whether loads sign-extend
+	var width uint64
+	var wide, signExtend, store bool
+	switch op {
+	case opI32Load, opI32Store:
+		width = 4
+	case opI64Load, opI64Store:
+		width, wide = 8, true
+	case opI32Load8S, opI32Load8S + 1, opI32Store8:
+		width = 1
+	case opI32Load8S + 2, opI32Load8S + 3, opI32Store8 + 1:
+		width = 2
+	case opI32Load8S + 4, opI32Load8S + 5, opI32Store8 + 2:
+		width, wide = 1, true
+	case opI32Load8S + 6, opI32Load8S + 7, opI32Store8 + 3:
+		width, wide = 2, true
+	default:
+		width, wide = 4, true
+	}
+	store = op >= opI32Store
+	signExtend = !store && op >= opI32Load8S && (op-opI32Load8S)%2 == 0
+
+	var value uint64
+	var err error
+	if store {
+		if value, err = i.pop(); err != nil {
+			return err
+		}
+	}
+	address, err := i.pop()
+	if err != nil {
+		return err
+	}
+	start := uint64(uint32(address)) + offset
+	if start+width > uint64(len(i.memory)) {
+		return trap("out of bounds memory access at %d", start)
+	}
+	memory := i.memory[start : start+width]
+
+	if store {
+		var buffer [8]byte
+		binary.LittleEndian.PutUint64(buffer[:], value)
+		copy(memory, buffer[:width])
+		return nil
+	}
+
+	var buffer [8]byte
+	copy(buffer[:], memory)
+	value = binary.LittleEndian.Uint64(buffer[:])
+	if signExtend {
+		value = uint64(signed(value, int(width*8)))
+	}
+	if !wide {
+		value = uint64(uint32(value))
+	}
+	return i.push(value)
+}
+
+// grow adds pages to the memory, pushing the previous size or -1 when the memory can't grow
+func (i *Instance) grow() error {
+	delta, err := i.pop()
+	if err != nil {
+		return err
+	}
+	pages := uint64(len(i.memory) / PageSize)
+	if pages+uint64(uint32(delta)) > uint64(i.module.memory.max) || pages+uint64(uint32(delta)) > MaxPages {
+		return i.push(uint64(uint32(0xffffffff)))
+	}
+	i.memory = append(i.memory, make([]byte, int(uint32(delta))*PageSize)...)
+	return i.push(pages)
+}
+
+// signed interprets the low size bits of value as a signed integer
+func signed(value uint64, size int) int64 {
+	shift := 64 - size
+	return int64(value<<shift) >> shift
+}
+
+// truncate keeps the low size bits of value
+func truncate(value uint64, size int) uint64 {
+	if size == 32 {
+		return uint64(uint32(value))
+	}
+	return value
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,394 @@
+// Package wasm interprets WebAssembly modules for smart contracts. Only integer instructions are
+// supported so execution is deterministic, and every instruction consumes fuel.
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ValueType is the type of a WebAssembly value, only integers are supported
+type ValueType byte
+
+const (
+	I32 ValueType = 0x7f
+	I64 ValueType = 0x7e
+)
+
+const (
+	// PageSize is the size of a page of linear memory
+	PageSize = 65536
+	// MaxPages caps the linear memory of a module to 1 MiB
+	MaxPages = 16
+)
+
+var (
+	ErrInvalidModule = errors.New("invalid wasm module")
+	ErrUnsupported   = errors.New("unsupported wasm feature")
+
+	magic = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+)
+
+// FuncType is the signature of a function
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+func (t FuncType) equal(other FuncType) bool {
+	return bytes.Equal(valueBytes(t.Params), valueBytes(other.Params)) && bytes.Equal(valueBytes(t.Results), valueBytes(other.Results))
+}
+
+func (t FuncType) String() string {
+	return fmt.Sprintf("%v -> %v", t.Params, t.Results)
+}
+
+func (v ValueType) String() string {
+	if v == I32 {
+		return "i32"
+	}
+	return "i64"
+}
+
+func valueBytes(types []ValueType) []byte {
+	encoded := make([]byte, len(types))
+	for i, t := range types {
+		encoded[i] = byte(t)
+	}
+	return encoded
+}
+
+// Import is a host function required by a module
+type Import struct {
+	Module string
+	Name   string
+	Type   FuncType
+}
+
+type function struct {
+	typ    FuncType
+	locals []ValueType
+	code   []instruction
+}
+
+type global struct {
+	typ     ValueType
+	mutable bool
+	value   uint64
+}
+
+type dataSegment struct {
+	offset uint32
+	data   []byte
+}
+
+// Module is a decoded WebAssembly module
+type Module struct {
+	Imports   []Import
+	Exports   map[string]uint32
+	functions []function
+	globals   []global
+	memory    *limits
+	data      []dataSegment
+	start     *uint32
+}
+
+type limits struct {
+	min, max uint32
+}
+
+// IsModule tells whether code starts like a WebAssembly binary
+func IsModule(code []byte) bool {
+	return bytes.HasPrefix(code, magic[:4])
+}
+
+// ExportType returns the signature of an exported function
+func (m *Module) ExportType(name string) (FuncType, bool) {
+	index, ok := m.Exports[name]
+	if !ok {
+		return FuncType{}, false
+	}
+	return m.funcType(index), true
+}
+
+// funcType returns the signature of the function at index, imports come first
+func (m *Module) funcType(index uint32) FuncType {
+	if int(index) < len(m.Imports) {
+		return m.Imports[index].Type
+	}
+	return m.functions[int(index)-len(m.Imports)].typ
+}
+
+func (m *Module) functionCount() int {
+	return len(m.Imports) + len(m.functions)
+}
+
+// Decode parses a binary module and checks that its instructions are supported
+func Decode(code []byte) (*Module, error) {
+	if !bytes.HasPrefix(code, magic) {
+		return nil, fmt.Errorf("%w: bad magic number or version", ErrInvalidModule)
+	}
+	r := &reader{data: code, pos: len(magic)}
+	m := &Module{Exports: make(map[string]uint32)}
+	var types []FuncType
+	var functionTypes []uint32
+
+	for r.pos < len(r.data) {
+		id := r.byte()
+		size := r.u32()
+		if r.err != nil || int(size) > len(r.data)-r.pos {
+			return nil, fmt.Errorf("%w: truncated section", ErrInvalidModule)
+		}
+		section := &reader{data: r.data[r.pos : r.pos+int(size)]}
+		r.pos += int(size)
+
+		switch id {
+		case 0, 12: // Custom and data count sections don't affect execution
+			continue
+		case 1:
+			types = decodeVec(section, func() FuncType {
+				if section.byte() != 0x60 {
+					section.fail("function type expected")
+				}
+				return FuncType{Params: section.valueTypes(), Results: section.valueTypes()}
+			})
+		case 2:
+			m.Imports = decodeVec(section, func() Import {
+				imp := Import{Module: section.name(), Name: section.name()}
+				if section.byte() != 0x00 {
+					section.unsupported("only function imports are supported")
+				}
+				imp.Type = typeAt(section, types, section.u32())
+				return imp
+			})
+		case 3:
+			functionTypes = decodeVec(section, section.u32)
+		case 4:
+			// Tables are only used by call_indirect, which isn't supported
+			continue
+		case 5:
+			memories := decodeVec(section, section.limits)
+			if len(memories) > 1 {
+				section.unsupported("more than one memory")
+			}
+			if len(memories) == 1 {
+				m.memory = &memories[0]
+			}
+		case 6:
+			m.globals = decodeVec(section, func() global {
+				g := global{typ: section.valueType(), mutable: section.byte() == 1}
+				g.value = section.constExpr()
+				return g
+			})
+		case 7:
+			decodeVec(section, func() struct{} {
+				name := section.name()
+				kind, index := section.byte(), section.u32()
+				if kind == 0x00 {
+					m.Exports[name] = index
+				}
+				return struct{}{}
+			})
+		case 8:
+			start := section.u32()
+			m.start = &start
+		case 9:
+			section.unsupported("element segments")
+		case 10:
+			bodies := decodeVec(section, func() []byte {
+				size := section.u32()
+				return section.bytes(int(size))
+			})
+			if section.err == nil && len(bodies) != len(functionTypes) {
+				section.fail("function and code sections don't match")
+			}
+			for i, body := range bodies {
+				if section.err != nil {
+					break
+				}
+				fn := function{typ: typeAt(section, types, functionTypes[i])}
+				if err := fn.decodeBody(body, m, types); err != nil {
+					return nil, fmt.Errorf("function %d: %w", len(m.Imports)+i, err)
+				}
+				m.functions = append(m.functions, fn)
+			}
+		case 11:
+			m.data = decodeVec(section, func() dataSegment {
+				if section.u32() != 0 {
+					section.unsupported("passive data segments")
+				}
+				segment := dataSegment{offset: uint32(section.constExpr())}
+				segment.data = section.bytes(int(section.u32()))
+				return segment
+			})
+		default:
+			return nil, fmt.Errorf("%w: unknown section %d", ErrInvalidModule, id)
+		}
+		if section.err != nil {
+			return nil, section.err
+		}
+		if section.pos != len(section.data) {
+			return nil, fmt.Errorf("%w: section %d has trailing bytes", ErrInvalidModule, id)
+		}
+	}
+
+	if len(functionTypes) != len(m.functions) {
+		return nil, fmt.Errorf("%w: functions without code", ErrInvalidModule)
+	}
+	if m.memory != nil && (m.memory.min > MaxPages || m.memory.max > MaxPages) {
+		return nil, fmt.Errorf("%w: memory is limited to %d pages", ErrUnsupported, MaxPages)
+	}
+	for name, index := range m.Exports {
+		if int(index) >= m.functionCount() {
+			return nil, fmt.Errorf("%w: export %s references function %d", ErrInvalidModule, name, index)
+		}
+	}
+	if m.start != nil && int(*m.start) >= m.functionCount() {
+		return nil, fmt.Errorf("%w: start function %d doesn't exist", ErrInvalidModule, *m.start)
+	}
+	// Calls are checked now that every function is known
+	for i, fn := range m.functions {
+		for _, in := range fn.code {
+			if in.op == opCall && int(in.a) >= m.functionCount() {
+				return nil, fmt.Errorf("%w: function %d calls unknown function %d", ErrInvalidModule, len(m.Imports)+i, in.a)
+			}
+		}
+	}
+	return m, nil
+}
+
+func typeAt(r *reader, types []FuncType, index uint32) FuncType {
+	if int(index) >= len(types) {
+		r.fail(fmt.Sprintf("type %d doesn't exist", index))
+		return FuncType{}
+	}
+	return types[index]
+}
+
+func decodeVec[T any](r *reader, decode func() T) []T {
+	count := r.u32()
+	if r.err != nil || int(count) > len(r.data)-r.pos {
+		r.fail("vector too long")
+		return nil
+	}
+	items := make([]T, 0, count)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		items = append(items, decode())
+	}
+	return items
+}
+
+// reader decodes the primitive encodings of the binary format. The first error sticks and
+// makes every later read return zero values.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) fail(message string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s", ErrInvalidModule, message)
+	}
+}
+
+func (r *reader) unsupported(message string) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s", ErrUnsupported, message)
+	}
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil || r.pos >= len(r.data) {
+		r.fail("unexpected end")
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.data)-r.pos {
+		r.fail("unexpected end")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+// leb decodes an unsigned or signed LEB128 integer of at most size bits
+func (r *reader) leb(size uint, signed bool) uint64 {
+	var result uint64
+	var shift uint
+	for {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		result |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if signed && shift < 64 && b&0x40 != 0 {
+				result |= ^uint64(0) << shift
+			}
+			return result
+		}
+		if shift >= size {
+			r.fail("integer too long")
+			return 0
+		}
+	}
+}
+
+func (r *reader) u32() uint32 {
+	return uint32(r.leb(32, false))
+}
+
+func (r *reader) name() string {
+	return string(r.bytes(int(r.u32())))
+}
+
+func (r *reader) valueType() ValueType {
+	t := ValueType(r.byte())
+	if r.err == nil && t != I32 && t != I64 {
+		r.unsupported(fmt.Sprintf("value type 0x%02x", byte(t)))
+	}
+	return t
+}
+
+func (r *reader) valueTypes() []ValueType {
+	return decodeVec(r, r.valueType)
+}
+
+func (r *reader) limits() limits {
+	l := limits{max: MaxPages}
+	switch r.byte() {
+	case 0x00:
+		l.min = r.u32()
+	case 0x01:
+		l.min, l.max = r.u32(), r.u32()
+	default:
+		r.fail("invalid limits")
+	}
+	return l
+}
+
+// constExpr decodes the constant initializer of a global or a data segment
+func (r *reader) constExpr() uint64 {
+	var value uint64
+	switch r.byte() {
+	case 0x41:
+		value = uint64(uint32(r.leb(32, true)))
+	case 0x42:
+		value = r.leb(64, true)
+	default:
+		r.unsupported("only constant initializers are supported")
+	}
+	if r.byte() != 0x0b {
+		r.fail("constant expression must end")
+	}
+	return value
+}
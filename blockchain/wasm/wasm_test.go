@@ -0,0 +1,198 @@
+package wasm_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"diy.blockchain.org/m/blockchain/wasm"
+)
+
+// The helpers below encode modules by hand, following the binary format of the specification.
+
+func uleb(v uint64) []byte {
+	encoded := []byte{}
+	for {
+		b := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			encoded = append(encoded, b|0x80)
+			continue
+		}
+		return append(encoded, b)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	return append(uleb(uint64(len(items))), bytes.Join(items, nil)...)
+}
+
+func name(s string) []byte {
+	return append(uleb(uint64(len(s))), s...)
+}
+
+func section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, uleb(uint64(len(content)))...), content...)
+}
+
+func body(locals []byte, code ...byte) []byte {
+	fn := append(locals, code...)
+	return append(uleb(uint64(len(fn))), fn...)
+}
+
+func module(sections ...[]byte) []byte {
+	return append([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, bytes.Join(sections, nil)...)
+}
+
+func instantiate(t *testing.T, code []byte, hostFuncs map[string]wasm.HostFunc, fuel uint64) *wasm.Instance {
+	t.Helper()
+	m, err := wasm.Decode(code)
+	if err != nil {
+		t.Fatalf("failed to decode module: %v", err)
+	}
+	instance, err := m.Instantiate(hostFuncs, fuel)
+	if err != nil {
+		t.Fatalf("failed to instantiate module: %v", err)
+	}
+	return instance
+}
+
+// TestFactorial runs an iterative and a recursive factorial, exercising loops, branches, if/else and calls.
+func TestFactorial(t *testing.T) {
+	code := module(
+		section(1, vec([]byte{0x60, 0x01, 0x7e, 0x01, 0x7e})),
+		section(3, vec([]byte{0x00}, []byte{0x00})),
+		section(7, vec(append(name("fact"), 0x00, 0x00), append(name("fact_rec"), 0x00, 0x01))),
+		section(10, vec(
+			// acc = 1; block { loop { br_if 1 (n == 0); acc *= n; n--; br 0 } }; acc
+			body(vec([]byte{0x01, 0x7e}),
+				0x42, 0x01, 0x21, 0x01,
+				0x02, 0x40, 0x03, 0x40,
+				0x20, 0x00, 0x50, 0x0d, 0x01,
+				0x20, 0x01, 0x20, 0x00, 0x7e, 0x21, 0x01,
+				0x20, 0x00, 0x42, 0x01, 0x7d, 0x21, 0x00,
+				0x0c, 0x00, 0x0b, 0x0b,
+				0x20, 0x01, 0x0b),
+			// if n == 0 { 1 } else { n * fact_rec(n - 1) }
+			body(vec(),
+				0x20, 0x00, 0x50, 0x04, 0x7e,
+				0x42, 0x01,
+				0x05, 0x20, 0x00, 0x20, 0x00, 0x42, 0x01, 0x7d, 0x10, 0x01, 0x7e,
+				0x0b, 0x0b),
+		)),
+	)
+
+	instance := instantiate(t, code, nil, 100000)
+	for _, export := range []string{"fact", "fact_rec"} {
+		results, err := instance.Call(export, 20)
+		if err != nil {
+			t.Fatalf("%s: %v", export, err)
+		}
+		if len(results) != 1 || results[0] != 2432902008176640000 {
+			t.Errorf("%s(20) = %v, expected 2432902008176640000", export, results)
+		}
+	}
+	if instance.FuelUsed() == 0 {
+		t.Error("expected fuel to be used")
+	}
+}
+
+// TestMemoryAndHostFunctions checks data segments, loads and stores, and imported functions.
+func TestMemoryAndHostFunctions(t *testing.T) {
+	code := module(
+		section(1, vec(
+			[]byte{0x60, 0x02, 0x7f, 0x7f, 0x00},
+			[]byte{0x60, 0x00, 0x00},
+			[]byte{0x60, 0x00, 0x01, 0x7f},
+		)),
+		section(2, vec(append(append(name("env"), name("log")...), 0x00, 0x00))),
+		section(3, vec([]byte{0x01}, []byte{0x02})),
+		section(5, vec([]byte{0x00, 0x01})),
+		section(7, vec(append(name("run"), 0x00, 0x01), append(name("sign"), 0x00, 0x02))),
+		section(10, vec(
+			// log(16, 5)
+			body(vec(), 0x41, 0x10, 0x41, 0x05, 0x10, 0x00, 0x0b),
+			// store the i64 511 at 0 and load its first byte as a signed i32
+			body(vec(), 0x41, 0x00, 0x42, 0xff, 0x03, 0x37, 0x03, 0x00, 0x41, 0x00, 0x2c, 0x00, 0x00, 0x0b),
+		)),
+		section(11, vec(append([]byte{0x00, 0x41, 0x10, 0x0b}, name("hello")...))),
+	)
+
+	var logged string
+	hostFuncs := map[string]wasm.HostFunc{
+		"env.log": {
+			Type: wasm.FuncType{Params: []wasm.ValueType{wasm.I32, wasm.I32}},
+			Fuel: 10,
+			Call: func(instance *wasm.Instance, args []uint64) ([]uint64, error) {
+				logged = string(instance.Memory()[args[0] : args[0]+args[1]])
+				return nil, nil
+			},
+		},
+	}
+	instance := instantiate(t, code, hostFuncs, 1000)
+	if _, err := instance.Call("run"); err != nil {
+		t.Fatal(err)
+	}
+	if logged != "hello" {
+		t.Errorf("expected host function to read hello, got %q", logged)
+	}
+	results, err := instance.Call("sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0] != 0xffffffff {
+		t.Errorf("expected -1 as an i32, got %x", results)
+	}
+
+	m, _ := wasm.Decode(code)
+	if _, err := m.Instantiate(nil, 1000); !errors.Is(err, wasm.ErrInvalidModule) {
+		t.Errorf("expected missing import to be rejected, got %v", err)
+	}
+}
+
+// TestTraps verifies that runaway or faulty code stops with an error instead of crashing the node.
+func TestTraps(t *testing.T) {
+	code := module(
+		section(1, vec([]byte{0x60, 0x00, 0x01, 0x7f}, []byte{0x60, 0x00, 0x00})),
+		section(3, vec([]byte{0x00}, []byte{0x01}, []byte{0x01})),
+		section(7, vec(append(name("divide"), 0x00, 0x00), append(name("spin"), 0x00, 0x01), append(name("recurse"), 0x00, 0x02))),
+		section(10, vec(
+			body(vec(), 0x41, 0x01, 0x41, 0x00, 0x6d, 0x0b),
+			body(vec(), 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b),
+			body(vec(), 0x10, 0x02, 0x0b),
+		)),
+	)
+
+	instance := instantiate(t, code, nil, 100000)
+	if _, err := instance.Call("divide"); !errors.Is(err, wasm.ErrTrap) {
+		t.Errorf("expected division by zero to trap, got %v", err)
+	}
+	if _, err := instance.Call("recurse"); !errors.Is(err, wasm.ErrTrap) {
+		t.Errorf("expected unbounded recursion to trap, got %v", err)
+	}
+	if _, err := instance.Call("spin"); !errors.Is(err, wasm.ErrOutOfFuel) || instance.FuelUsed() != 100000 {
+		t.Errorf("expected infinite loop to run out of fuel, got %v after %d", err, instance.FuelUsed())
+	}
+}
+
+// TestUnsupportedModules checks that floats and malformed binaries are rejected when decoding.
+func TestUnsupportedModules(t *testing.T) {
+	floats := module(
+		section(1, vec([]byte{0x60, 0x00, 0x00})),
+		section(3, vec([]byte{0x00})),
+		section(10, vec(body(vec(), 0x43, 0x00, 0x00, 0x80, 0x3f, 0x1a, 0x0b))),
+	)
+	if _, err := wasm.Decode(floats); !errors.Is(err, wasm.ErrUnsupported) {
+		t.Errorf("expected floats to be unsupported, got %v", err)
+	}
+
+	for name, code := range map[string][]byte{
+		"bad magic":         []byte("\x00asx\x01\x00\x00\x00"),
+		"truncated section": module([]byte{0x01, 0x05, 0x01}),
+		"unknown local":     module(section(1, vec([]byte{0x60, 0x00, 0x00})), section(3, vec([]byte{0x00})), section(10, vec(body(vec(), 0x20, 0x00, 0x0b)))),
+		"missing end":       module(section(1, vec([]byte{0x60, 0x00, 0x00})), section(3, vec([]byte{0x00})), section(10, vec(body(vec(), 0x01)))),
+	} {
+		if _, err := wasm.Decode(code); !errors.Is(err, wasm.ErrInvalidModule) {
+			t.Errorf("%s: expected ErrInvalidModule, got %v", name, err)
+		}
+	}
+}
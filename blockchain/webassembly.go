@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"diy.blockchain.org/m/blockchain/wasm"
+)
+
+const (
+	// WASMEntryPoint is the function a WebAssembly contract exports for calls. It takes no
+	// parameters and may return an i64, recorded in the receipt.
+	WASMEntryPoint = "call"
+	// maxHostAddress is the longest address the transfer host function reads from memory
+	maxHostAddress = 128
+)
+
+// wasmHost returns the host API of WebAssembly contracts, all under the "env" module:
+//
+//	storage_get(key i64) i64, storage_set(key i64, value i64)
+//	emit(topic i64, value i64)
+//	arg(index i32) i64, arg_count() i32
+//	balance() i64, the native balance of the contract
+//	transfer(address i32, length i32, amount i64) i32, sends native funds from the contract
+//	  to the address stored in memory, returning 1 if the contract can't afford it
+func (s *State) wasmHost(contract Contract, transaction Transaction, receipt *Receipt) map[string]wasm.HostFunc {
+	i32, i64 := wasm.I32, wasm.I64
+	storage := contractStorage{state: s, address: contract.Address}
+	return map[string]wasm.HostFunc{
+		"env.storage_get": {
+			Type: wasm.FuncType{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}},
+			Fuel: 50,
+			Call: func(_ *wasm.Instance, args []uint64) ([]uint64, error) {
+				return []uint64{uint64(storage.Load(int64(args[0])))}, nil
+			},
+		},
+		"env.storage_set": {
+			Type: wasm.FuncType{Params: []wasm.ValueType{i64, i64}},
+			Fuel: 100,
+			Call: func(_ *wasm.Instance, args []uint64) ([]uint64, error) {
+				storage.Store(int64(args[0]), int64(args[1]))
+				return nil, nil
+			},
+		},
+		"env.emit": {
+			Type: wasm.FuncType{Params: []wasm.ValueType{i64, i64}},
+			Fuel: 20,
+			Call: func(_ *wasm.Instance, args []uint64) ([]uint64, error) {
+				receipt.Logs = append(receipt.Logs, Log{Contract: contract.Address, Topic: int64(args[0]), Value: int64(args[1])})
+				return nil, nil
+			},
+		},
+		"env.arg": {
+			Type: wasm.FuncType{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i64}},
+			Call: func(_ *wasm.Instance, args []uint64) ([]uint64, error) {
+				if index := uint32(args[0]); index < uint32(len(transaction.Args)) {
+					return []uint64{uint64(transaction.Args[index])}, nil
+				}
+				return []uint64{0}, nil
+			},
+		},
+		"env.arg_count": {
+			Type: wasm.FuncType{Results: []wasm.ValueType{i32}},
+			Call: func(_ *wasm.Instance, _ []uint64) ([]uint64, error) {
+				return []uint64{uint64(len(transaction.Args))}, nil
+			},
+		},
+		"env.balance": {
+			Type: wasm.FuncType{Results: []wasm.ValueType{i64}},
+			Fuel: 10,
+			Call: func(_ *wasm.Instance, _ []uint64) ([]uint64, error) {
+				return []uint64{uint64(s.Balance(s.genesis.NativeSymbol, contract.Address))}, nil
+			},
+		},
+		"env.transfer": {
+			Type: wasm.FuncType{Params: []wasm.ValueType{i32, i32, i64}, Results: []wasm.ValueType{i32}},
+			Fuel: 100,
+			Call: func(instance *wasm.Instance, args []uint64) ([]uint64, error) {
+				start, length, amount := uint64(uint32(args[0])), uint64(uint32(args[1])), Amount(args[2])
+				if length == 0 || length > maxHostAddress || start+length > uint64(len(instance.Memory())) {
+					return nil, fmt.Errorf("%w: transfer address out of memory bounds", wasm.ErrTrap)
+				}
+				if amount <= 0 {
+					return nil, fmt.Errorf("%w: transfer amount must be positive", wasm.ErrTrap)
+				}
+				// Contracts can never overdraw, whatever the consensus rules allow to accounts
+				if s.Balance(s.genesis.NativeSymbol, contract.Address) < amount {
+					return []uint64{1}, nil
+				}
+				recipient := string(instance.Memory()[start : start+length])
+				if err := s.transfer(s.genesis.NativeSymbol, contract.Address, recipient, amount); err != nil {
+					return nil, fmt.Errorf("%w: %v", wasm.ErrTrap, err)
+				}
+				return []uint64{0}, nil
+			},
+		},
+	}
+}
+
+// validateWASMContract checks that a module only imports the host API and exports the entry point
+func validateWASMContract(code []byte) error {
+	module, err := wasm.Decode(code)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+	}
+	host := (&State{}).wasmHost(Contract{}, Transaction{}, nil)
+	for _, imp := range module.Imports {
+		if _, ok := host[imp.Module+"."+imp.Name]; !ok {
+			return fmt.Errorf("%w: contract imports unknown function %s.%s", ErrInvalidTransaction, imp.Module, imp.Name)
+		}
+	}
+	entryPoint, ok := module.ExportType(WASMEntryPoint)
+	if !ok || len(entryPoint.Params) != 0 || len(entryPoint.Results) > 1 || (len(entryPoint.Results) == 1 && entryPoint.Results[0] != wasm.I64) {
+		return fmt.Errorf("%w: contract must export %s with no parameters, returning nothing or an i64", ErrInvalidTransaction, WASMEntryPoint)
+	}
+	return nil
+}
+
+// runWASM calls the entry point of a WebAssembly contract, using the gas limit of the transaction as fuel
+func (s *State) runWASM(code []byte, contract Contract, transaction Transaction, receipt *Receipt) error {
+	module, err := wasm.Decode(code)
+	if err != nil {
+		return err
+	}
+	instance, err := module.Instantiate(s.wasmHost(contract, transaction, receipt), transaction.GasLimit)
+	if err == nil {
+		var results []uint64
+		if results, err = instance.Call(WASMEntryPoint); err == nil && len(results) == 1 {
+			receipt.Return = int64(results[0])
+		}
+	}
+	if instance != nil {
+		receipt.GasUsed = instance.FuelUsed()
+	} else if err != nil {
+		receipt.GasUsed = transaction.GasLimit
+	}
+	return err
+}
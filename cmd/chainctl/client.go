@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// client calls the API of one node
+type client struct {
+	base string
+	http *http.Client
+}
+
+func newClient(node string) *client {
+	if !strings.Contains(node, "://") {
+		node = "http://" + node
+	}
+	// No timeout, mining and resolving conflicts take as long as they take
+	return &client{base: strings.TrimSuffix(node, "/"), http: &http.Client{}}
+}
+
+// get decodes the answer of GET path into result
+func (c *client) get(path string, result interface{}) error {
+	return c.do(http.MethodGet, path, nil, result)
+}
+
+// post sends body as JSON to path and decodes the answer into result
+func (c *client) post(path string, body, result interface{}) error {
+	return c.do(http.MethodPost, path, body, result)
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	resp, err := c.open(method, path, reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("%s %s: invalid answer: %w", method, path, err)
+	}
+	return nil
+}
+
+// open sends a request and returns the response to read. Error statuses are returned as errors carrying the
+// message of the node.
+func (c *client) open(method, path string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+type (
+	// wallet is the file wallet create writes, and -wallet reads
+	wallet struct {
+		Address    string `json:"address"`
+		PublicKey  string `json:"public_key"`
+		PrivateKey string `json:"private_key,omitempty"`
+	}
+
+	accountDto struct {
+		Address  string                    `json:"address"`
+		Balances []blockchain.AssetBalance `json:"balances"`
+		Nonce    uint64                    `json:"nonce"`
+	}
+
+	nodesDto struct {
+		Nodes []string `json:"nodes"`
+		Total int      `json:"total"`
+	}
+)
+
+// parse parses the flags of a command and checks it got count positional arguments, or at least one if count is -1
+func parse(flags *flag.FlagSet, args []string, count int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case count == -1 && flags.NArg() == 0:
+		return fmt.Errorf("%s: expected at least one argument", flags.Name())
+	case count >= 0 && flags.NArg() != count:
+		return fmt.Errorf("%s: expected %d arguments, got %d", flags.Name(), count, flags.NArg())
+	}
+	return nil
+}
+
+func isSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// keyFlags adds -key and -wallet to the flags of a command that signs, and returns the private key they give
+func keyFlags(flags *flag.FlagSet) func() (string, error) {
+	key := flags.String("key", "", "hex encoded private key")
+	file := flags.String("wallet", "", "wallet file written by wallet create")
+	return func() (string, error) {
+		if *file == "" {
+			return *key, nil
+		}
+		if *key != "" {
+			return "", errors.New("-key and -wallet can't both be given")
+		}
+		content, err := os.ReadFile(*file)
+		if err != nil {
+			return "", err
+		}
+		var w wallet
+		if err := json.Unmarshal(content, &w); err != nil || w.PrivateKey == "" {
+			return "", fmt.Errorf("%s isn't a wallet with a private key", *file)
+		}
+		return w.PrivateKey, nil
+	}
+}
+
+// addressOf returns the address owned by a hex encoded private key
+func addressOf(privateKey string) (string, error) {
+	publicKey, err := blockchain.PublicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return blockchain.AddressFromPublicKey(publicKey)
+}
+
+// sign adds the signature of privateKey to transaction. The sender defaults to the address of the key, and the
+// chain ID, which signatures commit to, to the one of the node.
+func (c *cli) sign(transaction *blockchain.Transaction, privateKey string) error {
+	publicKey, err := blockchain.PublicKeyFromPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	if transaction.Sender == "" {
+		if transaction.Sender, err = addressOf(privateKey); err != nil {
+			return err
+		}
+	}
+	if transaction.ChainID == "" {
+		var info struct {
+			ChainID string `json:"chain_id"`
+		}
+		if err := c.client.get("/info", &info); err != nil {
+			return err
+		}
+		transaction.ChainID = info.ChainID
+	}
+	signature, err := blockchain.Sign(privateKey, transaction.SigningBytes())
+	if err != nil {
+		return err
+	}
+	transaction.Signatures = append(transaction.Signatures, blockchain.Signature{PublicKey: publicKey, Signature: signature})
+	return nil
+}
+
+// amount reads a decimal amount with the precision the node gives the asset, the native one if symbol is empty
+func (c *cli) amount(symbol, value string) (blockchain.Amount, error) {
+	if symbol == "" {
+		var info struct {
+			NativeSymbol string `json:"native_symbol"`
+		}
+		if err := c.client.get("/info", &info); err != nil {
+			return 0, err
+		}
+		symbol = info.NativeSymbol
+	}
+	var tokens struct {
+		Tokens []blockchain.Asset `json:"tokens"`
+	}
+	if err := c.client.get("/tokens", &tokens); err != nil {
+		return 0, err
+	}
+	for _, asset := range tokens.Tokens {
+		if asset.Symbol == symbol {
+			return blockchain.ParseAmount(value, asset.Decimals)
+		}
+	}
+	return 0, fmt.Errorf("unknown asset %s", symbol)
+}
+
+func txSend(c *cli, args []string) error {
+	flags := c.flags("tx send")
+	from := flags.String("from", "", "sender address, the address of the key by default")
+	to := flags.String("to", "", "recipient address")
+	amount := flags.String("amount", "0", "amount in the unit of the asset, like 1.25")
+	asset := flags.String("asset", "", "symbol of the asset, the native asset by default")
+	data := flags.String("data", "", "memo")
+	nonce := flags.Uint64("nonce", 0, "nonce of the transaction, the next one of the sender by default")
+	privateKey := keyFlags(flags)
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	key, err := privateKey()
+	if err != nil {
+		return err
+	}
+
+	units, err := c.amount(*asset, *amount)
+	if err != nil {
+		return err
+	}
+	transaction := blockchain.Transaction{Sender: *from, Recipient: *to, Amount: units, Asset: *asset, Data: *data, Nonce: *nonce}
+	if transaction.Sender == "" && key != "" {
+		if transaction.Sender, err = addressOf(key); err != nil {
+			return err
+		}
+	}
+	if transaction.Sender == "" || transaction.Recipient == "" {
+		return errors.New("tx send: -to is required, and -from unless a key is given")
+	}
+	if !isSet(flags, "nonce") {
+		var account accountDto
+		if err := c.client.get("/addresses/"+url.PathEscape(transaction.Sender)+"/balances", &account); err != nil {
+			return err
+		}
+		transaction.Nonce = account.Nonce
+	}
+	if key != "" {
+		if err := c.sign(&transaction, key); err != nil {
+			return err
+		}
+	}
+
+	var result struct {
+		Message       string `json:"message"`
+		BlockIndex    int    `json:"block_index"`
+		TransactionID string `json:"transaction_id"`
+	}
+	if err := c.client.post("/transactions/new", transaction, &result); err != nil {
+		return err
+	}
+	return c.print(result, func(table io.Writer) {
+		row(table, "Transaction:", result.TransactionID)
+		row(table, "Block:", result.BlockIndex)
+	})
+}
+
+func mine(c *cli, args []string) error {
+	if err := parse(c.flags("mine"), args, 0); err != nil {
+		return err
+	}
+	var result struct {
+		Block blockchain.Block `json:"block"`
+	}
+	if err := c.client.get("/mine", &result); err != nil {
+		return err
+	}
+	return c.print(result.Block, func(table io.Writer) { blockRows(table, result.Block) })
+}
+
+func chainShow(c *cli, args []string) error {
+	flags := c.flags("chain show")
+	from := flags.Int("from", 1, "index of the first block")
+	to := flags.Int("to", 0, "index of the last block, the tip by default")
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	query := url.Values{"headers": {"true"}, "from": {strconv.Itoa(*from)}}
+	if *to > 0 {
+		query.Set("to", strconv.Itoa(*to))
+	}
+	headers := []blockchain.BlockHeader{}
+	for {
+		var page struct {
+			Chain      []blockchain.BlockHeader `json:"chain"`
+			NextCursor string                   `json:"next_cursor"`
+		}
+		if err := c.client.get("/chain?"+query.Encode(), &page); err != nil {
+			return err
+		}
+		headers = append(headers, page.Chain...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Del("from")
+		query.Set("cursor", page.NextCursor)
+	}
+
+	return c.print(headers, func(table io.Writer) {
+		row(table, "INDEX", "HASH", "PREVIOUS", "TIMESTAMP", "TXS")
+		for _, header := range headers {
+			row(table, header.Index, short(header.Hash), short(header.PreviousHash), timestamp(header.Timestamp), header.TransactionCount)
+		}
+	})
+}
+
+// chainExport copies the NDJSON export of the node as it streams, it is the same in both output formats
+func chainExport(c *cli, args []string) error {
+	flags := c.flags("chain export")
+	file := flags.String("file", "", "file to write, stdout by default")
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	resp, err := c.client.open(http.MethodGet, "/chain?format=ndjson", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if *file == "" {
+		_, err = io.Copy(c.out, resp.Body)
+		return err
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func blockGet(c *cli, args []string) error {
+	flags := c.flags("block get")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	path := "/blocks/hash/" + url.PathEscape(flags.Arg(0))
+	if flags.Arg(0) == "latest" {
+		path = "/blocks/latest"
+	} else if _, err := strconv.Atoi(flags.Arg(0)); err == nil {
+		path = "/blocks/" + flags.Arg(0)
+	}
+	var block blockchain.Block
+	if err := c.client.get(path, &block); err != nil {
+		return err
+	}
+	return c.print(block, func(table io.Writer) { blockRows(table, block) })
+}
+
+func nodesAdd(c *cli, args []string) error {
+	flags := c.flags("nodes add")
+	if err := parse(flags, args, -1); err != nil {
+		return err
+	}
+	var result struct {
+		TotalNodes map[string]bool `json:"total_nodes"`
+	}
+	if err := c.client.post("/nodes/register", map[string][]string{"nodes": flags.Args()}, &result); err != nil {
+		return err
+	}
+	nodes := nodesDto{Nodes: []string{}}
+	for node := range result.TotalNodes {
+		nodes.Nodes = append(nodes.Nodes, node)
+	}
+	sort.Strings(nodes.Nodes)
+	nodes.Total = len(nodes.Nodes)
+	return c.printNodes(nodes)
+}
+
+func nodesList(c *cli, args []string) error {
+	if err := parse(c.flags("nodes list"), args, 0); err != nil {
+		return err
+	}
+	var nodes nodesDto
+	if err := c.client.get("/nodes", &nodes); err != nil {
+		return err
+	}
+	return c.printNodes(nodes)
+}
+
+func (c *cli) printNodes(nodes nodesDto) error {
+	return c.print(nodes, func(table io.Writer) {
+		row(table, "NODE")
+		for _, node := range nodes.Nodes {
+			row(table, node)
+		}
+	})
+}
+
+func nodesResolve(c *cli, args []string) error {
+	if err := parse(c.flags("nodes resolve"), args, 0); err != nil {
+		return err
+	}
+	var answer struct {
+		Message  string             `json:"message"`
+		Chain    []blockchain.Block `json:"chain"`
+		NewChain []blockchain.Block `json:"new_chain"`
+	}
+	if err := c.client.get("/nodes/resolve", &answer); err != nil {
+		return err
+	}
+	result := struct {
+		Replaced bool `json:"replaced"`
+		Length   int  `json:"length"`
+	}{answer.NewChain != nil, len(answer.Chain) + len(answer.NewChain)}
+	return c.print(result, func(table io.Writer) {
+		row(table, "Replaced:", result.Replaced)
+		row(table, "Length:", result.Length)
+	})
+}
+
+func walletCreate(c *cli, args []string) error {
+	flags := c.flags("wallet create")
+	file := flags.String("file", "", "file to save the wallet to, readable by its owner only; the private key is printed otherwise")
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	publicKey, privateKey, err := blockchain.GenerateKey()
+	if err != nil {
+		return err
+	}
+	address, err := blockchain.AddressFromPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	w := wallet{Address: address, PublicKey: publicKey, PrivateKey: privateKey}
+
+	if *file != "" {
+		content, err := json.MarshalIndent(w, "", "  ")
+		if err != nil {
+			return err
+		}
+		// Never overwrite a wallet, its funds would be lost with its key
+		f, err := os.OpenFile(*file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(append(content, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		w.PrivateKey = ""
+	}
+	return c.print(w, func(table io.Writer) {
+		row(table, "Address:", w.Address)
+		row(table, "Public key:", w.PublicKey)
+		if w.PrivateKey != "" {
+			row(table, "Private key:", w.PrivateKey)
+		}
+	})
+}
+
+// walletSign prints the signed transaction as JSON in both output formats, ready to be posted to /transactions/new
+func walletSign(c *cli, args []string) error {
+	flags := c.flags("wallet sign")
+	file := flags.String("file", "", "file holding the transaction as JSON, stdin by default")
+	privateKey := keyFlags(flags)
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	key, err := privateKey()
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return errors.New("wallet sign: -key or -wallet is required")
+	}
+
+	in := c.in
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var transaction blockchain.Transaction
+	if err := json.NewDecoder(in).Decode(&transaction); err != nil {
+		return fmt.Errorf("invalid transaction: %w", err)
+	}
+	if err := c.sign(&transaction, key); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(transaction)
+}
+
+func balance(c *cli, args []string) error {
+	flags := c.flags("balance")
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+	var account accountDto
+	if err := c.client.get("/addresses/"+url.PathEscape(flags.Arg(0))+"/balances", &account); err != nil {
+		return err
+	}
+	return c.print(account, func(table io.Writer) {
+		row(table, "ASSET", "BALANCE")
+		for _, balance := range account.Balances {
+			row(table, balance.Symbol, balance.Formatted)
+		}
+		fmt.Fprintln(table)
+		row(table, "Next nonce:", account.Nonce)
+	})
+}
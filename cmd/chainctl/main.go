@@ -0,0 +1,112 @@
+// Command chainctl drives a node through its HTTP API:
+//
+//	go run ./cmd/chainctl -node localhost:8080 tx send -from alice -to bob -amount 5
+//	go run ./cmd/chainctl -output json block get latest
+//
+// Run chainctl help for every command. Wallets are created and transactions signed locally, private keys never
+// reach the node.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// NodeEnv names the environment variable holding the node chainctl talks to when -node isn't given
+const NodeEnv = "CHAINCTL_NODE"
+
+// command is a subcommand of chainctl, named by one or two words like "tx send"
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+var commands = []command{
+	{"tx send", "-to <address> -amount <n> [-from <address>] [-asset <symbol>] [-data <memo>] [-nonce <n>] [-key <hex> | -wallet <file>]", "submit a transfer, signed when a key is given", txSend},
+	{"mine", "", "mine the pending transactions into a block", mine},
+	{"chain show", "[-from <index>] [-to <index>]", "list the headers of the blocks", chainShow},
+	{"chain export", "[-file <path>]", "write every block as NDJSON, one per line", chainExport},
+	{"block get", "<index | hash | latest>", "show a block and its transactions", blockGet},
+	{"nodes add", "<address>...", "register peers", nodesAdd},
+	{"nodes list", "", "list the registered peers", nodesList},
+	{"nodes resolve", "", "adopt the longest valid chain of the peers", nodesResolve},
+	{"wallet create", "[-file <path>]", "generate a key pair and its address, without the node", walletCreate},
+	{"wallet sign", "[-key <hex> | -wallet <file>] [-file <transaction.json>]", "sign a transaction read from a file or stdin", walletSign},
+	{"balance", "<address>", "show the balances and the next nonce of an address", balance},
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "chainctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags, then runs the command named by the arguments left
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	node := os.Getenv(NodeEnv)
+	if node == "" {
+		node = "http://localhost:8080"
+	}
+	flags := flag.NewFlagSet("chainctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&node, "node", node, "URL or host:port of the node, $"+NodeEnv+" overrides the default")
+	output := flags.String("output", "table", "output format: table or json")
+	flags.Usage = func() { usage(stderr, flags) }
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output %q, expected table or json", *output)
+	}
+
+	if flags.NArg() == 0 || flags.Arg(0) == "help" {
+		usage(stdout, flags)
+		return nil
+	}
+	cmd, rest, err := findCommand(flags.Args())
+	if err != nil {
+		return err
+	}
+	c := &cli{client: newClient(node), in: stdin, out: stdout, stderr: stderr, json: *output == "json"}
+	return cmd.run(c, rest)
+}
+
+// findCommand returns the command the arguments start with, and the arguments left for it
+func findCommand(args []string) (command, []string, error) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return cmd, args[len(words):], nil
+		}
+	}
+	return command{}, nil, fmt.Errorf("unknown command %q, run chainctl help for the commands", strings.Join(args[:min(2, len(args))], " "))
+}
+
+func usage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: chainctl [-node <url>] [-output table|json] <command> [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(table, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	table.Flush()
+	fmt.Fprintln(w, "\nArguments:")
+	for _, cmd := range commands {
+		if cmd.args != "" {
+			fmt.Fprintf(w, "  %s %s\n", cmd.name, cmd.args)
+		}
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+}
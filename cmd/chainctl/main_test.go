@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diy.blockchain.org/m/api"
+	"diy.blockchain.org/m/blockchain"
+)
+
+// chainctl runs the command line args against node and returns what it printed
+func chainctl(t *testing.T, node string, stdin string, args ...string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if err := run(append([]string{"-node", node}, args...), strings.NewReader(stdin), &stdout, &stderr); err != nil {
+		t.Fatalf("chainctl %s: %v\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String()
+}
+
+// TestCommands sends a signed transfer from a new wallet, mines it and reads it back.
+func TestCommands(t *testing.T) {
+	genesis := blockchain.DefaultGenesis()
+	genesis.NativeDecimals = 2
+	bc, err := blockchain.NewBlockchainWithGenesis(genesis, blockchain.WithDifficulty(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(api.NewRouter(bc, nil, nil))
+	defer node.Close()
+
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	var w wallet
+	if err := json.Unmarshal([]byte(chainctl(t, node.URL, "", "-output", "json", "wallet", "create", "-file", path)), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Address == "" || w.PrivateKey != "" {
+		t.Errorf("expected the address without the private key once saved, got %+v", w)
+	}
+	if err := run([]string{"wallet", "create", "-file", path}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an existing wallet not to be overwritten")
+	}
+
+	for i := 0; i < 2; i++ {
+		chainctl(t, node.URL, "", "tx", "send", "-wallet", path, "-to", "carol", "-amount", "2.5")
+	}
+	if err := run([]string{"-node", node.URL, "tx", "send", "-wallet", path, "-to", "carol", "-amount", "0.001"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an amount beyond the decimals of the asset to be rejected")
+	}
+	if out := chainctl(t, node.URL, "", "mine"); !strings.Contains(out, "Index:") || !strings.Contains(out, w.Address) {
+		t.Errorf("expected the mined block with the transfers, got\n%s", out)
+	}
+
+	var account accountDto
+	if err := json.Unmarshal([]byte(chainctl(t, node.URL, "", "-output", "json", "balance", "carol")), &account); err != nil {
+		t.Fatal(err)
+	}
+	if len(account.Balances) != 1 || account.Balances[0].Formatted != "5.00" {
+		t.Errorf("expected carol to hold 5.00, got %+v", account)
+	}
+	var block blockchain.Block
+	if err := json.Unmarshal([]byte(chainctl(t, node.URL, "", "-output", "json", "block", "get", "latest")), &block); err != nil {
+		t.Fatal(err)
+	}
+	if block.Index != 2 || len(block.Transactions) != 2 || block.Transactions[1].Nonce != 1 {
+		t.Errorf("expected block 2 with nonces 0 and 1, got %+v", block)
+	}
+	if out := chainctl(t, node.URL, "", "block", "get", block.Hash); !strings.Contains(out, block.Hash) {
+		t.Errorf("expected the block found by hash, got\n%s", out)
+	}
+
+	if out := chainctl(t, node.URL, "", "chain", "show"); strings.Count(out, "\n") != 3 {
+		t.Errorf("expected a header row and two blocks, got\n%s", out)
+	}
+	if out := chainctl(t, node.URL, "", "chain", "export"); strings.Count(out, "\n") != 2 {
+		t.Errorf("expected two NDJSON lines, got\n%s", out)
+	}
+
+	chainctl(t, node.URL, "", "nodes", "add", "localhost:5002", "localhost:5001")
+	if out := chainctl(t, node.URL, "", "nodes", "list"); out != "NODE\nlocalhost:5001\nlocalhost:5002\n" {
+		t.Errorf("expected the peers in order, got\n%s", out)
+	}
+}
+
+// TestWalletSign signs a transaction offline, the node accepting it as is.
+func TestWalletSign(t *testing.T) {
+	bc := blockchain.NewBlockchain(blockchain.WithDifficulty(1))
+	public, private, err := blockchain.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, _ := blockchain.AddressFromPublicKey(public)
+	transaction := `{"chain_id": "` + bc.ChainID() + `", "recipient": "dave", "amount": 3}`
+	// The transaction carries its chain ID, so no node is asked for it
+	signed := chainctl(t, "localhost:1", transaction, "wallet", "sign", "-key", private)
+
+	var result blockchain.Transaction
+	if err := json.Unmarshal([]byte(signed), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Sender != sender || len(result.Signatures) != 1 {
+		t.Fatalf("expected a transaction signed by %s, got %s", sender, signed)
+	}
+	if _, err := bc.AddTransaction(&result); err != nil {
+		t.Errorf("expected the signed transaction to be valid, got %v", err)
+	}
+}
+
+// TestUsage lists the commands, and rejects unknown ones and bad arguments.
+func TestUsage(t *testing.T) {
+	if out := chainctl(t, "localhost:1", "", "help"); !strings.Contains(out, "tx send") || !strings.Contains(out, "-output") {
+		t.Errorf("expected the commands and flags, got\n%s", out)
+	}
+	for _, args := range [][]string{{"tx", "burn"}, {"-output", "xml", "mine"}, {"block", "get"}, {"nodes", "add"}, {"wallet", "sign"}} {
+		if err := run(args, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+			t.Errorf("expected chainctl %s to fail", strings.Join(args, " "))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// cli holds what the commands share: the node, the standard streams and the output format
+type cli struct {
+	client *client
+	in     io.Reader
+	out    io.Writer
+	stderr io.Writer
+	json   bool
+}
+
+// flags returns the flag set of the command name
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("chainctl "+name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	return flags
+}
+
+// print writes value as indented JSON with -output json, and the table rows writes otherwise
+func (c *cli) print(value interface{}, rows func(table io.Writer)) error {
+	if c.json {
+		encoder := json.NewEncoder(c.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	table := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	rows(table)
+	return table.Flush()
+}
+
+// row writes the cells of one table row
+func row(table io.Writer, cells ...interface{}) {
+	columns := make([]string, len(cells))
+	for i, cell := range cells {
+		columns[i] = fmt.Sprint(cell)
+	}
+	fmt.Fprintln(table, strings.Join(columns, "\t"))
+}
+
+// blockRows writes the fields of a block, then its transactions
+func blockRows(table io.Writer, block blockchain.Block) {
+	row(table, "Index:", block.Index)
+	row(table, "Hash:", block.Hash)
+	row(table, "Previous hash:", block.PreviousHash)
+	row(table, "Timestamp:", timestamp(block.Timestamp))
+	row(table, "Proof:", block.Proof)
+	row(table, "Transactions:", len(block.Transactions))
+	if len(block.Transactions) == 0 {
+		return
+	}
+	fmt.Fprintln(table)
+	row(table, "ID", "TYPE", "SENDER", "RECIPIENT", "AMOUNT", "ASSET", "NONCE")
+	for _, transaction := range block.Transactions {
+		row(table, short(transaction.ID), dash(transaction.Type), transaction.Sender, dash(transaction.Recipient), transaction.Amount, dash(transaction.Asset), transaction.Nonce)
+	}
+}
+
+// short keeps the start of a hash, enough to tell blocks and transactions apart in a table
+func short(hash string) string {
+	if len(hash) > 16 {
+		return hash[:16]
+	}
+	return hash
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func timestamp(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,87 @@
+// Command simulate runs the network simulator over every combination of node counts and difficulties it is given,
+// and writes one CSV row per run. The kernels of the simulated nodes log to stdout as well, keep them quiet with
+// LOG_LEVEL=error:
+//
+//	LOG_LEVEL=error go run ./cmd/simulate -nodes 2,4,8 -difficulty 1,2,3 -delay exponential:2s -runs 5 > results.csv
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"diy.blockchain.org/m/simulator"
+)
+
+func main() {
+	nodes := flag.String("nodes", "4", "comma separated node counts")
+	difficulties := flag.String("difficulty", "2", "comma separated difficulties")
+	hashRate := flag.Float64("hashrate", 100, "proofs tried per second by every node")
+	blocks := flag.Int("blocks", 100, "blocks mined per run")
+	delay := flag.String("delay", "exponential:1s", "delay distribution: constant:<d>, uniform:<min>:<max>, exponential:<mean> or normal:<mean>:<stddev>")
+	runs := flag.Int("runs", 1, "runs per combination, seeded from -seed upwards")
+	seed := flag.Int64("seed", 1, "seed of the first run")
+	flag.Parse()
+
+	if err := simulate(*nodes, *difficulties, *hashRate, *blocks, *delay, *runs, *seed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func simulate(nodes, difficulties string, hashRate float64, blocks int, delay string, runs int, seed int64) error {
+	nodeCounts, err := parseInts(nodes)
+	if err != nil {
+		return fmt.Errorf("invalid node counts: %w", err)
+	}
+	difficultyLevels, err := parseInts(difficulties)
+	if err != nil {
+		return fmt.Errorf("invalid difficulties: %w", err)
+	}
+	distribution, err := simulator.ParseDelay(delay)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write(simulator.Header)
+	for _, n := range nodeCounts {
+		for _, difficulty := range difficultyLevels {
+			for run := 0; run < runs; run++ {
+				result, err := simulator.Run(simulator.Config{
+					Nodes:      n,
+					Difficulty: difficulty,
+					HashRate:   hashRate,
+					Blocks:     blocks,
+					Delay:      distribution,
+					Seed:       seed + int64(run),
+				})
+				if err != nil {
+					return err
+				}
+				// Flush every row, long sweeps can be followed as they go
+				writer.Write(result.Record())
+				writer.Flush()
+				if err := writer.Error(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func parseInts(list string) ([]int, error) {
+	values := []int{}
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
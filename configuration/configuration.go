@@ -1,30 +1,226 @@
 package configuration
 
 import (
-	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
-	"diy.blockchain.org/m/logger"
+	"diy.blockchain.org/m/blockchain"
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	// DefaultPath is the configuration file read when no path is given and CONFIG_PATH isn't set, if it exists
+	DefaultPath = "config.yaml"
+	// PathEnv names the environment variable holding the path of the configuration file
+	PathEnv = "CONFIG_PATH"
+)
+
+// ErrInvalidConfig is returned for configurations the node can't start with
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config is the configuration of a node. Every field can be overridden by an environment variable, see EnvPrefix.
 type Config struct {
-	HttpPort string `yaml:"http_port"`
+	Network   NetworkConfig   `yaml:"network"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Mining    MiningConfig    `yaml:"mining"`
+	Consensus ConsensusConfig `yaml:"consensus"`
+	Peers     PeersConfig     `yaml:"peers"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	TLS       TLSConfig       `yaml:"tls"`
+	Limits    LimitsConfig    `yaml:"limits"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
+	// Path is the file the configuration was loaded from, empty when there was none
+	Path string `yaml:"-"`
+}
+
+// NetworkConfig tells where the API listens and how long connections may take
+type NetworkConfig struct {
+	// Host is the address to listen on, every interface when empty
+	Host              string        `yaml:"host"`
+	HttpPort          int           `yaml:"http_port"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout is how long in-flight requests get to complete once the node is asked to stop
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// StorageConfig tells what the node indexes besides the chain
+type StorageConfig struct {
+	// AddressIndex maintains the transactions of every address for /addresses/{address}/transactions
+	AddressIndex bool `yaml:"address_index"`
+}
+
+// MiningConfig tells if and when the node mines
+type MiningConfig struct {
+	// Enabled lets /mine mine blocks, it answers 503 Service Unavailable otherwise
+	Enabled bool `yaml:"enabled"`
+	// Interval mines a block every interval when transactions are pending, 0 mines on /mine only
+	Interval time.Duration `yaml:"interval"`
+}
+
+// ConsensusConfig tells which network the node belongs to
+type ConsensusConfig struct {
+	// GenesisFile is the genesis spec of the network, the default spec when empty. The difficulty is part of
+	// the spec, every node of a network must mine with the same one.
+	GenesisFile string `yaml:"genesis_file"`
 }
 
-var InstanceConfig Config
+// PeersConfig tells which nodes the node syncs with
+type PeersConfig struct {
+	// Nodes are registered when the node starts, as host:port or URLs
+	Nodes []string `yaml:"nodes"`
+	// SyncInterval resolves conflicts with the peers every interval, 0 resolves on /nodes/resolve only
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+// LoggingConfig tells what the node logs and how
+type LoggingConfig struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Encoding is json or console
+	Encoding string `yaml:"encoding"`
+}
 
-func LoadConfig(ctx context.Context, configPath string) {
-	if path, ok := os.LookupEnv("CONFIG_PATH"); ok {
-		configPath = path
+// TLSConfig serves the API over HTTPS when enabled
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// LimitsConfig protects the node from large or too many requests
+type LimitsConfig struct {
+	MaxBodyBytes   int64 `yaml:"max_body_bytes"`
+	MaxHeaderBytes int   `yaml:"max_header_bytes"`
+	// RequestsPerSecond is the number of requests a client IP can make per second, 0 for no limit. Burst
+	// requests can be made at once.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+}
+
+// WebhooksConfig tells where webhooks may deliver
+type WebhooksConfig struct {
+	// AllowedNetworks are CIDR ranges of private, loopback or link-local addresses webhooks may still target,
+	// like 10.0.0.0/8. Webhooks can only target public addresses otherwise.
+	AllowedNetworks []string `yaml:"allowed_networks"`
+}
+
+// Default returns the configuration of a node without configuration file nor environment overrides
+func Default() *Config {
+	return &Config{
+		Network: NetworkConfig{
+			HttpPort:          8080,
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Mining:  MiningConfig{Enabled: true},
+		Logging: LoggingConfig{Level: "info", Encoding: "json"},
+		Limits: LimitsConfig{
+			MaxBodyBytes:   1 << 20,
+			MaxHeaderBytes: 1 << 20,
+			Burst:          20,
+		},
 	}
-	yamlFile, err := os.ReadFile(configPath)
+}
 
+// Load reads the configuration file at path over the defaults, applies the environment overrides and validates
+// the result. Without a path it reads the file CONFIG_PATH names, or config.yaml if there is one.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(PathEnv)
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultPath); err == nil {
+			path = DefaultPath
+		}
+	}
+
+	config := Default()
+	if path != "" {
+		yamlFile, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Unknown keys are most likely typos, better stop than ignore them
+		if err := yaml.UnmarshalStrict(yamlFile, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		config.Path = path
+	}
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate returns every problem of the configuration at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidConfig}, args...)...))
+		}
+	}
+
+	check(c.Network.HttpPort > 0 && c.Network.HttpPort <= 65535, "network.http_port must be between 1 and 65535, got %d", c.Network.HttpPort)
+	check(c.Network.ReadHeaderTimeout >= 0, "network.read_header_timeout can't be negative")
+	check(c.Network.IdleTimeout >= 0, "network.idle_timeout can't be negative")
+	check(c.Network.ShutdownTimeout > 0, "network.shutdown_timeout must be positive")
+	check(c.Mining.Interval >= 0, "mining.interval can't be negative")
+	for i, node := range c.Peers.Nodes {
+		check(node != "", "peers.nodes[%d] is empty", i)
+	}
+	check(c.Peers.SyncInterval >= 0, "peers.sync_interval can't be negative")
+	check(ValidLogLevel(c.Logging.Level), "logging.level must be debug, info, warn or error, got %q", c.Logging.Level)
+	check(c.Logging.Encoding == "json" || c.Logging.Encoding == "console", "logging.encoding must be json or console, got %q", c.Logging.Encoding)
+	check(!c.TLS.Enabled || (c.TLS.CertFile != "" && c.TLS.KeyFile != ""), "tls.cert_file and tls.key_file are required when tls is enabled")
+	check(c.Limits.MaxBodyBytes > 0, "limits.max_body_bytes must be positive")
+	check(c.Limits.MaxHeaderBytes > 0, "limits.max_header_bytes must be positive")
+	check(c.Limits.RequestsPerSecond >= 0, "limits.requests_per_second can't be negative")
+	check(c.Limits.RequestsPerSecond == 0 || c.Limits.Burst > 0, "limits.burst must be positive when requests are limited")
+	for i, network := range c.Webhooks.AllowedNetworks {
+		_, _, err := net.ParseCIDR(network)
+		check(err == nil, "webhooks.allowed_networks[%d] must be a CIDR range like 10.0.0.0/8, got %q", i, network)
+	}
+	return errors.Join(errs...)
+}
+
+// ValidLogLevel tells if level is one of the levels the logger knows
+func ValidLogLevel(level string) bool {
+	return level == "debug" || level == "info" || level == "warn" || level == "error"
+}
+
+// Address returns the host:port the API listens on
+func (c *Config) Address() string {
+	return net.JoinHostPort(c.Network.Host, strconv.Itoa(c.Network.HttpPort))
+}
+
+// Genesis loads the genesis spec referenced by the configuration, falling back to the default one
+func (c *Config) Genesis() (*blockchain.Genesis, error) {
+	if c.Consensus.GenesisFile == "" {
+		return blockchain.DefaultGenesis(), nil
+	}
+
+	yamlFile, err := os.ReadFile(c.Consensus.GenesisFile)
 	if err != nil {
-		logger.Errorf("Failed to read config file: %v", err)
+		return nil, fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	genesis := blockchain.DefaultGenesis()
+	if err := yaml.Unmarshal(yamlFile, genesis); err != nil {
+		return nil, fmt.Errorf("failed to parse genesis file: %w", err)
 	}
 
-	if err := yaml.Unmarshal(yamlFile, &InstanceConfig); err != nil {
-		logger.Errorf("Failed to parse config file: %v", err)
+	if err := genesis.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %w", c.Consensus.GenesisFile, err)
 	}
+	return genesis, nil
 }
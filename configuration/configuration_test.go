@@ -0,0 +1,123 @@
+package configuration_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/configuration"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoad reads a file over the defaults, then the environment over the file.
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+network:
+  http_port: 9000
+mining:
+  interval: 5s
+peers:
+  nodes: ["localhost:5001"]
+`)
+	t.Setenv("BLOCKCHAIN_NETWORK_HOST", "127.0.0.1")
+	t.Setenv("BLOCKCHAIN_MINING_ENABLED", "false")
+	t.Setenv("BLOCKCHAIN_PEERS_NODES", "localhost:5002, http://localhost:5003")
+	t.Setenv("BLOCKCHAIN_LIMITS_REQUESTS_PER_SECOND", "2.5")
+	t.Setenv("BLOCKCHAIN_LIMITS_MAX_BODY_BYTES", "2048")
+	t.Setenv("BLOCKCHAIN_NETWORK_SHUTDOWN_TIMEOUT", "1m")
+
+	config, err := configuration.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Address() != "127.0.0.1:9000" || config.Mining.Interval != 5*time.Second || config.Mining.Enabled {
+		t.Errorf("expected the file and the environment to apply, got %+v", config)
+	}
+	if !slices.Equal(config.Peers.Nodes, []string{"localhost:5002", "http://localhost:5003"}) {
+		t.Errorf("expected the peers of the environment, got %v", config.Peers.Nodes)
+	}
+	if config.Limits.RequestsPerSecond != 2.5 || config.Limits.MaxBodyBytes != 2048 || config.Network.ShutdownTimeout != time.Minute {
+		t.Errorf("expected the limits of the environment, got %+v", config.Limits)
+	}
+	if config.Logging.Level != "info" || config.Network.IdleTimeout != 2*time.Minute {
+		t.Errorf("expected defaults for the fields set nowhere, got %+v", config)
+	}
+}
+
+// TestLoadErrors stops on unreadable files, unknown keys, malformed overrides and invalid values.
+func TestLoadErrors(t *testing.T) {
+	if _, err := configuration.Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected a missing file to be an error")
+	}
+	if _, err := configuration.Load(writeConfig(t, "http_port: 8080\n")); err == nil {
+		t.Error("expected an unknown key to be an error")
+	}
+
+	t.Setenv("BLOCKCHAIN_NETWORK_HTTP_PORT", "eighty")
+	if _, err := configuration.Load(writeConfig(t, "")); !errors.Is(err, configuration.ErrInvalidConfig) {
+		t.Errorf("expected a malformed override to be invalid, got %v", err)
+	}
+
+	t.Setenv("BLOCKCHAIN_NETWORK_HTTP_PORT", "70000")
+	t.Setenv("BLOCKCHAIN_LOGGING_LEVEL", "verbose")
+	t.Setenv("BLOCKCHAIN_TLS_ENABLED", "true")
+	t.Setenv("BLOCKCHAIN_WEBHOOKS_ALLOWED_NETWORKS", "10.0.0.0/8,localhost")
+	_, err := configuration.Load(writeConfig(t, ""))
+	if !errors.Is(err, configuration.ErrInvalidConfig) {
+		t.Fatalf("expected an invalid configuration, got %v", err)
+	}
+	for _, field := range []string{"network.http_port", "logging.level", "tls.cert_file", "webhooks.allowed_networks[1]"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %s to be reported, got %v", field, err)
+		}
+	}
+}
+
+// TestEnvVars names a variable for every field of every section.
+func TestEnvVars(t *testing.T) {
+	names := configuration.EnvVars()
+	for _, name := range []string{"BLOCKCHAIN_NETWORK_HTTP_PORT", "BLOCKCHAIN_STORAGE_ADDRESS_INDEX", "BLOCKCHAIN_CONSENSUS_GENESIS_FILE", "BLOCKCHAIN_TLS_KEY_FILE", "BLOCKCHAIN_LIMITS_BURST"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("expected %s in %v", name, names)
+		}
+	}
+	if len(names) != 21 {
+		t.Errorf("expected 21 variables, got %d", len(names))
+	}
+}
+
+// TestDefaults are valid, so a node starts without any configuration.
+func TestDefaults(t *testing.T) {
+	if err := configuration.Default().Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestDiff names the changed fields by their YAML path, and treats empty and missing lists alike.
+func TestDiff(t *testing.T) {
+	old, new := configuration.Default(), configuration.Default()
+	new.Peers.Nodes = []string{}
+	new.Path = "elsewhere.yaml"
+	if changed := configuration.Diff(old, new); len(changed) != 0 {
+		t.Errorf("expected no change, got %v", changed)
+	}
+
+	new.Network.HttpPort = 9000
+	new.Peers.Nodes = []string{"localhost:5001"}
+	new.Limits.Burst = 1
+	if changed := configuration.Diff(old, new); !slices.Equal(changed, []string{"network.http_port", "peers.nodes", "limits.burst"}) {
+		t.Errorf("expected the port, the peers and the burst to change, got %v", changed)
+	}
+}
@@ -0,0 +1,123 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the names of the environment variables overriding the configuration. The rest of a name is
+// the YAML path of its field in upper case: BLOCKCHAIN_NETWORK_HTTP_PORT overrides network.http_port. Lists are
+// comma separated and durations are written like 30s.
+const EnvPrefix = "BLOCKCHAIN"
+
+// EnvVars returns the names of the environment variables overriding the configuration, in the order of its fields
+func EnvVars() []string {
+	names := []string{}
+	walkFields(reflect.ValueOf(&Config{}).Elem(), nil, func(path []string, _ reflect.Value) {
+		names = append(names, envName(path))
+	})
+	return names
+}
+
+// Diff returns the YAML paths of the fields that differ between two configurations, like network.http_port
+func Diff(old, new *Config) []string {
+	values := map[string]reflect.Value{}
+	walkFields(reflect.ValueOf(old).Elem(), nil, func(path []string, field reflect.Value) {
+		values[strings.Join(path, ".")] = field
+	})
+	changed := []string{}
+	walkFields(reflect.ValueOf(new).Elem(), nil, func(path []string, field reflect.Value) {
+		name := strings.Join(path, ".")
+		old := values[name]
+		// An empty list is the same as no list
+		if field.Kind() == reflect.Slice && field.Len() == 0 && old.Len() == 0 {
+			return
+		}
+		if !reflect.DeepEqual(old.Interface(), field.Interface()) {
+			changed = append(changed, name)
+		}
+	})
+	return changed
+}
+
+func envName(path []string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.Join(path, "_"))
+}
+
+// applyEnv overrides the fields whose environment variable lookup finds
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	walkFields(reflect.ValueOf(c).Elem(), nil, func(path []string, field reflect.Value) {
+		name := envName(path)
+		raw, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if err := setField(field, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// walkFields calls visit with the YAML path of every field of the sections of value, fields YAML ignores excepted
+func walkFields(value reflect.Value, prefix []string, visit func(path []string, field reflect.Value)) {
+	for i := 0; i < value.NumField(); i++ {
+		tag, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+		if tag == "-" {
+			continue
+		}
+		path := append(append([]string{}, prefix...), tag)
+		if field := value.Field(i); field.Kind() == reflect.Struct {
+			walkFields(field, path, visit)
+		} else {
+			visit(path, field)
+		}
+	}
+}
+
+func setField(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+	case string:
+		field.SetString(raw)
+	case bool:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case int, int64:
+		value, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(value)
+	case float64:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(value)
+	case []string:
+		values := []string{}
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("fields of type %s can't be set from the environment", field.Type())
+	}
+	return nil
+}
@@ -12,12 +12,17 @@ var APP_LOG_LEVEL = os.Getenv("LOG_LEVEL")
 var APP_LOG_ENCODING = os.Getenv("LOG_ENCODING")
 
 var log *zap.SugaredLogger
+var level zap.AtomicLevel
 var initLock sync.Mutex
 
 func init() {
 	initLock.Lock()
 	defer initLock.Unlock()
-	level := zap.NewAtomicLevelAt(getLevelLogger(APP_LOG_LEVEL))
+	level = zap.NewAtomicLevelAt(getLevelLogger(APP_LOG_LEVEL))
+	log = build(getLogEncoding(APP_LOG_ENCODING))
+}
+
+func build(encoding string) *zap.SugaredLogger {
 	encoder := zapcore.EncoderConfig{
 		// Keys can be anything except the empty string.
 		TimeKey:        "timestamp",
@@ -39,7 +44,7 @@ func init() {
 		DisableCaller:     false,
 		DisableStacktrace: false,
 		Sampling:          nil,
-		Encoding:          getLogEncoding(APP_LOG_ENCODING),
+		Encoding:          encoding,
 		EncoderConfig:     encoder,
 		OutputPaths:       []string{"stdout"},
 		ErrorOutputPaths:  []string{"stdout"},
@@ -47,7 +52,21 @@ func init() {
 	}
 
 	zapLogger, _ := config.Build()
-	log = zapLogger.Sugar()
+	return zapLogger.Sugar()
+}
+
+// Configure sets the level and the encoding, json or console, of the entries logged from now on. It replaces
+// the logger, so call it at startup, before logging from several goroutines.
+func Configure(levelName, encoding string) {
+	initLock.Lock()
+	defer initLock.Unlock()
+	level.SetLevel(getLevelLogger(levelName))
+	log = build(getLogEncoding(encoding))
+}
+
+// SetLevel changes the minimum level of the entries logged from now on: debug, info, warn or error
+func SetLevel(name string) {
+	level.SetLevel(getLevelLogger(name))
 }
 
 func getLevelLogger(level string) zapcore.Level {
@@ -111,3 +130,8 @@ func Fatal(args ...interface{}) {
 func Fatalf(format string, args ...interface{}) {
 	log.Fatalf(format, args...)
 }
+
+// Sync flushes the buffered log entries, call it before exiting
+func Sync() error {
+	return log.Sync()
+}
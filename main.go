@@ -2,13 +2,68 @@ package main
 
 import (
 	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"diy.blockchain.org/m/api"
 	"diy.blockchain.org/m/configuration"
+	"diy.blockchain.org/m/logger"
+	"go.uber.org/zap"
 )
 
 func main() {
-	ctx := context.Background()
-	configuration.LoadConfig(ctx, "config.yaml")
-	api.Start(ctx, &configuration.InstanceConfig)
+	configPath := flag.String("config", "", "path of the configuration file, $CONFIG_PATH or config.yaml when there is one by default")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer logger.Sync()
+
+	config, err := configuration.Load(*configPath)
+	if err != nil {
+		logger.Fatal("Invalid configuration.", zap.Error(err))
+	}
+	logger.Configure(config.Logging.Level, config.Logging.Encoding)
+	server, err := api.NewServer(config)
+	if err != nil {
+		logger.Fatal("Server couldn't be created.", zap.Error(err))
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		failed <- server.Start()
+	}()
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	for running := true; running; {
+		select {
+		case err := <-failed:
+			logger.Fatal("Server didn't start.", zap.Error(err))
+		case <-hangup:
+			reload(server)
+		case <-ctx.Done():
+			running = false
+		}
+	}
+
+	// A second signal kills the node right away
+	stop()
+	logger.Infof("Shutting down, waiting up to %s for in-flight requests", config.Network.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Network.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf("Server didn't shut down cleanly: %v", err)
+	}
+}
+
+// reload applies the configuration file again, the node keeps its configuration if the file is invalid
+func reload(server *api.Server) {
+	result, err := server.Reload()
+	if err != nil {
+		logger.Errorf("Configuration not reloaded: %v", err)
+		return
+	}
+	logger.Infof("Configuration reloaded, applied %v, restart required for %v", result.Applied, result.RestartRequired)
 }
@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Delay draws the time a message takes to reach another node
+type Delay interface {
+	Sample(random *rand.Rand) time.Duration
+	// String returns the delay in the syntax ParseDelay reads
+	String() string
+}
+
+// ConstantDelay delivers every message after the same time
+type ConstantDelay struct {
+	Delay time.Duration
+}
+
+func (d ConstantDelay) Sample(*rand.Rand) time.Duration {
+	return d.Delay
+}
+
+func (d ConstantDelay) String() string {
+	return fmt.Sprintf("constant:%s", d.Delay)
+}
+
+// UniformDelay delivers messages after a time drawn uniformly between Min and Max
+type UniformDelay struct {
+	Min, Max time.Duration
+}
+
+func (d UniformDelay) Sample(random *rand.Rand) time.Duration {
+	return d.Min + time.Duration(random.Int63n(int64(d.Max-d.Min)+1))
+}
+
+func (d UniformDelay) String() string {
+	return fmt.Sprintf("uniform:%s:%s", d.Min, d.Max)
+}
+
+// ExponentialDelay delivers messages after a time drawn from an exponential distribution, most are fast and a
+// few are much slower than the mean
+type ExponentialDelay struct {
+	Mean time.Duration
+}
+
+func (d ExponentialDelay) Sample(random *rand.Rand) time.Duration {
+	return time.Duration(random.ExpFloat64() * float64(d.Mean))
+}
+
+func (d ExponentialDelay) String() string {
+	return fmt.Sprintf("exponential:%s", d.Mean)
+}
+
+// NormalDelay delivers messages after a time drawn from a normal distribution, cut at zero
+type NormalDelay struct {
+	Mean, StdDev time.Duration
+}
+
+func (d NormalDelay) Sample(random *rand.Rand) time.Duration {
+	return max(0, time.Duration(random.NormFloat64()*float64(d.StdDev))+d.Mean)
+}
+
+func (d NormalDelay) String() string {
+	return fmt.Sprintf("normal:%s:%s", d.Mean, d.StdDev)
+}
+
+// ParseDelay reads a delay distribution: constant:<d>, uniform:<min>:<max>, exponential:<mean> or
+// normal:<mean>:<stddev>, where durations are written like 1.5s or 200ms
+func ParseDelay(spec string) (Delay, error) {
+	kind, rest, _ := strings.Cut(spec, ":")
+	parts := strings.Split(rest, ":")
+	durations := make([]time.Duration, len(parts))
+	for i, part := range parts {
+		duration, err := time.ParseDuration(part)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid delay %q: %q is not a positive duration", spec, part)
+		}
+		durations[i] = duration
+	}
+
+	arguments := map[string]int{"constant": 1, "uniform": 2, "exponential": 1, "normal": 2}
+	if expected, ok := arguments[kind]; !ok {
+		return nil, fmt.Errorf("invalid delay %q: the distribution must be constant, uniform, exponential or normal", spec)
+	} else if len(durations) != expected {
+		return nil, fmt.Errorf("invalid delay %q: %s takes %d durations", spec, kind, expected)
+	}
+
+	switch kind {
+	case "constant":
+		return ConstantDelay{Delay: durations[0]}, nil
+	case "uniform":
+		if durations[0] > durations[1] {
+			return nil, fmt.Errorf("invalid delay %q: the minimum is above the maximum", spec)
+		}
+		return UniformDelay{Min: durations[0], Max: durations[1]}, nil
+	case "exponential":
+		return ExponentialDelay{Mean: durations[0]}, nil
+	default:
+		return NormalDelay{Mean: durations[0], StdDev: durations[1]}, nil
+	}
+}
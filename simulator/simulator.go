@@ -0,0 +1,313 @@
+// Package simulator measures the consensus of the kernel on a simulated network. Nodes are real blockchains driven
+// by a discrete-event loop on a virtual clock: they mine blocks and exchange chains without sockets nor sleeps, so a
+// run only takes the time of its proofs of work and the same seed always gives the same result.
+package simulator
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+// ErrInvalidConfig is returned for configurations that can't be simulated
+var ErrInvalidConfig = errors.New("invalid simulation config")
+
+// Header holds the names of the CSV columns of Result.Record
+var Header = []string{
+	"nodes", "difficulty", "hash_rate", "delay", "seed", "blocks",
+	"mined", "length", "orphans", "orphan_rate", "reorgs", "max_fork_depth",
+	"mean_convergence_s", "max_convergence_s", "duration_s",
+}
+
+// Config describes a simulated network where every node sends its chain to every other node when it mines a block
+type Config struct {
+	Nodes int
+	// Difficulty is the number of leading zeros of the proofs of work
+	Difficulty int
+	// HashRate is the number of proofs a node tries per second
+	HashRate float64
+	// Blocks is the number of blocks mined before mining stops. If the nodes still disagree on the tip once every
+	// chain is delivered, they mine one more block and try again.
+	Blocks int
+	// Delay is the distribution of the time chains take to reach the other nodes
+	Delay Delay
+	// Seed seeds every random draw of the simulation
+	Seed int64
+}
+
+// Validate checks that a config describes a network that can be simulated
+func (c Config) Validate() error {
+	switch {
+	case c.Nodes < 1:
+		return fmt.Errorf("%w: a network needs at least one node, got %d", ErrInvalidConfig, c.Nodes)
+	case c.Difficulty < 1 || c.Difficulty > 64:
+		return fmt.Errorf("%w: difficulty must be between 1 and 64, got %d", ErrInvalidConfig, c.Difficulty)
+	case c.HashRate <= 0:
+		return fmt.Errorf("%w: hash rate must be positive, got %g", ErrInvalidConfig, c.HashRate)
+	case c.Blocks < 1:
+		return fmt.Errorf("%w: at least one block must be mined, got %d", ErrInvalidConfig, c.Blocks)
+	case c.Delay == nil:
+		return fmt.Errorf("%w: a delay distribution is required", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Result holds what a simulation measured
+type Result struct {
+	Config
+	// Mined is the number of blocks mined, Length the length of the chain the nodes converged on, genesis included
+	Mined, Length int
+	// Orphans is the number of mined blocks left out of that chain
+	Orphans int
+	// Reorgs is the number of times a node disconnected blocks to adopt another chain, MaxForkDepth the most
+	// blocks disconnected at once
+	Reorgs, MaxForkDepth int
+	// MeanConvergence and MaxConvergence measure, for the blocks of the chain, the time from their mining to the
+	// moment the last node connected them for good
+	MeanConvergence, MaxConvergence time.Duration
+	// Duration is the simulated time from the genesis block to convergence
+	Duration time.Duration
+}
+
+// OrphanRate returns the share of the mined blocks left out of the chain
+func (r Result) OrphanRate() float64 {
+	if r.Mined == 0 {
+		return 0
+	}
+	return float64(r.Orphans) / float64(r.Mined)
+}
+
+// Record returns the result as a CSV row, in the order of Header
+func (r Result) Record() []string {
+	seconds := func(d time.Duration) string {
+		return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+	}
+	return []string{
+		strconv.Itoa(r.Nodes), strconv.Itoa(r.Difficulty), strconv.FormatFloat(r.HashRate, 'g', -1, 64),
+		r.Delay.String(), strconv.FormatInt(r.Seed, 10), strconv.Itoa(r.Blocks),
+		strconv.Itoa(r.Mined), strconv.Itoa(r.Length), strconv.Itoa(r.Orphans),
+		strconv.FormatFloat(r.OrphanRate(), 'f', 4, 64), strconv.Itoa(r.Reorgs), strconv.Itoa(r.MaxForkDepth),
+		seconds(r.MeanConvergence), seconds(r.MaxConvergence), seconds(r.Duration),
+	}
+}
+
+// Run simulates a network until its nodes converge and returns what it measured
+func Run(config Config) (Result, error) {
+	if err := config.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	start := time.Unix(blockchain.DefaultGenesisTimestamp, 0)
+	s := &simulation{
+		config: config,
+		random: rand.New(rand.NewSource(config.Seed)),
+		target: config.Blocks,
+		mined:  make(map[string]time.Duration),
+	}
+	for id := 0; id < config.Nodes; id++ {
+		bc := blockchain.NewBlockchain(
+			blockchain.WithDifficulty(config.Difficulty),
+			blockchain.WithClock(func() time.Time { return start.Add(s.now) }),
+		)
+		n := &node{id: id, bc: bc, simulation: s, miner: fmt.Sprintf("miner-%d", id), connected: make(map[string]time.Duration)}
+		bc.AddObserver(n)
+		s.nodes = append(s.nodes, n)
+	}
+
+	for {
+		for _, n := range s.nodes {
+			s.scheduleMining(n)
+		}
+		if err := s.run(); err != nil {
+			return Result{}, err
+		}
+		if s.converged() {
+			return s.measure(), nil
+		}
+		// Equally long chains stay split until one of them grows
+		s.target++
+	}
+}
+
+// event is a block found by a node, or a chain reaching it
+type event struct {
+	at   time.Duration
+	seq  int
+	node *node
+	// chain is the chain delivered to the node, mining events have none
+	chain []blockchain.Block
+}
+
+// events is a queue of events ordered by time, then by scheduling order
+type events []*event
+
+func (e events) Len() int { return len(e) }
+func (e events) Less(i, j int) bool {
+	if e[i].at != e[j].at {
+		return e[i].at < e[j].at
+	}
+	return e[i].seq < e[j].seq
+}
+func (e events) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e *events) Push(x any)   { *e = append(*e, x.(*event)) }
+func (e *events) Pop() any {
+	old := *e
+	last := old[len(old)-1]
+	*e = old[:len(old)-1]
+	return last
+}
+
+// node is a simulated node, it observes its own blockchain to know when blocks come and go
+type node struct {
+	id         int
+	bc         *blockchain.Blockchain
+	simulation *simulation
+	// miner is the sender of the anchor every block of the node carries, so blocks of different nodes never
+	// hash the same
+	miner string
+	// connected holds when every block was last connected
+	connected map[string]time.Duration
+	// disconnected counts the blocks disconnected by the chain being delivered
+	disconnected int
+}
+
+func (n *node) BlockConnected(block blockchain.Block) {
+	n.connected[block.Hash] = n.simulation.now
+}
+
+func (n *node) BlockDisconnected(blockchain.Block) {
+	n.disconnected++
+}
+
+type simulation struct {
+	config Config
+	random *rand.Rand
+	nodes  []*node
+	queue  events
+	seq    int
+	now    time.Duration
+	// target is the number of blocks to mine before mining stops
+	target int
+	// mined holds when every block was mined
+	mined  map[string]time.Duration
+	reorgs int
+	depth  int
+}
+
+func (s *simulation) schedule(at time.Duration, n *node, chain []blockchain.Block) {
+	s.seq++
+	heap.Push(&s.queue, &event{at: at, seq: s.seq, node: n, chain: chain})
+}
+
+// scheduleMining draws when a node finds its next block. The kernel's proof of work doesn't cover the content of
+// blocks, so every node mining on a tip would find the same proof: who finds a block first is drawn instead, from
+// an exponential distribution whose mean is the expected number of tries at the difficulty over the hash rate.
+func (s *simulation) scheduleMining(n *node) {
+	mean := math.Pow(16, float64(s.config.Difficulty)) / s.config.HashRate
+	s.schedule(s.now+time.Duration(s.random.ExpFloat64()*mean*float64(time.Second)), n, nil)
+}
+
+// run processes events until there are none left, mining stops once the target is reached
+func (s *simulation) run() error {
+	for s.queue.Len() > 0 {
+		e := heap.Pop(&s.queue).(*event)
+		s.now = e.at
+		if e.chain != nil {
+			if err := s.deliver(e.node, e.chain); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(s.mined) < s.target {
+			if err := s.mine(e.node); err != nil {
+				return err
+			}
+			s.scheduleMining(e.node)
+		}
+	}
+	return nil
+}
+
+// mine has a node mine a block on its tip with the kernel's proof of work, and send its chain to the others
+func (s *simulation) mine(n *node) error {
+	anchor := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", n.miner, len(s.mined))))
+	transaction := blockchain.Transaction{
+		Type:       blockchain.TxTypeAnchor,
+		Sender:     n.miner,
+		AnchorHash: hex.EncodeToString(anchor[:]),
+		Nonce:      n.bc.PendingNonce(n.miner),
+	}
+	if _, err := n.bc.AddTransaction(&transaction); err != nil {
+		return fmt.Errorf("node %d: %w", n.id, err)
+	}
+	block := n.bc.NewBlock(n.bc.LastBlock().Hash)
+	s.mined[block.Hash] = s.now
+
+	chain, _ := n.bc.Blocks(1, block.Index)
+	for _, peer := range s.nodes {
+		if peer != n {
+			s.schedule(s.now+s.config.Delay.Sample(s.random), peer, chain)
+		}
+	}
+	return nil
+}
+
+// deliver has a node adopt a chain if it is longer than its own
+func (s *simulation) deliver(n *node, chain []blockchain.Block) error {
+	n.disconnected = 0
+	if _, err := n.bc.AdoptChain(chain); err != nil {
+		return fmt.Errorf("node %d rejected a chain: %w", n.id, err)
+	}
+	if n.disconnected > 0 {
+		s.reorgs++
+		s.depth = max(s.depth, n.disconnected)
+	}
+	return nil
+}
+
+// converged tells if every node has the same tip
+func (s *simulation) converged() bool {
+	tip := s.nodes[0].bc.LastBlock().Hash
+	for _, n := range s.nodes[1:] {
+		if n.bc.LastBlock().Hash != tip {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *simulation) measure() Result {
+	chain, length := s.nodes[0].bc.Blocks(1, math.MaxInt)
+	result := Result{
+		Config:       s.config,
+		Mined:        len(s.mined),
+		Length:       length,
+		Orphans:      len(s.mined) - (length - 1),
+		Reorgs:       s.reorgs,
+		MaxForkDepth: s.depth,
+		Duration:     s.now,
+	}
+
+	var total time.Duration
+	for _, block := range chain[1:] {
+		var last time.Duration
+		for _, n := range s.nodes {
+			last = max(last, n.connected[block.Hash])
+		}
+		convergence := last - s.mined[block.Hash]
+		total += convergence
+		result.MaxConvergence = max(result.MaxConvergence, convergence)
+	}
+	if len(chain) > 1 {
+		result.MeanConvergence = total / time.Duration(len(chain)-1)
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package simulator_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/simulator"
+)
+
+func config(nodes int, delay simulator.Delay) simulator.Config {
+	// Every node finds a block every second on average
+	return simulator.Config{Nodes: nodes, Difficulty: 2, HashRate: 256, Blocks: 30, Delay: delay, Seed: 7}
+}
+
+// TestDeterminism runs the same simulation twice and expects the same measures.
+func TestDeterminism(t *testing.T) {
+	delay := simulator.ExponentialDelay{Mean: 500 * time.Millisecond}
+	first, err := simulator.Run(config(4, delay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := simulator.Run(config(4, delay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(first.Record(), second.Record()) {
+		t.Errorf("expected identical runs, got %v and %v", first.Record(), second.Record())
+	}
+	if first.Mined < 30 || first.Length-1+first.Orphans != first.Mined {
+		t.Errorf("expected every mined block to be in the chain or orphaned, got %+v", first)
+	}
+}
+
+// TestDelays expects no forks when chains arrive instantly, and orphans once they take longer than blocks.
+func TestDelays(t *testing.T) {
+	instant, err := simulator.Run(config(4, simulator.ConstantDelay{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instant.Orphans != 0 || instant.Reorgs != 0 || instant.MaxConvergence != 0 || instant.Length != 31 {
+		t.Errorf("expected a single chain of 30 blocks, got %+v", instant)
+	}
+
+	slow, err := simulator.Run(config(4, simulator.UniformDelay{Min: time.Second, Max: 3 * time.Second}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slow.Orphans == 0 || slow.Reorgs == 0 || slow.MaxForkDepth == 0 {
+		t.Errorf("expected orphans and reorgs, got %+v", slow)
+	}
+	if slow.MeanConvergence < time.Second || slow.MaxConvergence < slow.MeanConvergence {
+		t.Errorf("expected blocks to take at least the minimum delay to converge, got %+v", slow)
+	}
+}
+
+// TestParseDelay reads delay distributions and rejects malformed ones.
+func TestParseDelay(t *testing.T) {
+	tests := map[string]simulator.Delay{
+		"constant:2s":          simulator.ConstantDelay{Delay: 2 * time.Second},
+		"uniform:100ms:1s":     simulator.UniformDelay{Min: 100 * time.Millisecond, Max: time.Second},
+		"exponential:1.5s":     simulator.ExponentialDelay{Mean: 1500 * time.Millisecond},
+		"normal:1s:200ms":      simulator.NormalDelay{Mean: time.Second, StdDev: 200 * time.Millisecond},
+		"constant":             nil,
+		"uniform:1s":           nil,
+		"uniform:2s:1s":        nil,
+		"exponential:-1s":      nil,
+		"poisson:1s":           nil,
+		"exponential:1 second": nil,
+	}
+	for spec, expected := range tests {
+		delay, err := simulator.ParseDelay(spec)
+		if expected == nil {
+			if err == nil {
+				t.Errorf("expected %q to be rejected, got %v", spec, delay)
+			}
+			continue
+		}
+		if err != nil || delay != expected {
+			t.Errorf("expected %q to be %v, got %v (%v)", spec, expected, delay, err)
+		}
+		if delay != nil && delay.String() != expected.String() {
+			t.Errorf("expected %q to print as %s, got %s", spec, expected, delay)
+		}
+	}
+}
+
+// TestInvalidConfig rejects configurations that can't be simulated.
+func TestInvalidConfig(t *testing.T) {
+	valid := config(2, simulator.ConstantDelay{})
+	for name, change := range map[string]func(*simulator.Config){
+		"no nodes":   func(c *simulator.Config) { c.Nodes = 0 },
+		"difficulty": func(c *simulator.Config) { c.Difficulty = 0 },
+		"hash rate":  func(c *simulator.Config) { c.HashRate = 0 },
+		"blocks":     func(c *simulator.Config) { c.Blocks = 0 },
+		"delay":      func(c *simulator.Config) { c.Delay = nil },
+	} {
+		config := valid
+		change(&config)
+		if _, err := simulator.Run(config); !errors.Is(err, simulator.ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package testnet runs networks of nodes in one process for consensus tests. The network between them can be
+// partitioned, slowed down and made to drop requests, and tests assert that the nodes eventually converge.
+package testnet
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/api"
+	"diy.blockchain.org/m/blockchain"
+)
+
+// ErrUnreachable is returned for requests between partitioned nodes, and for requests the network dropped
+var ErrUnreachable = errors.New("node unreachable")
+
+// Node is a node of the network, serving the API of its own blockchain
+type Node struct {
+	ID         int
+	Blockchain *blockchain.Blockchain
+	// Address is the host:port the other nodes reach this one at
+	Address string
+}
+
+// Network links nodes through a simulated network
+type Network struct {
+	Nodes []*Node
+
+	// ids maps the address of every node to its ID
+	ids map[string]int
+	// groups holds the partition of every node, nodes of different partitions can't reach each other
+	groups    []int
+	latency   time.Duration
+	dropRate  float64
+	random    *rand.Rand
+	transport *http.Transport
+	mu        sync.Mutex
+}
+
+// New starts n nodes from the same genesis spec, the default one when nil. They know nothing of each other
+// until Connect is called, and are stopped when the test ends. Drops are drawn from a generator seeded with 1,
+// see Seed.
+func New(t testing.TB, n int, genesis *blockchain.Genesis) *Network {
+	t.Helper()
+	if genesis == nil {
+		genesis = blockchain.DefaultGenesis()
+	}
+
+	network := &Network{
+		ids:       make(map[string]int),
+		groups:    make([]int, n),
+		random:    rand.New(rand.NewSource(1)),
+		transport: &http.Transport{},
+	}
+	for id := 0; id < n; id++ {
+		bc, err := blockchain.NewBlockchainWithGenesis(genesis)
+		if err != nil {
+			t.Fatalf("node %d couldn't be created: %v", id, err)
+		}
+		bc.SetHTTPClient(&http.Client{Transport: &link{network: network, from: id}, Timeout: 10 * time.Second})
+		server := httptest.NewServer(api.NewRouter(bc, nil, nil))
+		t.Cleanup(server.Close)
+
+		node := &Node{ID: id, Blockchain: bc, Address: strings.TrimPrefix(server.URL, "http://")}
+		network.Nodes = append(network.Nodes, node)
+		network.ids[node.Address] = id
+	}
+	t.Cleanup(network.transport.CloseIdleConnections)
+	return network
+}
+
+// Connect registers every node as a peer of every other node
+func (n *Network) Connect() {
+	for _, node := range n.Nodes {
+		for _, peer := range n.Nodes {
+			if peer != node {
+				node.Blockchain.RegisterNode(peer.Address)
+			}
+		}
+	}
+}
+
+// Partition splits the network: nodes only reach the nodes of their group. Nodes left out of every group are isolated.
+func (n *Network) Partition(groups ...[]int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for id := range n.groups {
+		n.groups[id] = len(groups) + id
+	}
+	for group, ids := range groups {
+		for _, id := range ids {
+			n.groups[id] = group
+		}
+	}
+}
+
+// Heal lets every node reach every other node again
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for id := range n.groups {
+		n.groups[id] = 0
+	}
+}
+
+// SetLatency delays every request between nodes
+func (n *Network) SetLatency(latency time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.latency = latency
+}
+
+// SetDropRate makes the network drop a fraction of the requests between nodes, from 0 to 1
+func (n *Network) SetDropRate(rate float64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.dropRate = rate
+}
+
+// Seed reseeds the generator deciding which requests are dropped, so failing runs can be replayed
+func (n *Network) Seed(seed int64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.random = rand.New(rand.NewSource(seed))
+}
+
+// Mine makes a node mine a block with its pending transactions
+func (n *Network) Mine(id int) blockchain.Block {
+	bc := n.Nodes[id].Blockchain
+	return bc.NewBlock(bc.LatestBlock().Hash)
+}
+
+// Sync makes every node resolve conflicts with its peers once, in order, and returns how many replaced their chain
+func (n *Network) Sync() int {
+	replaced := 0
+	for _, node := range n.Nodes {
+		if node.Blockchain.ResolveConflicts() {
+			replaced++
+		}
+	}
+	return replaced
+}
+
+// Tips returns the hash of the last block of every node
+func (n *Network) Tips() []string {
+	tips := make([]string, len(n.Nodes))
+	for id, node := range n.Nodes {
+		tips[id] = node.Blockchain.LatestBlock().Hash
+	}
+	return tips
+}
+
+// Converged tells if every node has the same chain
+func (n *Network) Converged() bool {
+	tips := n.Tips()
+	for _, tip := range tips {
+		if tip != tips[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// AwaitConvergence syncs the network until every node has the same chain, for at most rounds rounds
+func (n *Network) AwaitConvergence(rounds int) error {
+	for round := 0; round < rounds; round++ {
+		if n.Converged() {
+			return nil
+		}
+		n.Sync()
+	}
+	if n.Converged() {
+		return nil
+	}
+
+	lengths := make([]string, len(n.Nodes))
+	for id, node := range n.Nodes {
+		lengths[id] = fmt.Sprintf("node %d: %d blocks, tip %.8s", id, node.Blockchain.LatestBlock().Index, node.Blockchain.LatestBlock().Hash)
+	}
+	return fmt.Errorf("network didn't converge after %d rounds: %s", rounds, strings.Join(lengths, "; "))
+}
+
+// link carries the requests of a node through the simulated network
+type link struct {
+	network *Network
+	from    int
+}
+
+func (l *link) RoundTrip(request *http.Request) (*http.Response, error) {
+	n := l.network
+	n.mu.Lock()
+	to, known := n.ids[request.URL.Host]
+	reachable := !known || n.groups[l.from] == n.groups[to]
+	dropped := n.dropRate > 0 && n.random.Float64() < n.dropRate
+	latency := n.latency
+	n.mu.Unlock()
+
+	if !reachable {
+		return nil, fmt.Errorf("%w: node %d is partitioned from node %d", ErrUnreachable, l.from, to)
+	}
+	if dropped {
+		return nil, fmt.Errorf("%w: request of node %d to %s was dropped", ErrUnreachable, l.from, request.URL.Host)
+	}
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+	return n.transport.RoundTrip(request)
+}
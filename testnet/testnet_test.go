@@ -0,0 +1,90 @@
+package testnet_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+	"diy.blockchain.org/m/testnet"
+)
+
+// TestConvergence spreads the longest chain to every node.
+func TestConvergence(t *testing.T) {
+	network := testnet.New(t, 4, nil)
+	network.Connect()
+
+	network.Mine(0)
+	network.Mine(0)
+	network.Mine(2)
+	if network.Converged() {
+		t.Fatal("expected the nodes to diverge before syncing")
+	}
+	if err := network.AwaitConvergence(3); err != nil {
+		t.Fatal(err)
+	}
+	if length := network.Nodes[3].Blockchain.LatestBlock().Index; length != 3 {
+		t.Errorf("expected the 3 blocks of node 0 to win, got %d", length)
+	}
+}
+
+// TestPartition converges each side of a partition on its own, then the whole network once healed.
+func TestPartition(t *testing.T) {
+	network := testnet.New(t, 4, nil)
+	network.Connect()
+	network.Partition([]int{0, 1}, []int{2, 3})
+
+	transaction := blockchain.Transaction{Sender: "Alice", Recipient: "Bob", Amount: 1}
+	if _, err := network.Nodes[0].Blockchain.AddTransaction(&transaction); err != nil {
+		t.Fatal(err)
+	}
+	network.Mine(0)
+	for i := 0; i < 3; i++ {
+		network.Mine(3)
+	}
+	network.Sync()
+	tips := network.Tips()
+	if tips[0] != tips[1] || tips[2] != tips[3] || tips[0] == tips[2] {
+		t.Fatalf("expected each side of the partition to converge on its own chain, got %v", tips)
+	}
+
+	network.Heal()
+	if err := network.AwaitConvergence(3); err != nil {
+		t.Fatal(err)
+	}
+	if pending := network.Nodes[0].Blockchain.PendingTransactions(); len(pending) != 1 || pending[0].ID != transaction.ID {
+		t.Errorf("expected the transaction of the losing side to be pending again, got %+v", pending)
+	}
+}
+
+// TestFaults converges despite dropped requests, and delays requests by the latency of the network.
+func TestFaults(t *testing.T) {
+	network := testnet.New(t, 3, nil)
+	network.Connect()
+	network.SetDropRate(0.5)
+	network.Seed(42)
+
+	network.Mine(1)
+	network.Mine(1)
+	if err := network.AwaitConvergence(20); err != nil {
+		t.Fatal(err)
+	}
+
+	network.SetDropRate(0)
+	network.SetLatency(20 * time.Millisecond)
+	start := time.Now()
+	network.Sync()
+	// Every node asks its 2 peers
+	if elapsed := time.Since(start); elapsed < 6*20*time.Millisecond {
+		t.Errorf("expected requests to be delayed, syncing took %s", elapsed)
+	}
+
+	network.SetDropRate(1)
+	client := &http.Client{Transport: http.DefaultTransport}
+	if _, err := client.Get("http://" + network.Nodes[0].Address + "/health"); err != nil {
+		t.Errorf("expected requests from outside the network to go through, got %v", err)
+	}
+	if network.Sync() != 0 {
+		t.Error("expected no chain to be replaced when every request is dropped")
+	}
+}
@@ -0,0 +1,170 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"diy.blockchain.org/m/logger"
+)
+
+const (
+	// DeliveryPending is the status of deliveries still being tried
+	DeliveryPending = "pending"
+	// DeliverySucceeded is the status of deliveries the receiver acknowledged with a 2xx
+	DeliverySucceeded = "succeeded"
+	// DeliveryFailed is the status of deliveries that ran out of attempts
+	DeliveryFailed = "failed"
+
+	// SignatureHeader holds "sha256=" followed by the hex encoded HMAC-SHA256 of the body, keyed with the secret
+	SignatureHeader = "X-Webhook-Signature"
+	// EventHeader holds the event type of the payload
+	EventHeader = "X-Webhook-Event"
+	// DeliveryHeader holds the ID of the delivery, identical across retries so receivers can deduplicate
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Payload is the body posted to the webhook URL
+type Payload struct {
+	ID        string      `json:"id"`
+	WebhookID string      `json:"webhook_id"`
+	Event     string      `json:"event"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery is the log of a payload sent to a webhook
+type Delivery struct {
+	ID       string    `json:"id"`
+	Event    string    `json:"event"`
+	Status   string    `json:"status"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+// Attempt is a try at delivering a payload
+type Attempt struct {
+	Timestamp  int64  `json:"timestamp"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Sign returns the value of SignatureHeader for a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// job is a delivery waiting for a worker
+type job struct {
+	url      string
+	secret   string
+	body     []byte
+	delivery *Delivery
+}
+
+// deliver logs a delivery of data to a webhook and queues it for the workers, with d.mu held. The delivery fails
+// right away when the queue is full.
+func (d *Dispatcher) deliver(webhook *Webhook, event string, data interface{}) {
+	payload := Payload{ID: randomID(8), WebhookID: webhook.ID, Event: event, Timestamp: time.Now().Unix(), Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Errorf("Webhook %s: payload of %s couldn't be encoded: %v", webhook.ID, event, err)
+		return
+	}
+
+	delivery := &Delivery{ID: payload.ID, Event: event, Status: DeliveryPending, Attempts: []Attempt{}}
+	webhook.deliveries = append(webhook.deliveries, delivery)
+	if len(webhook.deliveries) > MaxDeliveryLog {
+		webhook.deliveries = webhook.deliveries[len(webhook.deliveries)-MaxDeliveryLog:]
+	}
+
+	select {
+	case d.queue <- job{url: webhook.URL, secret: webhook.Secret, body: body, delivery: delivery}:
+	default:
+		delivery.Status = DeliveryFailed
+		delivery.Attempts = append(delivery.Attempts, Attempt{Timestamp: time.Now().Unix(), Error: "too many deliveries queued"})
+		logger.Warnf("Delivery %s to %s dropped, %d deliveries are queued already", delivery.ID, webhook.URL, DeliveryQueue)
+	}
+}
+
+// work sends the queued deliveries one at a time until ctx is done
+func (d *Dispatcher) work(ctx context.Context) {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-d.queue:
+			d.send(ctx, job.url, job.secret, job.body, job.delivery)
+		}
+	}
+}
+
+// send posts a payload until the receiver acknowledges it or the attempts run out, waiting longer after each failure
+func (d *Dispatcher) send(ctx context.Context, url, secret string, body []byte, delivery *Delivery) {
+	backoff := d.Backoff
+	for attempt := 1; ; attempt++ {
+		result := d.post(ctx, url, secret, body, delivery)
+
+		d.mu.Lock()
+		delivery.Attempts = append(delivery.Attempts, result)
+		succeeded := result.Error == "" && result.StatusCode/100 == 2
+		if succeeded {
+			delivery.Status = DeliverySucceeded
+		} else if attempt >= d.MaxAttempts {
+			delivery.Status = DeliveryFailed
+		}
+		d.mu.Unlock()
+		if succeeded {
+			return
+		}
+		if attempt >= d.MaxAttempts {
+			logger.Warnf("Delivery %s to %s failed after %d attempts", delivery.ID, url, attempt)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			delivery.Status = DeliveryFailed
+			d.mu.Unlock()
+			return
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, url, secret string, body []byte, delivery *Delivery) Attempt {
+	attempt := Attempt{Timestamp: time.Now().Unix()}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, Sign(secret, body))
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode/100 != 2 {
+		attempt.Error = fmt.Sprintf("receiver answered %s", response.Status)
+	}
+	return attempt
+}
@@ -0,0 +1,380 @@
+// Package webhooks notifies downstream services of chain events with signed HTTP callbacks
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+)
+
+const (
+	// EventTransactionConfirmed is delivered with a TransactionConfirmation once a transaction
+	// reaches the confirmations threshold of the webhook
+	EventTransactionConfirmed = "transaction.confirmed"
+	// EventBuffer is the number of chain events the dispatcher can lag behind before it misses some
+	EventBuffer = 256
+	// MaxDeliveryLog is the number of deliveries kept per webhook, older ones are forgotten
+	MaxDeliveryLog = 100
+	// DeliveryQueue is the number of deliveries that can wait for a worker, deliveries beyond it fail
+	DeliveryQueue = 1024
+)
+
+var (
+	// ErrInvalidWebhook is returned for subscriptions with a bad URL, event type or threshold
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrUnknownWebhook is returned when a webhook can't be found
+	ErrUnknownWebhook = errors.New("unknown webhook")
+)
+
+// Subscription is what a service asks to be notified of
+type Subscription struct {
+	URL string `json:"url"`
+	// Events are topic filters, see blockchain.TopicMatches. transaction.confirmed comes on top of the chain topics.
+	Events []string `json:"events"`
+	// Address restricts transaction and block events to the ones involving this address
+	Address string `json:"address,omitempty"`
+	// Confirmations is the depth a transaction must reach before transaction.confirmed is delivered, 1 by default
+	Confirmations int `json:"confirmations,omitempty"`
+	// Secret is the HMAC key of the signatures, generated when empty
+	Secret string `json:"secret,omitempty"`
+}
+
+// Webhook is a registered subscription
+type Webhook struct {
+	ID string `json:"id"`
+	Subscription
+	CreatedAt int64 `json:"created_at"`
+
+	// scanned is the index of the last block checked for transaction.confirmed
+	scanned int
+	// confirmed maps the transactions already delivered as confirmed to the index of their block. Blocks deeper
+	// than blockchain.UndoDepth below scanned are dropped, a reorg isn't expected to replace them.
+	confirmed  map[string]int
+	deliveries []*Delivery
+}
+
+// TransactionConfirmation is the data of transaction.confirmed events
+type TransactionConfirmation struct {
+	Transaction   blockchain.Transaction `json:"transaction"`
+	BlockIndex    int                    `json:"block_index"`
+	BlockHash     string                 `json:"block_hash"`
+	Position      int                    `json:"position"`
+	Confirmations int                    `json:"confirmations"`
+}
+
+// Dispatcher turns the events of a blockchain into webhook deliveries
+type Dispatcher struct {
+	// Client sends the deliveries
+	Client *http.Client
+	// MaxAttempts is the number of times a delivery is tried before it fails
+	MaxAttempts int
+	// Backoff is the wait before the first retry, doubled for each following one
+	Backoff time.Duration
+	// Workers is the number of deliveries sent at once
+	Workers int
+	// AllowedNetworks are the private, loopback and link-local ranges webhooks may target, no such address is
+	// reached otherwise
+	AllowedNetworks []*net.IPNet
+
+	bc           *blockchain.Blockchain
+	subscription *blockchain.Subscription
+	webhooks     map[string]*Webhook
+	queue        chan job
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+}
+
+// NewDispatcher subscribes to the events of bc, they are delivered once Run is called
+func NewDispatcher(bc *blockchain.Blockchain) *Dispatcher {
+	d := &Dispatcher{
+		MaxAttempts:  5,
+		Backoff:      time.Second,
+		Workers:      8,
+		bc:           bc,
+		subscription: bc.Events().Subscribe(EventBuffer),
+		webhooks:     make(map[string]*Webhook),
+		queue:        make(chan job, DeliveryQueue),
+	}
+	// Addresses are checked again when connecting, a host may resolve differently than when it was registered
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: d.checkConnection}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	d.Client = &http.Client{Timeout: 10 * time.Second, Transport: transport}
+	return d
+}
+
+// Run delivers events until ctx is done, then waits for the deliveries in flight to give up
+func (d *Dispatcher) Run(ctx context.Context) {
+	for i := 0; i < d.Workers; i++ {
+		d.wg.Add(1)
+		go d.work(ctx)
+	}
+	defer d.wg.Wait()
+	defer d.subscription.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-d.subscription.C:
+			d.handle(event)
+		}
+	}
+}
+
+// Register validates a subscription and starts delivering its events
+func (d *Dispatcher) Register(subscription Subscription) (Webhook, error) {
+	if err := validateSubscription(&subscription); err != nil {
+		return Webhook{}, err
+	}
+	if err := d.checkHost(subscription.URL); err != nil {
+		return Webhook{}, err
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = randomID(32)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	webhook := &Webhook{
+		ID:           randomID(8),
+		Subscription: subscription,
+		CreatedAt:    time.Now().Unix(),
+		// Transactions already as deep as the threshold aren't news
+		scanned:   max(0, d.bc.LatestBlock().Index-subscription.Confirmations+1),
+		confirmed: make(map[string]int),
+	}
+	d.webhooks[webhook.ID] = webhook
+	return *webhook, nil
+}
+
+// Webhooks returns the registered webhooks ordered by creation, without their secrets
+func (d *Dispatcher) Webhooks() []Webhook {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	webhooks := []Webhook{}
+	for _, webhook := range d.webhooks {
+		webhooks = append(webhooks, webhook.public())
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].CreatedAt != webhooks[j].CreatedAt {
+			return webhooks[i].CreatedAt < webhooks[j].CreatedAt
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks
+}
+
+// Webhook returns a registered webhook without its secret
+func (d *Dispatcher) Webhook(id string) (Webhook, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	webhook, ok := d.webhooks[id]
+	if !ok {
+		return Webhook{}, fmt.Errorf("%w: %s", ErrUnknownWebhook, id)
+	}
+	return webhook.public(), nil
+}
+
+// Remove stops delivering the events of a webhook, deliveries in flight still complete
+func (d *Dispatcher) Remove(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.webhooks[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownWebhook, id)
+	}
+	delete(d.webhooks, id)
+	return nil
+}
+
+// Deliveries returns the delivery log of a webhook, newest first
+func (d *Dispatcher) Deliveries(id string) ([]Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	webhook, ok := d.webhooks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWebhook, id)
+	}
+	deliveries := make([]Delivery, 0, len(webhook.deliveries))
+	for i := len(webhook.deliveries) - 1; i >= 0; i-- {
+		delivery := *webhook.deliveries[i]
+		delivery.Attempts = append([]Attempt{}, delivery.Attempts...)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// handle queues the deliveries of a chain event, and of the transactions it confirmed
+func (d *Dispatcher) handle(event blockchain.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, webhook := range d.webhooks {
+		if webhook.wants(event.Topic) && webhook.concerned(event) {
+			d.deliver(webhook, event.Topic, event.Data)
+		}
+	}
+
+	switch event.Topic {
+	case blockchain.EventChainReplaced:
+		// Rescan the blocks that replaced the ones already scanned, confirmed transactions aren't delivered twice
+		fork := event.Data.(blockchain.ChainReplacement).Fork
+		for _, webhook := range d.webhooks {
+			webhook.scanned = min(webhook.scanned, fork)
+		}
+		d.confirm()
+	case blockchain.EventBlockMined, blockchain.EventBlockReceived:
+		d.confirm()
+	}
+}
+
+// confirm delivers the transactions that reached the confirmations threshold of each webhook
+func (d *Dispatcher) confirm() {
+	length := d.bc.LatestBlock().Index
+	for _, webhook := range d.webhooks {
+		if !webhook.wants(EventTransactionConfirmed) {
+			continue
+		}
+		for webhook.scanned < length-webhook.Confirmations+1 {
+			block, ok := d.bc.BlockByIndex(webhook.scanned + 1)
+			if !ok {
+				break
+			}
+			webhook.scanned++
+			for position, transaction := range block.Transactions {
+				if _, ok := webhook.confirmed[transaction.ID]; ok || (webhook.Address != "" && !transaction.Involves(webhook.Address)) {
+					continue
+				}
+				webhook.confirmed[transaction.ID] = block.Index
+				d.deliver(webhook, EventTransactionConfirmed, TransactionConfirmation{
+					Transaction:   transaction,
+					BlockIndex:    block.Index,
+					BlockHash:     block.Hash,
+					Position:      position,
+					Confirmations: length - block.Index + 1,
+				})
+			}
+		}
+		for id, index := range webhook.confirmed {
+			if index <= webhook.scanned-blockchain.UndoDepth {
+				delete(webhook.confirmed, id)
+			}
+		}
+	}
+}
+
+// wants tells if a webhook subscribed to a topic
+func (w *Webhook) wants(topic string) bool {
+	for _, filter := range w.Events {
+		if blockchain.TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// concerned tells if a chain event involves the address of a webhook. Events unrelated to transactions
+// only go to webhooks without an address.
+func (w *Webhook) concerned(event blockchain.Event) bool {
+	if w.Address == "" {
+		return true
+	}
+	switch data := event.Data.(type) {
+	case blockchain.Transaction:
+		return data.Involves(w.Address)
+	case blockchain.Block:
+		for _, transaction := range data.Transactions {
+			if transaction.Involves(w.Address) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *Webhook) public() Webhook {
+	webhook := *w
+	webhook.Secret = ""
+	return webhook
+}
+
+func validateSubscription(subscription *Subscription) error {
+	target, err := url.Parse(subscription.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", ErrInvalidWebhook)
+	}
+	if len(subscription.Events) == 0 {
+		return fmt.Errorf("%w: at least one event type is needed", ErrInvalidWebhook)
+	}
+	for _, event := range subscription.Events {
+		if !blockchain.ValidTopic(event) && !blockchain.TopicMatches(event, EventTransactionConfirmed) {
+			return fmt.Errorf("%w: unknown event type %s", ErrInvalidWebhook, event)
+		}
+	}
+	if subscription.Confirmations < 0 {
+		return fmt.Errorf("%w: confirmations can't be negative", ErrInvalidWebhook)
+	}
+	subscription.Confirmations = max(subscription.Confirmations, 1)
+	return nil
+}
+
+// checkHost rejects the URL of a webhook when its host resolves to an address webhooks may not target
+func (d *Dispatcher) checkHost(rawURL string) error {
+	target, _ := url.Parse(rawURL) // validateSubscription already parsed it
+	ips, err := net.DefaultResolver.LookupIP(context.Background(), "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: host %s can't be resolved", ErrInvalidWebhook, target.Hostname())
+	}
+	for _, ip := range ips {
+		if !d.reachable(ip) {
+			return fmt.Errorf("%w: %s resolves to %s, a private, loopback or link-local address", ErrInvalidWebhook, target.Hostname(), ip)
+		}
+	}
+	return nil
+}
+
+// checkConnection refuses to connect deliveries to addresses webhooks may not target
+func (d *Dispatcher) checkConnection(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !d.reachable(ip) {
+		return fmt.Errorf("webhooks can't be delivered to %s, a private, loopback or link-local address", host)
+	}
+	return nil
+}
+
+// reachable tells if webhooks may target ip, which is either public or in one of the allowed networks
+func (d *Dispatcher) reachable(ip net.IP) bool {
+	if !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range d.AllowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
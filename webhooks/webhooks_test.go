@@ -0,0 +1,208 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"diy.blockchain.org/m/blockchain"
+	"diy.blockchain.org/m/webhooks"
+)
+
+// receiver records the payloads posted to it, failing the first failures requests
+type receiver struct {
+	secret   string
+	failures int
+	payloads chan webhooks.Payload
+	t        *testing.T
+	mu       sync.Mutex
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+	if signature := request.Header.Get(webhooks.SignatureHeader); signature != webhooks.Sign(r.secret, body) {
+		r.t.Errorf("expected the payload to be signed with the secret, got %s", signature)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var payload webhooks.Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		r.t.Errorf("expected a JSON payload, got %s", body)
+	}
+	if payload.Event != request.Header.Get(webhooks.EventHeader) || payload.ID != request.Header.Get(webhooks.DeliveryHeader) {
+		r.t.Errorf("expected headers to match payload %+v, got %v", payload, request.Header)
+	}
+	r.payloads <- payload
+}
+
+func (r *receiver) next(t *testing.T) webhooks.Payload {
+	select {
+	case payload := <-r.payloads:
+		return payload
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a delivery")
+		return webhooks.Payload{}
+	}
+}
+
+func newDispatcher(t *testing.T, bc *blockchain.Blockchain) *webhooks.Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatcher := webhooks.NewDispatcher(bc)
+	dispatcher.Backoff = time.Millisecond
+	dispatcher.MaxAttempts = 3
+	// The receivers of the tests listen on the loopback interface
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	dispatcher.AllowedNetworks = []*net.IPNet{loopback}
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return dispatcher
+}
+
+func waitForStatus(t *testing.T, dispatcher *webhooks.Dispatcher, id, status string) webhooks.Delivery {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		deliveries, err := dispatcher.Deliveries(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) > 0 && deliveries[0].Status == status {
+			return deliveries[0]
+		}
+	}
+	t.Fatalf("expected the last delivery of %s to be %s", id, status)
+	return webhooks.Delivery{}
+}
+
+// TestConfirmations delivers the transactions of an address once they are deep enough.
+func TestConfirmations(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	dispatcher := newDispatcher(t, bc)
+	receiver := &receiver{secret: "s3cr3t", payloads: make(chan webhooks.Payload, 10), t: t}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := dispatcher.Register(webhooks.Subscription{
+		URL:           server.URL,
+		Events:        []string{webhooks.EventTransactionConfirmed},
+		Address:       "Bob",
+		Confirmations: 2,
+		Secret:        receiver.secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed, _ := dispatcher.Webhook(webhook.ID); listed.Secret != "" || listed.Confirmations != 2 {
+		t.Errorf("expected the webhook without its secret, got %+v", listed)
+	}
+
+	for _, transaction := range []blockchain.Transaction{
+		{Sender: "Alice", Recipient: "Bob", Amount: 1},
+		{Sender: "Alice", Recipient: "Carol", Amount: 1, Nonce: 1},
+	} {
+		if _, err := bc.AddTransaction(&transaction); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := bc.NewBlock(bc.LastBlock().Hash)
+	bc.NewBlock(bc.LastBlock().Hash)
+
+	payload := receiver.next(t)
+	data, _ := json.Marshal(payload.Data)
+	var confirmation webhooks.TransactionConfirmation
+	json.Unmarshal(data, &confirmation)
+	if payload.Event != webhooks.EventTransactionConfirmed || confirmation.Transaction.Recipient != "Bob" || confirmation.BlockHash != block.Hash || confirmation.Confirmations != 2 {
+		t.Errorf("expected the transaction to Bob confirmed twice, got %+v", payload)
+	}
+
+	bc.NewBlock(bc.LastBlock().Hash)
+	waitForStatus(t, dispatcher, webhook.ID, webhooks.DeliverySucceeded)
+	select {
+	case payload := <-receiver.payloads:
+		t.Errorf("expected a single delivery, got %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestRetries retries failed deliveries with backoff and logs every attempt.
+func TestRetries(t *testing.T) {
+	bc := blockchain.NewBlockchain()
+	dispatcher := newDispatcher(t, bc)
+	receiver := &receiver{secret: "s3cr3t", failures: 2, payloads: make(chan webhooks.Payload, 10), t: t}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	webhook, err := dispatcher.Register(webhooks.Subscription{URL: server.URL, Events: []string{"block"}, Secret: receiver.secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.NewBlock(bc.LastBlock().Hash)
+	if payload := receiver.next(t); payload.Event != blockchain.EventBlockMined {
+		t.Errorf("expected a block.mined delivery, got %+v", payload)
+	}
+	delivery := waitForStatus(t, dispatcher, webhook.ID, webhooks.DeliverySucceeded)
+	if len(delivery.Attempts) != 3 || delivery.Attempts[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected two failed attempts before the delivery succeeded, got %+v", delivery.Attempts)
+	}
+
+	receiver.mu.Lock()
+	receiver.failures = 3
+	receiver.mu.Unlock()
+	bc.NewBlock(bc.LastBlock().Hash)
+	if delivery := waitForStatus(t, dispatcher, webhook.ID, webhooks.DeliveryFailed); len(delivery.Attempts) != 3 {
+		t.Errorf("expected the delivery to fail after 3 attempts, got %+v", delivery.Attempts)
+	}
+
+	if err := dispatcher.Remove(webhook.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dispatcher.Deliveries(webhook.ID); !errors.Is(err, webhooks.ErrUnknownWebhook) {
+		t.Errorf("expected ErrUnknownWebhook, got %v", err)
+	}
+}
+
+// TestInvalidSubscriptions rejects subscriptions that can't be delivered.
+func TestInvalidSubscriptions(t *testing.T) {
+	dispatcher := webhooks.NewDispatcher(blockchain.NewBlockchain())
+	for _, subscription := range []webhooks.Subscription{
+		{URL: "ftp://example.com", Events: []string{"block"}},
+		{URL: "/relative", Events: []string{"block"}},
+		{URL: "http://example.com"},
+		{URL: "http://example.com", Events: []string{"blocks"}},
+		{URL: "http://example.com", Events: []string{"transaction"}, Confirmations: -1},
+		{URL: "http://127.0.0.1:8080/hook", Events: []string{"block"}},
+		{URL: "http://10.0.0.1/hook", Events: []string{"block"}},
+		{URL: "http://169.254.169.254/latest/meta-data", Events: []string{"block"}},
+		{URL: "http://[::1]/hook", Events: []string{"block"}},
+	} {
+		if _, err := dispatcher.Register(subscription); !errors.Is(err, webhooks.ErrInvalidWebhook) {
+			t.Errorf("expected ErrInvalidWebhook for %+v, got %v", subscription, err)
+		}
+	}
+
+	// Hosts can resolve to another address after registration, so deliveries check it again
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	if response, err := dispatcher.Client.Post(server.URL, "application/json", nil); err == nil {
+		response.Body.Close()
+		t.Error("expected the client of the dispatcher not to connect to a loopback address")
+	}
+}